@@ -28,8 +28,13 @@ func main() {
 	// Initialize Redis
 	rdb := database.ConnectRedis(cfg)
 
-	// Create Fiber app
+	// Create Fiber app; the body limit has to fit the largest upload we accept
+	bodyLimit := cfg.MaxFileSize
+	if cfg.ChatImportMaxSize > bodyLimit {
+		bodyLimit = cfg.ChatImportMaxSize
+	}
 	app := fiber.New(fiber.Config{
+		BodyLimit: int(bodyLimit),
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/pkg/database"
+)
+
+// migrate runs the schema and data migrations the API runs at start, and with
+// -drop-legacy also drops the columns those data migrations copied out of
+func main() {
+	dropLegacy := flag.Bool("drop-legacy", false, "drop the old tags and notes columns")
+	flag.Parse()
+
+	db, err := database.Connect(config.Load())
+	if err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	if *dropLegacy {
+		if err := database.DropLegacyColumns(db); err != nil {
+			log.Fatal("Failed to drop legacy columns:", err)
+		}
+	}
+	log.Println("Database is up to date")
+}
@@ -62,6 +62,63 @@ type Config struct {
 	GCredentialsPath       string
 	GCSSignedURLExpSeconds int64
 
+	// Outbound event webhooks
+	EventWebhookMaxAttempts    int
+	EventWebhookDisableAfter   int
+	EventWebhookTimeoutSeconds int
+
+	// Agent presence
+	PresenceTTLSeconds int
+
+	// Collision detection
+	CollisionViewingTTLSeconds int
+	CollisionTypingTTLSeconds  int
+	CollisionLock              string
+
+	// Read tracking
+	ReadReceipts bool
+
+	// Routing
+	RoutingEnabled       bool
+	RoutingStrategy      string
+	RoutingMaxConcurrent int
+
+	// Conversation lifecycle
+	ConversationReopenPolicy      string
+	ConversationReopenWindowHours int
+	TransferTimeoutSeconds        int
+	AutoCloseIdleMinutes          int
+	AutoCloseTemplate             string
+
+	// Business hours and SLA
+	BusinessTimezone        string
+	BusinessHours           string
+	BusinessHolidays        string
+	OutOfOfficeMessage      string
+	OutOfOfficeTemplate     string
+	SLACheckIntervalSeconds int
+
+	// CSAT surveys
+	CSATEnabled        bool
+	CSATChannel        string
+	CSATTemplate       string
+	CSATQuestion       string
+	CSATFollowUp       string
+	CSATThanks         string
+	CSATExpiryHours    int
+	CSATCommentMinutes int
+
+	// Chat history import
+	ChatImportMaxSize int64
+
+	// Transcript export
+	ExportLinkExpiryHours  int
+	ExportMaxConversations int
+
+	// Customer spreadsheet import
+	CustomerImportMaxRows int
+	DefaultCountryCode    string
+
 	// Allowed types
 	AllowedImageTypes    []string
 	AllowedDocumentTypes []string
@@ -123,6 +180,52 @@ func Load() *Config {
 		GCredentialsPath:       getEnv("GOOGLE_APPLICATION_CREDENTIALS", ""),
 		GCSSignedURLExpSeconds: gcsExp,
 
+		EventWebhookMaxAttempts:    parseInt("EVENT_WEBHOOK_MAX_ATTEMPTS", 6),
+		EventWebhookDisableAfter:   parseInt("EVENT_WEBHOOK_DISABLE_AFTER", 15),
+		EventWebhookTimeoutSeconds: parseInt("EVENT_WEBHOOK_TIMEOUT_SECONDS", 10),
+
+		PresenceTTLSeconds: parseInt("PRESENCE_TTL_SECONDS", 90),
+
+		CollisionViewingTTLSeconds: parseInt("COLLISION_VIEWING_TTL_SECONDS", 30),
+		CollisionTypingTTLSeconds:  parseInt("COLLISION_TYPING_TTL_SECONDS", 8),
+		CollisionLock:              getEnv("COLLISION_LOCK", "off"),
+
+		ReadReceipts: getEnv("READ_RECEIPTS", "false") == "true",
+
+		RoutingEnabled:       getEnv("ROUTING_ENABLED", "true") == "true",
+		RoutingStrategy:      getEnv("ROUTING_STRATEGY", "least_active"),
+		RoutingMaxConcurrent: parseInt("ROUTING_MAX_CONCURRENT", 5),
+
+		ConversationReopenPolicy:      getEnv("CONVERSATION_REOPEN_POLICY", "reopen"),
+		ConversationReopenWindowHours: parseInt("CONVERSATION_REOPEN_WINDOW_HOURS", 72),
+		TransferTimeoutSeconds:        parseInt("TRANSFER_TIMEOUT_SECONDS", 120),
+		AutoCloseIdleMinutes:          parseInt("AUTO_CLOSE_IDLE_MINUTES", 0),
+		AutoCloseTemplate:             getEnv("AUTO_CLOSE_TEMPLATE", ""),
+
+		BusinessTimezone:        getEnv("BUSINESS_TIMEZONE", "Asia/Jakarta"),
+		BusinessHours:           getEnv("BUSINESS_HOURS", ""),
+		BusinessHolidays:        getEnv("BUSINESS_HOLIDAYS", ""),
+		OutOfOfficeMessage:      getEnv("OUT_OF_OFFICE_MESSAGE", ""),
+		OutOfOfficeTemplate:     getEnv("OUT_OF_OFFICE_TEMPLATE", ""),
+		SLACheckIntervalSeconds: parseInt("SLA_CHECK_INTERVAL_SECONDS", 60),
+
+		CSATEnabled:        getEnv("CSAT_ENABLED", "false") == "true",
+		CSATChannel:        getEnv("CSAT_CHANNEL", "interactive"),
+		CSATTemplate:       getEnv("CSAT_TEMPLATE", ""),
+		CSATQuestion:       getEnv("CSAT_QUESTION", "How satisfied are you with our help? Please pick a rating from 1 to 5."),
+		CSATFollowUp:       getEnv("CSAT_FOLLOWUP", "Thank you! Is there anything we could do better? Just reply to this message."),
+		CSATThanks:         getEnv("CSAT_THANKS", "Thank you for your feedback!"),
+		CSATExpiryHours:    parseInt("CSAT_EXPIRY_HOURS", 24),
+		CSATCommentMinutes: parseInt("CSAT_COMMENT_MINUTES", 30),
+
+		ChatImportMaxSize: int64(parseInt("CHAT_IMPORT_MAX_SIZE", 104857600)),
+
+		ExportLinkExpiryHours:  parseInt("EXPORT_LINK_EXPIRY_HOURS", 168),
+		ExportMaxConversations: parseInt("EXPORT_MAX_CONVERSATIONS", 500),
+
+		CustomerImportMaxRows: parseInt("CUSTOMER_IMPORT_MAX_ROWS", 50000),
+		DefaultCountryCode:    getEnv("DEFAULT_COUNTRY_CODE", "62"),
+
 		AllowedImageTypes:    splitCSV(getEnv("ALLOWED_IMAGE_TYPES", "jpg,jpeg,png,gif,webp")),
 		AllowedDocumentTypes: splitCSV(getEnv("ALLOWED_DOCUMENT_TYPES", "pdf,doc,docx,xls,xlsx,ppt,pptx")),
 		AllowedAudioTypes:    splitCSV(getEnv("ALLOWED_AUDIO_TYPES", "mp3,ogg,m4a,wav,aac")),
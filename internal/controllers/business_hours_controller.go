@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BusinessHoursController struct {
+	db    *gorm.DB
+	hours *services.BusinessHoursService
+}
+
+func NewBusinessHoursController(db *gorm.DB, hours *services.BusinessHoursService) *BusinessHoursController {
+	return &BusinessHoursController{db: db, hours: hours}
+}
+
+type scheduleRequest struct {
+	Name              *string `json:"name"`
+	Timezone          *string `json:"timezone"`
+	Hours             *string `json:"hours"`
+	IsDefault         *bool   `json:"is_default"`
+	AutoReplyEnabled  *bool   `json:"auto_reply_enabled"`
+	AutoReplyMessage  *string `json:"auto_reply_message"`
+	AutoReplyTemplate *string `json:"auto_reply_template"`
+}
+
+// List returns every business schedule with its holidays
+func (bc *BusinessHoursController) List(c *fiber.Ctx) error {
+	schedules, err := bc.hours.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch schedules"})
+	}
+	return c.JSON(fiber.Map{"schedules": schedules})
+}
+
+// Create adds a schedule
+func (bc *BusinessHoursController) Create(c *fiber.Ctx) error {
+	var req scheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	var schedule models.BusinessSchedule
+	req.apply(&schedule)
+	if err := bc.hours.Save(&schedule); err != nil {
+		return bc.fail(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(schedule)
+}
+
+// Update edits a schedule's hours, timezone or auto-reply
+func (bc *BusinessHoursController) Update(c *fiber.Ctx) error {
+	var schedule models.BusinessSchedule
+	if err := bc.db.First(&schedule, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+	}
+	var req scheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.apply(&schedule)
+	if err := bc.hours.Save(&schedule); err != nil {
+		return bc.fail(c, err)
+	}
+	return c.JSON(schedule)
+}
+
+// Delete removes a schedule; teams using it fall back to the default
+func (bc *BusinessHoursController) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+	}
+	if err := bc.hours.Delete(id); err != nil {
+		return bc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Schedule deleted successfully"})
+}
+
+// AddHoliday closes a schedule for a day: POST /business-hours/:id/holidays {"date":"2026-12-25","name":"...","recurring":true}
+func (bc *BusinessHoursController) AddHoliday(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Schedule not found"})
+	}
+	var req struct {
+		Date      string `json:"date"`
+		Name      string `json:"name"`
+		Recurring bool   `json:"recurring"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	holiday, err := bc.hours.AddHoliday(id, req.Date, req.Name, req.Recurring)
+	if err != nil {
+		return bc.fail(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(holiday)
+}
+
+// RemoveHoliday deletes a holiday from a schedule
+func (bc *BusinessHoursController) RemoveHoliday(c *fiber.Ctx) error {
+	id, err1 := uuid.Parse(c.Params("id"))
+	holidayID, err2 := uuid.Parse(c.Params("holidayId"))
+	if err1 != nil || err2 != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Holiday not found"})
+	}
+	if err := bc.hours.RemoveHoliday(id, holidayID); err != nil {
+		return bc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Holiday removed successfully"})
+}
+
+// Status reports whether a team (or the default schedule) is open: GET /business-hours/status?team_id=
+func (bc *BusinessHoursController) Status(c *fiber.Ctx) error {
+	var teamID *uuid.UUID
+	if v := c.Query("team_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid team_id"})
+		}
+		teamID = &id
+	}
+	hours := bc.hours.ForTeam(teamID)
+	now := time.Now()
+	return c.JSON(fiber.Map{
+		"open":        hours.IsOpen(now),
+		"always_open": hours.AlwaysOpen(),
+		"next_open":   hours.NextOpen(now),
+	})
+}
+
+func (r scheduleRequest) apply(s *models.BusinessSchedule) {
+	if r.Name != nil {
+		s.Name = *r.Name
+	}
+	if r.Timezone != nil {
+		s.Timezone = strings.TrimSpace(*r.Timezone)
+	}
+	if r.Hours != nil {
+		s.Hours = strings.TrimSpace(*r.Hours)
+	}
+	if r.IsDefault != nil {
+		s.IsDefault = *r.IsDefault
+	}
+	if r.AutoReplyEnabled != nil {
+		s.AutoReplyEnabled = *r.AutoReplyEnabled
+	}
+	if r.AutoReplyMessage != nil {
+		s.AutoReplyMessage = *r.AutoReplyMessage
+	}
+	if r.AutoReplyTemplate != nil {
+		s.AutoReplyTemplate = strings.TrimSpace(*r.AutoReplyTemplate)
+	}
+}
+
+func (bc *BusinessHoursController) fail(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case err == services.ErrScheduleTaken:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrScheduleName, err == services.ErrHolidayDate, strings.HasPrefix(err.Error(), "timezone"), strings.HasPrefix(err.Error(), "business hours"):
+		// ParseBusinessHours errors describe what is wrong with the input
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save schedule"})
+}
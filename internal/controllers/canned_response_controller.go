@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CannedResponseController struct {
+	canned *services.CannedResponseService
+	media  *services.MediaUploader
+}
+
+func NewCannedResponseController(canned *services.CannedResponseService, media *services.MediaUploader) *CannedResponseController {
+	return &CannedResponseController{canned: canned, media: media}
+}
+
+type cannedRequest struct {
+	Shortcode *string `json:"shortcode"`
+	Title     *string `json:"title"`
+	Content   *string `json:"content"`
+	Shared    bool    `json:"shared"`
+}
+
+// List returns the shared library and the caller's own responses: GET /canned-responses?search=
+func (cc *CannedResponseController) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	responses, err := cc.canned.List(user.ID, c.Query("search"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch canned responses"})
+	}
+	return c.JSON(fiber.Map{"canned_responses": responses})
+}
+
+// Create adds a personal response, or a shared one for supervisors and admins
+func (cc *CannedResponseController) Create(c *fiber.Ctx) error {
+	var req cannedRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	user := c.Locals("user").(*models.User)
+	canned := models.CannedResponse{CreatedBy: user.ID}
+	if !req.Shared {
+		canned.OwnerID = &user.ID
+	}
+	if !services.CanManage(user, &canned) {
+		return cc.fail(c, services.ErrCannedForbidden)
+	}
+	req.apply(&canned)
+	if err := cc.canned.Save(&canned); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(canned)
+}
+
+// Update edits a response the caller manages
+func (cc *CannedResponseController) Update(c *fiber.Ctx) error {
+	canned, err := cc.manageable(c)
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	var req cannedRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.apply(canned)
+	if err := cc.canned.Save(canned); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(canned)
+}
+
+// Delete removes a response the caller manages
+func (cc *CannedResponseController) Delete(c *fiber.Ctx) error {
+	canned, err := cc.manageable(c)
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	if err := cc.canned.Delete(canned); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Canned response deleted successfully"})
+}
+
+// UploadMedia attaches an image or document (multipart field "file") to a response
+func (cc *CannedResponseController) UploadMedia(c *fiber.Ctx) error {
+	canned, err := cc.manageable(c)
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file is required"})
+	}
+	path, mediaType, err := cc.media.Store(c.Context(), file, c.FormValue("type"))
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to store file"})
+	}
+	if err := cc.canned.AttachMedia(canned, path, mediaType, file.Filename); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(canned)
+}
+
+// Render resolves placeholders for a conversation: GET /canned-responses/:id/render?conversation_id=
+func (cc *CannedResponseController) Render(c *fiber.Ctx) error {
+	canned, err := cc.usable(c)
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	convID, err := uuid.Parse(c.Query("conversation_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "conversation_id is required"})
+	}
+	content, err := cc.canned.Render(canned, convID, c.Locals("user").(*models.User))
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"content": content, "media_type": canned.MediaType, "file_name": canned.FileName})
+}
+
+// Send renders a response and sends it: POST /canned-responses/:id/send {"conversation_id":"..."}
+func (cc *CannedResponseController) Send(c *fiber.Ctx) error {
+	canned, err := cc.usable(c)
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	var req struct {
+		ConversationID uuid.UUID `json:"conversation_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.ConversationID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "conversation_id is required"})
+	}
+	msg, err := cc.canned.Send(c.Context(), canned, req.ConversationID, c.Locals("user").(*models.User))
+	if err == gorm.ErrRecordNotFound {
+		return cc.fail(c, err)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(msg)
+}
+
+// Usage reports sends per response: GET /canned-responses/usage?from=YYYY-MM-DD&to=YYYY-MM-DD
+func (cc *CannedResponseController) Usage(c *fiber.Ctx) error {
+	var from, to *time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from date"})
+		}
+		from = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to date"})
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	usage, err := cc.canned.Usage(from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build report"})
+	}
+	return c.JSON(fiber.Map{"usage": usage})
+}
+
+// usable loads the :id response if the caller may use it
+func (cc *CannedResponseController) usable(c *fiber.Ctx) (*models.CannedResponse, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return cc.canned.Get(id, c.Locals("user").(*models.User).ID)
+}
+
+// manageable loads the :id response if the caller may change it
+func (cc *CannedResponseController) manageable(c *fiber.Ctx) (*models.CannedResponse, error) {
+	canned, err := cc.usable(c)
+	if err != nil {
+		return nil, err
+	}
+	if !services.CanManage(c.Locals("user").(*models.User), canned) {
+		return nil, services.ErrCannedForbidden
+	}
+	return canned, nil
+}
+
+func (r cannedRequest) apply(canned *models.CannedResponse) {
+	if r.Shortcode != nil {
+		canned.Shortcode = *r.Shortcode
+	}
+	if r.Title != nil {
+		canned.Title = *r.Title
+	}
+	if r.Content != nil {
+		canned.Content = *r.Content
+	}
+}
+
+func (cc *CannedResponseController) fail(c *fiber.Ctx, err error) error {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case services.ErrCannedInvalid, services.ErrCannedMedia:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrCannedForbidden:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrCannedTaken:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save canned response"})
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChatImportController struct {
+	imports *services.ChatImportService
+	cfg     *config.Config
+}
+
+func NewChatImportController(imports *services.ChatImportService, cfg *config.Config) *ChatImportController {
+	return &ChatImportController{imports: imports, cfg: cfg}
+}
+
+// Import loads a WhatsApp chat export into the customer's history:
+// POST /customers/:id/import-chat multipart file=<.txt|.zip>, me=<our name(s), comma separated>, date_order=dmy|mdy, timezone=Asia/Jakarta
+func (ic *ChatImportController) Import(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Customer not found"})
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file is required"})
+	}
+	if file.Size > ic.cfg.ChatImportMaxSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("file too large (max %d bytes)", ic.cfg.ChatImportMaxSize)})
+	}
+	opts := services.ChatImportOptions{DateOrder: c.FormValue("date_order")}
+	if opts.DateOrder != "" && opts.DateOrder != services.DateOrderDMY && opts.DateOrder != services.DateOrderMDY {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "date_order must be dmy or mdy"})
+	}
+	for _, name := range strings.Split(c.FormValue("me"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.Me = append(opts.Me, name)
+		}
+	}
+	if tz := c.FormValue("timezone"); tz != "" {
+		if opts.Location, err = time.LoadLocation(tz); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid timezone"})
+		}
+	}
+
+	result, err := ic.imports.ImportFile(c.Context(), id, file, opts)
+	switch {
+	case err == nil:
+		return c.Status(fiber.StatusCreated).JSON(result)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Customer not found"})
+	case err == services.ErrImportFile, err == services.ErrImportAuthors, err == services.ErrChatExportEmpty, errors.Is(err, services.ErrImportTooBig):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to import chat"})
+}
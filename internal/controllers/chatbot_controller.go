@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChatbotController struct {
+	db      *gorm.DB
+	chatbot *services.ChatbotService
+}
+
+func NewChatbotController(db *gorm.DB, chatbot *services.ChatbotService) *ChatbotController {
+	return &ChatbotController{db: db, chatbot: chatbot}
+}
+
+type ruleRequest struct {
+	Name      *string               `json:"name"`
+	MatchType *models.ChatbotMatch  `json:"match_type"`
+	Pattern   *string               `json:"pattern"`
+	Action    *models.ChatbotAction `json:"action"`
+	Reply     *string               `json:"reply"`
+	FlowKey   *string               `json:"flow_key"`
+	Priority  *int                  `json:"priority"`
+	IsActive  *bool                 `json:"is_active"`
+}
+
+type flowRequest struct {
+	Key        string          `json:"key"`
+	Name       string          `json:"name"`
+	Definition json.RawMessage `json:"definition"`
+}
+
+// ListRules returns the rules in the order they are tried
+func (cc *ChatbotController) ListRules(c *fiber.Ctx) error {
+	rules, err := cc.chatbot.ListRules()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch rules"})
+	}
+	return c.JSON(fiber.Map{"rules": rules})
+}
+
+// CreateRule adds a keyword or regex rule
+func (cc *ChatbotController) CreateRule(c *fiber.Ctx) error {
+	var req ruleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	rule := models.ChatbotRule{IsActive: true}
+	req.apply(&rule)
+	if err := cc.chatbot.SaveRule(&rule); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// UpdateRule edits a rule
+func (cc *ChatbotController) UpdateRule(c *fiber.Ctx) error {
+	var rule models.ChatbotRule
+	if err := cc.db.First(&rule, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Rule not found"})
+	}
+	var req ruleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.apply(&rule)
+	if err := cc.chatbot.SaveRule(&rule); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(rule)
+}
+
+// DeleteRule removes a rule
+func (cc *ChatbotController) DeleteRule(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Rule not found"})
+	}
+	if err := cc.chatbot.DeleteRule(id); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Rule deleted successfully"})
+}
+
+// ListFlows returns the newest version of every flow
+func (cc *ChatbotController) ListFlows(c *fiber.Ctx) error {
+	flows, err := cc.chatbot.ListFlows()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch flows"})
+	}
+	return c.JSON(fiber.Map{"flows": flows})
+}
+
+// CreateFlow stores version 1 of a new flow: POST /chatbot/flows {"key","name","definition":{...}}
+func (cc *ChatbotController) CreateFlow(c *fiber.Ctx) error {
+	var req flowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	user := c.Locals("user").(*models.User)
+	flow, err := cc.chatbot.SaveFlow(strings.TrimSpace(req.Key), req.Name, req.Definition, user.ID, true)
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(flow)
+}
+
+// UpdateFlow stores a new active version of a flow: PUT /chatbot/flows/:key
+func (cc *ChatbotController) UpdateFlow(c *fiber.Ctx) error {
+	var req flowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	user := c.Locals("user").(*models.User)
+	flow, err := cc.chatbot.SaveFlow(c.Params("key"), req.Name, req.Definition, user.ID, false)
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(flow)
+}
+
+// Versions lists every version of a flow, newest first
+func (cc *ChatbotController) Versions(c *fiber.Ctx) error {
+	flows, err := cc.chatbot.Versions(c.Params("key"))
+	if err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"versions": flows})
+}
+
+// Activate rolls a flow forward or back to one version: POST /chatbot/flows/:key/versions/:version/activate
+func (cc *ChatbotController) Activate(c *fiber.Ctx) error {
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Flow version not found"})
+	}
+	if err := cc.chatbot.Activate(c.Params("key"), version); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Flow version activated"})
+}
+
+// Deactivate stops a flow from starting; its versions are kept
+func (cc *ChatbotController) Deactivate(c *fiber.Ctx) error {
+	if err := cc.chatbot.Deactivate(c.Params("key")); err != nil {
+		return cc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Flow deactivated"})
+}
+
+// SetControl hands a conversation to the bot or a human: PUT /conversations/:id/control {"controlled_by":"human"}
+func (cc *ChatbotController) SetControl(c *fiber.Ctx) error {
+	var conv models.Conversation
+	if err := cc.db.First(&conv, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Conversation not found"})
+	}
+	var req struct {
+		ControlledBy models.ConversationControl `json:"controlled_by"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	user := c.Locals("user").(*models.User)
+	if err := cc.chatbot.SetControl(&conv, req.ControlledBy, services.UserActor(user.ID)); err != nil {
+		return cc.fail(c, err)
+	}
+	cc.db.First(&conv, "id = ?", conv.ID)
+	return c.JSON(conv)
+}
+
+func (r ruleRequest) apply(rule *models.ChatbotRule) {
+	if r.Name != nil {
+		rule.Name = *r.Name
+	}
+	if r.MatchType != nil {
+		rule.MatchType = *r.MatchType
+	}
+	if r.Pattern != nil {
+		rule.Pattern = *r.Pattern
+	}
+	if r.Action != nil {
+		rule.Action = *r.Action
+	}
+	if r.Reply != nil {
+		rule.Reply = *r.Reply
+	}
+	if r.FlowKey != nil {
+		rule.FlowKey = *r.FlowKey
+	}
+	if r.Priority != nil {
+		rule.Priority = *r.Priority
+	}
+	if r.IsActive != nil {
+		rule.IsActive = *r.IsActive
+	}
+}
+
+func (cc *ChatbotController) fail(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case err == services.ErrFlowTaken:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrInvalidTransition, err == services.ErrBotNeedsUnassigned, err == services.ErrStatusChanged:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrRuleInvalid, err == services.ErrRulePattern, err == services.ErrFlowKey,
+		err == services.ErrFlowInactive, err == services.ErrInvalidControl, strings.HasPrefix(err.Error(), "flow "):
+		// ParseFlow errors say which step is wrong
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save chatbot settings"})
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CollisionController struct {
+	db         *gorm.DB
+	collisions *services.CollisionService
+}
+
+func NewCollisionController(db *gorm.DB, collisions *services.CollisionService) *CollisionController {
+	return &CollisionController{db: db, collisions: collisions}
+}
+
+// Viewers lists the agents who have the conversation open and who of them is typing
+func (cc *CollisionController) Viewers(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid conversation id"})
+	}
+	viewers, err := cc.collisions.Viewers(c.Context(), id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch viewers"})
+	}
+	return c.JSON(fiber.Map{"conversation_id": id, "viewers": viewers})
+}
+
+// Activity reports the caller's activity for clients without a WebSocket:
+// POST /conversations/:id/activity {"activity":"viewing|typing|idle|left"}
+func (cc *CollisionController) Activity(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid conversation id"})
+	}
+	var req struct {
+		Activity string `json:"activity"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	user := c.Locals("user").(*models.User)
+	ok, err := cc.apply(c.Context(), id, user, req.Activity)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "activity must be viewing, typing, idle or left"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record activity"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (cc *CollisionController) apply(ctx context.Context, conversationID uuid.UUID, user *models.User, activity string) (bool, error) {
+	switch activity {
+	case services.ActivityViewing:
+		return true, cc.collisions.View(ctx, conversationID, user)
+	case services.ActivityTyping:
+		return true, cc.collisions.Typing(ctx, conversationID, user, true)
+	case services.ActivityIdle:
+		return true, cc.collisions.Typing(ctx, conversationID, user, false)
+	case services.ActivityLeft:
+		return true, cc.collisions.Leave(ctx, conversationID, user)
+	}
+	return false, nil
+}
+
+// SoftLock holds back a send from someone other than the assignee while the assignee
+// is typing, as COLLISION_LOCK says. The conversation comes from the route parameter
+// param, or from conversation_id in the JSON body when param is empty. In warn mode
+// the sender can go ahead with ?force=true.
+func (cc *CollisionController) SoftLock(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if cc.collisions.Lock() == services.CollisionLockOff {
+			return c.Next()
+		}
+		id := c.Params(param)
+		if param == "" {
+			var body struct {
+				ConversationID string `json:"conversation_id"`
+			}
+			_ = c.BodyParser(&body)
+			id = body.ConversationID
+		}
+		var conv models.Conversation
+		if err := cc.db.Select("id, agent_id").First(&conv, "id = ?", id).Error; err != nil {
+			// the handler reports a bad conversation
+			return c.Next()
+		}
+		user := c.Locals("user").(*models.User)
+		if err := cc.collisions.CheckSend(c.Context(), &conv, user, c.Query("force") == "true"); err == services.ErrAssigneeTyping {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":          err.Error(),
+				"lock":           cc.collisions.Lock(),
+				"assignee_id":    conv.AgentID,
+				"can_force_send": cc.collisions.Lock() == services.CollisionLockWarn,
+			})
+		}
+		return c.Next()
+	}
+}
+
+type collisionFrame struct {
+	Type           string    `json:"type"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+}
+
+// Stream is the collision WebSocket. Clients send {"type":"view","conversation_id":...}
+// when they open a conversation and again before the viewing TTL lapses,
+// {"type":"typing",...} while typing, {"type":"stop_typing",...} and {"type":"leave",...}.
+// Viewing a conversation returns {"type":"viewers",...} and then pushes
+// {"type":"collision",...} whenever another agent starts or stops viewing or typing in it.
+// Closing the socket leaves every conversation it viewed.
+func (cc *CollisionController) Stream() fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		user := conn.Locals("user").(*models.User)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var writeMu sync.Mutex
+		write := func(v interface{}) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			return conn.WriteJSON(v)
+		}
+
+		var viewingMu sync.RWMutex
+		viewing := map[uuid.UUID]bool{}
+		defer func() {
+			for id := range viewing {
+				_ = cc.collisions.Leave(context.Background(), id, user)
+			}
+		}()
+
+		changes := cc.collisions.Subscribe(ctx)
+		go func() {
+			for change := range changes {
+				viewingMu.RLock()
+				watched := viewing[change.ConversationID]
+				viewingMu.RUnlock()
+				if !watched || change.UserID == user.ID {
+					continue
+				}
+				if err := write(fiber.Map{"type": "collision", "change": change}); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+
+		for {
+			var frame collisionFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+			if frame.ConversationID == uuid.Nil {
+				_ = write(fiber.Map{"type": "error", "error": "conversation_id is required"})
+				continue
+			}
+			activity := map[string]string{
+				"view":        services.ActivityViewing,
+				"typing":      services.ActivityTyping,
+				"stop_typing": services.ActivityIdle,
+				"leave":       services.ActivityLeft,
+			}[frame.Type]
+			if activity == "" {
+				_ = write(fiber.Map{"type": "error", "error": "type must be view, typing, stop_typing or leave"})
+				continue
+			}
+
+			viewingMu.Lock()
+			first := !viewing[frame.ConversationID] && activity != services.ActivityLeft
+			if activity == services.ActivityLeft {
+				delete(viewing, frame.ConversationID)
+			} else {
+				viewing[frame.ConversationID] = true
+			}
+			viewingMu.Unlock()
+
+			if _, err := cc.apply(ctx, frame.ConversationID, user, activity); err != nil {
+				_ = write(fiber.Map{"type": "error", "error": "Failed to record activity"})
+				continue
+			}
+			if first {
+				viewers, err := cc.collisions.Viewers(ctx, frame.ConversationID)
+				if err == nil {
+					if err := write(fiber.Map{"type": "viewers", "conversation_id": frame.ConversationID, "viewers": viewers}); err != nil {
+						return
+					}
+				}
+			}
+		}
+	})
+}
@@ -1,7 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"strconv"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
 	"whatsapp-crm/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,16 +14,60 @@ import (
 	"gorm.io/gorm"
 )
 
-type ConversationController struct { db *gorm.DB; csv *services.ConversationService; ms *services.MessageService }
+type ConversationController struct { db *gorm.DB; csv *services.ConversationService; ms *services.MessageService; ts *services.TeamService; cfg *config.Config }
 
-func NewConversationController(db *gorm.DB, csv *services.ConversationService, ms *services.MessageService) *ConversationController { return &ConversationController{db: db, csv: csv, ms: ms} }
+func NewConversationController(db *gorm.DB, csv *services.ConversationService, ms *services.MessageService, ts *services.TeamService, cfg *config.Config) *ConversationController { return &ConversationController{db: db, csv: csv, ms: ms, ts: ts, cfg: cfg} }
 
+// GET /api/v1/conversations — filters: status, priority (comma lists), agent_id (uuid, "mine" or "unassigned"),
+// team_id, tag, unread=true, controlled_by=bot|human, snoozed=only|all (hidden by default), from/to (YYYY-MM-DD, created date); sort=last_message|priority, order=asc|desc; cursor, limit
 func (cc *ConversationController) List(c *fiber.Ctx) error {
-	// simplified list by recent
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
-	var convs []struct{ ID string; CustomerID string; Status string; UpdatedAt string }
-	cc.db.Table("conversations").Select("id, customer_id, status, updated_at").Order("updated_at desc").Limit(limit).Scan(&convs)
-	return c.JSON(fiber.Map{"conversations": convs})
+	f := services.ConversationFilter{Sort: services.ConversationSort(c.Query("sort", string(services.SortLastMessage))), Ascending: c.Query("order") == "asc", Cursor: c.Query("cursor"), UnreadOnly: c.Query("unread") == "true"}
+	f.Limit, _ = strconv.Atoi(c.Query("limit", "20"))
+	f.Viewer = c.Locals("user").(*models.User).ID
+	if f.Sort != services.SortLastMessage && f.Sort != services.SortPriority { return c.Status(400).JSON(fiber.Map{"error":"sort must be last_message or priority"}) }
+	for _, s := range splitQuery(c.Query("status")) { f.Statuses = append(f.Statuses, models.ConversationStatus(s)) }
+	for _, p := range splitQuery(c.Query("priority")) { f.Priorities = append(f.Priorities, models.ConversationPriority(p)) }
+	switch agent := c.Query("agent_id"); agent {
+	case "":
+	case "unassigned":
+		f.Unassigned = true
+	case "mine":
+		user := c.Locals("user").(*models.User)
+		f.AgentID = &user.ID
+	default:
+		id, err := uuid.Parse(agent); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid agent_id"}) }
+		f.AgentID = &id
+	}
+	if v := c.Query("team_id"); v != "" {
+		id, err := uuid.Parse(v); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid team_id"}) }
+		f.TeamID = &id
+	}
+	f.Tag = c.Query("tag")
+	f.Control = models.ConversationControl(c.Query("controlled_by"))
+	f.Snoozed = services.ConversationSnoozed(c.Query("snoozed"))
+	if f.Snoozed != services.SnoozedHidden && f.Snoozed != services.SnoozedOnly && f.Snoozed != services.SnoozedInclude { return c.Status(400).JSON(fiber.Map{"error":"snoozed must be only or all"}) }
+	if v := c.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid from date"}) }
+		f.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid to date"}) }
+		t = t.AddDate(0, 0, 1)
+		f.To = &t
+	}
+	page, err := cc.csv.List(f)
+	if err == services.ErrInvalidCursor { return c.Status(400).JSON(fiber.Map{"error": err.Error()}) }
+	if err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to fetch conversations"}) }
+	return c.JSON(page)
+}
+
+// splitQuery splits a comma separated query value, dropping blanks
+func splitQuery(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" { out = append(out, s) }
+	}
+	return out
 }
 
 func (cc *ConversationController) Create(c *fiber.Ctx) error {
@@ -31,17 +80,46 @@ func (cc *ConversationController) Create(c *fiber.Ctx) error {
 
 func (cc *ConversationController) Detail(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
-	var conv interface{}
-	if err := cc.db.Preload("Customer").Preload("Agent").Preload("Messages").First(&conv, "id = ?", id).Error; err != nil { return c.Status(404).JSON(fiber.Map{"error":"Not found"}) }
+	var conv models.Conversation
+	if err := cc.db.Preload("Customer").Preload("Agent").Preload("Tags").Preload("Messages").First(&conv, "id = ?", id).Error; err != nil { return c.Status(404).JSON(fiber.Map{"error":"Not found"}) }
+	if conv.UnreadCount, err = cc.csv.UnreadCount(conv.ID, c.Locals("user").(*models.User).ID); err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to count unread messages"}) }
 	return c.JSON(conv)
 }
 
+// MarkRead moves the caller's read cursor: POST /conversations/:id/read {"message_id":"...","receipt":true}.
+// Without message_id everything is read; receipt (default READ_RECEIPTS) sends the customer blue ticks.
+func (cc *ConversationController) MarkRead(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
+	var req struct{ MessageID *uuid.UUID `json:"message_id"`; Receipt *bool `json:"receipt"` }
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid body"}) }
+	}
+	user := c.Locals("user").(*models.User)
+	read, err := cc.ms.MarkRead(id, user.ID, req.MessageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) { return c.Status(404).JSON(fiber.Map{"error":"Not found"}) }
+	if err == services.ErrReadMessage { return c.Status(400).JSON(fiber.Map{"error": err.Error()}) }
+	if err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to mark read"}) }
+
+	resp := fiber.Map{"read": read}
+	if req.Receipt == nil && cc.cfg.ReadReceipts || req.Receipt != nil && *req.Receipt {
+		// the cursor is saved either way; a failed receipt is reported, not fatal
+		n, err := cc.ms.SendReadReceipt(id, read.LastReadAt)
+		resp["receipts"] = n
+		if err != nil { resp["receipt_error"] = err.Error() }
+	}
+	return c.JSON(resp)
+}
+
 func (cc *ConversationController) Assign(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
 	var req struct{ AgentID string `json:"agent_id"` }
 	if err := c.BodyParser(&req); err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid body"}) }
 	aid, err := uuid.Parse(req.AgentID); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid agent_id"}) }
-	if err := cc.csv.Assign(id, aid); err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to assign"}) }
+	user := c.Locals("user").(*models.User)
+	if err := cc.csv.Assign(id, aid, services.UserActor(user.ID)); err != nil {
+		if err == services.ErrInvalidTransition { return c.Status(409).JSON(fiber.Map{"error":"Closed conversations must be reopened before assigning"}) }
+		return c.Status(500).JSON(fiber.Map{"error":"Failed to assign"})
+	}
 	return c.JSON(fiber.Map{"message":"Assigned"})
 }
 
@@ -49,7 +127,18 @@ func (cc *ConversationController) UpdateStatus(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
 	var req struct{ Status string `json:"status"` }
 	if err := c.BodyParser(&req); err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid body"}) }
-	if err := cc.db.Model(&struct{ }{}).Table("conversations").Where("id = ?", id).Update("status", req.Status).Error; err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to update"}) }
+	user := c.Locals("user").(*models.User)
+	if err := cc.csv.Transition(id, models.ConversationStatus(req.Status), services.UserActor(user.ID)); err != nil {
+		switch err {
+		case services.ErrInvalidTransition, services.ErrNoAgent:
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		case services.ErrStatusChanged:
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		case gorm.ErrRecordNotFound:
+			return c.Status(404).JSON(fiber.Map{"error":"Not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error":"Failed to update"})
+	}
 	return c.JSON(fiber.Map{"message":"Updated"})
 }
 
@@ -57,14 +146,203 @@ func (cc *ConversationController) UpdatePriority(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
 	var req struct{ Priority string `json:"priority"` }
 	if err := c.BodyParser(&req); err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid body"}) }
-	if err := cc.db.Table("conversations").Where("id = ?", id).Update("priority", req.Priority).Error; err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to update"}) }
+	user := c.Locals("user").(*models.User)
+	if err := cc.csv.UpdatePriority(id, models.ConversationPriority(req.Priority), services.UserActor(user.ID)); err != nil {
+		if err == services.ErrInvalidPriority { return c.Status(400).JSON(fiber.Map{"error": err.Error()}) }
+		return c.Status(500).JSON(fiber.Map{"error":"Failed to update"})
+	}
 	return c.JSON(fiber.Map{"message":"Updated"})
 }
 
-func (cc *ConversationController) UpdateNotes(c *fiber.Ctx) error {
+// POST /api/v1/conversations/:id/tags — body {"tag_id": "..."}
+func (cc *ConversationController) AddTag(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
+	var req struct{ TagID uuid.UUID `json:"tag_id"` }
+	if err := c.BodyParser(&req); err != nil || req.TagID == uuid.Nil { return c.Status(400).JSON(fiber.Map{"error":"tag_id is required"}) }
+	user := c.Locals("user").(*models.User)
+	return cc.tagResponse(c, cc.csv.AddTag(id, req.TagID, services.UserActor(user.ID)))
+}
+
+// DELETE /api/v1/conversations/:id/tags/:tagId
+func (cc *ConversationController) RemoveTag(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
+	tagID, err := uuid.Parse(c.Params("tagId")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid tag ID"}) }
+	user := c.Locals("user").(*models.User)
+	return cc.tagResponse(c, cc.csv.RemoveTag(id, tagID, services.UserActor(user.ID)))
+}
+
+func (cc *ConversationController) tagResponse(c *fiber.Ctx, err error) error {
+	switch err {
+	case nil:
+		return c.JSON(fiber.Map{"message":"Updated"})
+	case gorm.ErrRecordNotFound:
+		return c.Status(404).JSON(fiber.Map{"error":"Not found"})
+	case services.ErrTagScope:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error":"Failed to update"})
+}
+
+// GET /api/v1/conversations/:id/timeline — history events, messages and internal notes in chronological order
+func (cc *ConversationController) Timeline(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
+	var n int64
+	cc.db.Model(&models.Conversation{}).Where("id = ?", id).Count(&n)
+	if n == 0 { return c.Status(404).JSON(fiber.Map{"error":"Not found"}) }
+	items, err := cc.csv.Timeline(id)
+	if err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to load timeline"}) }
+	return c.JSON(fiber.Map{"timeline": items})
+}
+
+// GET /api/v1/conversations/queue — unassigned conversations waiting for an available agent
+func (cc *ConversationController) Queue(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	var teamID *uuid.UUID
+	if v := c.Query("team_id"); v != "" {
+		tid, err := uuid.Parse(v); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid team_id"}) }
+		teamID = &tid
+	}
+	convs, err := cc.csv.Queue(limit, teamID)
+	if err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to fetch queue"}) }
+	return c.JSON(fiber.Map{"conversations": convs, "total": len(convs)})
+}
+
+// POST /api/v1/conversations/:id/route — run automatic routing for an unassigned conversation
+func (cc *ConversationController) Route(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
+	var conv models.Conversation
+	if err := cc.db.First(&conv, "id = ?", id).Error; err != nil { return c.Status(404).JSON(fiber.Map{"error":"Not found"}) }
+	if conv.AgentID != nil { return c.Status(409).JSON(fiber.Map{"error":"Conversation is already assigned"}) }
+	cc.csv.AutoRoute(&conv, services.RoutingHints{})
+	return c.JSON(conv)
+}
+
+// POST /api/v1/conversations/:id/pickup — take an unassigned conversation from a (team) queue
+func (cc *ConversationController) PickUp(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
-	var req struct{ Notes string `json:"notes"` }
+	user := c.Locals("user").(*models.User)
+	var conv models.Conversation
+	if err := cc.db.First(&conv, "id = ?", id).Error; err != nil { return c.Status(404).JSON(fiber.Map{"error":"Not found"}) }
+	if conv.TeamID != nil && user.Role == models.RoleAgent && !cc.ts.IsMember(*conv.TeamID, user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error":"Only members of the team can pick up this conversation"})
+	}
+	if err := cc.csv.PickUp(id, user.ID); err != nil {
+		if err == services.ErrAlreadyAssigned { return c.Status(409).JSON(fiber.Map{"error": err.Error()}) }
+		return c.Status(500).JSON(fiber.Map{"error":"Failed to pick up"})
+	}
+	return c.JSON(fiber.Map{"message":"Assigned"})
+}
+
+// POST /api/v1/conversations/:id/transfer — hand off to another agent (who must accept) or team, with a note
+func (cc *ConversationController) Transfer(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
+	var req struct{ AgentID string `json:"agent_id"`; TeamID string `json:"team_id"`; Note string `json:"note"` }
 	if err := c.BodyParser(&req); err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid body"}) }
-	if err := cc.db.Table("conversations").Where("id = ?", id).Update("notes", req.Notes).Error; err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to update"}) }
-	return c.JSON(fiber.Map{"message":"Updated"})
+	user := c.Locals("user").(*models.User)
+
+	var conv models.Conversation
+	if err := cc.db.Select("id, agent_id").First(&conv, "id = ?", id).Error; err != nil { return c.Status(404).JSON(fiber.Map{"error":"Not found"}) }
+	if user.Role == models.RoleAgent && (conv.AgentID == nil || *conv.AgentID != user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error":"Agents can only transfer their own conversations"})
+	}
+
+	tr := services.TransferRequest{Note: strings.TrimSpace(req.Note)}
+	if req.AgentID != "" {
+		aid, err := uuid.Parse(req.AgentID); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid agent_id"}) }
+		var agent models.User
+		if err := cc.db.Select("id").First(&agent, "id = ? AND role IN ? AND status <> ?", aid, []models.UserRole{models.RoleAgent, models.RoleSupervisor}, models.UserStatusInactive).Error; err != nil {
+			return c.Status(400).JSON(fiber.Map{"error":"agent_id must reference an active agent"})
+		}
+		tr.ToAgentID = &aid
+	}
+	if req.TeamID != "" {
+		tid, err := uuid.Parse(req.TeamID); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid team_id"}) }
+		var team models.Team
+		if err := cc.db.Select("id").First(&team, "id = ? AND is_active = ?", tid, true).Error; err != nil {
+			return c.Status(400).JSON(fiber.Map{"error":"team_id must reference an active team"})
+		}
+		tr.ToTeamID = &tid
+	}
+
+	transfer, err := cc.csv.Transfer(id, tr, user.ID)
+	switch err {
+	case nil:
+		return c.Status(201).JSON(transfer)
+	case services.ErrHandoffNoteRequired, services.ErrTransferTarget, services.ErrSameAgent, services.ErrInvalidTransition:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrTransferPending:
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error":"Failed to transfer"})
+}
+
+// GET /api/v1/conversations/transfers — pending handoffs for the caller (all of them for supervisors/admins)
+func (cc *ConversationController) PendingTransfers(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	var agentID *uuid.UUID
+	if user.Role == models.RoleAgent || c.Query("mine") == "true" { agentID = &user.ID }
+	transfers, err := cc.csv.PendingTransfers(agentID)
+	if err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to fetch transfers"}) }
+	return c.JSON(fiber.Map{"transfers": transfers})
+}
+
+// POST /api/v1/conversations/transfers/:transferId/accept
+func (cc *ConversationController) AcceptTransfer(c *fiber.Ctx) error {
+	tid, err := uuid.Parse(c.Params("transferId")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid transfer ID"}) }
+	user := c.Locals("user").(*models.User)
+	transfer, err := cc.csv.AcceptTransfer(tid, user.ID)
+	return cc.transferResponse(c, transfer, err)
+}
+
+// POST /api/v1/conversations/transfers/:transferId/decline — the conversation goes back to routing
+func (cc *ConversationController) DeclineTransfer(c *fiber.Ctx) error {
+	tid, err := uuid.Parse(c.Params("transferId")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid transfer ID"}) }
+	var req struct{ Reason string `json:"reason"` }
+	_ = c.BodyParser(&req)
+	user := c.Locals("user").(*models.User)
+	transfer, err := cc.csv.DeclineTransfer(tid, user.ID, req.Reason)
+	return cc.transferResponse(c, transfer, err)
+}
+
+// POST /api/v1/conversations/:id/snooze — body {"until": RFC3339}; without until it sleeps until the customer replies
+func (cc *ConversationController) Snooze(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
+	var req struct{ Until *time.Time `json:"until"` }
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid body"}) }
+	}
+	user := c.Locals("user").(*models.User)
+	switch err := cc.csv.Snooze(id, req.Until, services.UserActor(user.ID)); err {
+	case nil:
+		return c.JSON(fiber.Map{"message":"Snoozed"})
+	case gorm.ErrRecordNotFound:
+		return c.Status(404).JSON(fiber.Map{"error":"Not found"})
+	case services.ErrSnoozeInPast:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrInvalidTransition:
+		return c.Status(409).JSON(fiber.Map{"error":"Closed conversations cannot be snoozed"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error":"Failed to snooze"})
+}
+
+// DELETE /api/v1/conversations/:id/snooze — wake a snoozed conversation now
+func (cc *ConversationController) Unsnooze(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid ID"}) }
+	var conv models.Conversation
+	if err := cc.db.First(&conv, "id = ?", id).Error; err != nil { return c.Status(404).JSON(fiber.Map{"error":"Not found"}) }
+	user := c.Locals("user").(*models.User)
+	if err := cc.csv.Unsnooze(&conv, services.UserActor(user.ID)); err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to unsnooze"}) }
+	return c.JSON(fiber.Map{"message":"Unsnoozed"})
+}
+
+func (cc *ConversationController) transferResponse(c *fiber.Ctx, transfer *models.ConversationTransfer, err error) error {
+	switch err {
+	case nil:
+		return c.JSON(transfer)
+	case gorm.ErrRecordNotFound:
+		return c.Status(404).JSON(fiber.Map{"error":"Transfer not found"})
+	case services.ErrTransferNotPending, services.ErrInvalidTransition:
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error":"Failed to update transfer"})
 }
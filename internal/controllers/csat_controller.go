@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CSATController struct {
+	csat *services.CSATService
+}
+
+func NewCSATController(csat *services.CSATService) *CSATController {
+	return &CSATController{csat: csat}
+}
+
+// queryUUID reads an optional uuid query parameter
+func queryUUID(c *fiber.Ctx, key string) (*uuid.UUID, bool) {
+	v := c.Query(key)
+	if v == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return nil, false
+	}
+	return &id, true
+}
+
+// Report returns CSAT scores:
+// GET /csat/report?from=2024-01-01&to=2024-01-31&group_by=agent|team|day|week|month&agent_id=&team_id=
+func (cc *CSATController) Report(c *fiber.Ctx) error {
+	var f services.CSATFilter
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from date"})
+		}
+		f.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to date"})
+		}
+		t = t.AddDate(0, 0, 1)
+		f.To = &t
+	}
+	var ok bool
+	if f.AgentID, ok = queryUUID(c, "agent_id"); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid agent_id"})
+	}
+	if f.TeamID, ok = queryUUID(c, "team_id"); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid team_id"})
+	}
+
+	rows, total, err := cc.csat.Report(f, c.Query("group_by", "agent"))
+	if err == services.ErrCSATGroup {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build report"})
+	}
+	return c.JSON(fiber.Map{"rows": rows, "total": total})
+}
+
+// Surveys lists individual survey responses, optionally for a conversation or agent
+func (cc *CSATController) Surveys(c *fiber.Ctx) error {
+	conversationID, ok := queryUUID(c, "conversation_id")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid conversation_id"})
+	}
+	agentID, ok := queryUUID(c, "agent_id")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid agent_id"})
+	}
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	surveys, total, err := cc.csat.Surveys(conversationID, agentID, (page-1)*limit, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch surveys"})
+	}
+	return c.JSON(fiber.Map{
+		"surveys": surveys,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
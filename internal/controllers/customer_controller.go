@@ -23,7 +23,7 @@ func (cc *CustomerController) List(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
 	search := c.Query("search")
 
-	customers, total, err := cc.csv.GetCustomers(page, limit, search)
+	customers, total, err := cc.csv.GetCustomers(page, limit, search, c.Query("tag"))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch customers"})
 	}
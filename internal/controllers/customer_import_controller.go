@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CustomerImportController struct {
+	imports   *services.CustomerImportService
+	customers *services.CustomerService
+}
+
+func NewCustomerImportController(imports *services.CustomerImportService, customers *services.CustomerService) *CustomerImportController {
+	return &CustomerImportController{imports: imports, customers: customers}
+}
+
+// Upload reads a customer spreadsheet into a draft import and suggests a column mapping:
+// POST /customers/imports multipart file=<.csv|.xlsx>
+func (ic *CustomerImportController) Upload(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file is required"})
+	}
+	user := c.Locals("user").(*models.User)
+	upload, err := ic.imports.Upload(file, user.ID)
+	if err != nil {
+		return ic.fail(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(upload)
+}
+
+// Preview dry-runs a draft import and keeps its settings:
+// POST /customers/imports/:id/preview {"mapping":{"name":"Nama","phone":"No HP",...},"on_match":"update|skip","on_new":"create|skip"}
+func (ic *CustomerImportController) Preview(c *fiber.Ctx) error {
+	job, ok := ic.job(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Import not found"})
+	}
+	var req struct {
+		Mapping map[string]string `json:"mapping"`
+		OnMatch string            `json:"on_match"`
+		OnNew   string            `json:"on_new"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Mapping == nil {
+		_, req.Mapping = ic.imports.Mapping(job)
+	}
+	job, preview, err := ic.imports.Preview(job.ID, req.Mapping, req.OnMatch, req.OnNew, 100)
+	if err != nil {
+		return ic.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"import": job, "mapping": req.Mapping, "preview": preview})
+}
+
+// Start queues a previewed import: POST /customers/imports/:id/start
+func (ic *CustomerImportController) Start(c *fiber.Ctx) error {
+	job, ok := ic.job(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Import not found"})
+	}
+	job, err := ic.imports.Start(job.ID)
+	if err != nil {
+		return ic.fail(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// Jobs lists the caller's imports; admins see all of them
+func (ic *CustomerImportController) Jobs(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	var owner *uuid.UUID
+	if user.Role != models.RoleAdmin {
+		owner = &user.ID
+	}
+	jobs, err := ic.imports.Jobs(owner)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch imports"})
+	}
+	return c.JSON(fiber.Map{"imports": jobs})
+}
+
+// Job shows an import's mapping and progress, with a link to the failed rows once it is completed
+func (ic *CustomerImportController) Job(c *fiber.Ctx) error {
+	job, ok := ic.job(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Import not found"})
+	}
+	headers, mapping := ic.imports.Mapping(job)
+	result := fiber.Map{"import": job, "headers": headers, "mapping": mapping}
+	if job.Status == models.CustomerImportCompleted {
+		url, err := ic.imports.ErrorReportURL(c.Context(), job)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to sign error report link"})
+		}
+		if url != "" {
+			result["error_report_url"] = url
+		}
+	}
+	return c.JSON(result)
+}
+
+// Export downloads the filtered customer list: GET /customers/export?format=csv|xlsx&search=&tag=
+func (ic *CustomerImportController) Export(c *fiber.Ctx) error {
+	format := c.Query("format", "csv")
+	contentType, ok := services.CustomerExportFormats[format]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": services.ErrCustomerExportFormat.Error()})
+	}
+	var buf bytes.Buffer
+	if err := ic.customers.Export(&buf, format, c.Query("search"), c.Query("tag")); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export customers"})
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="customers-%s.%s"`, time.Now().Format("2006-01-02"), format))
+	return c.Send(buf.Bytes())
+}
+
+// job loads the import in :id if the caller may see it
+func (ic *CustomerImportController) job(c *fiber.Ctx) (*models.CustomerImportJob, bool) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, false
+	}
+	job, err := ic.imports.Job(id)
+	user := c.Locals("user").(*models.User)
+	if err != nil || (job.RequestedBy != user.ID && user.Role != models.RoleAdmin) {
+		return nil, false
+	}
+	return job, true
+}
+
+func (ic *CustomerImportController) fail(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Import not found"})
+	case err == services.ErrCustomerImportStarted:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrCustomerFile, err == services.ErrCustomerImportEmpty, err == services.ErrCustomerImportTooLarge,
+		err == services.ErrCustomerImportOptions, err == services.ErrCustomerImportNotReady, errors.Is(err, services.ErrCustomerImportMapping):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to import customers"})
+}
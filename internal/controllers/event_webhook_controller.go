@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EventWebhookController struct {
+	db     *gorm.DB
+	events *services.EventDispatcher
+}
+
+func NewEventWebhookController(db *gorm.DB, events *services.EventDispatcher) *EventWebhookController {
+	return &EventWebhookController{db: db, events: events}
+}
+
+type eventWebhookRequest struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Events   []string `json:"events"`
+	Secret   string   `json:"secret"`
+	IsActive *bool    `json:"is_active"`
+}
+
+// validateEvents checks every requested event is known and joins them for storage
+func validateEvents(events []string) (string, bool) {
+	if len(events) == 0 {
+		return "", false
+	}
+	for _, e := range events {
+		if e == "*" {
+			continue
+		}
+		known := false
+		for _, t := range models.AllEventTypes {
+			if string(t) == e {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return "", false
+		}
+	}
+	return strings.Join(events, ","), true
+}
+
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ListEventTypes returns the events a subscription can listen to
+func (ec *EventWebhookController) ListEventTypes(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"events": models.AllEventTypes})
+}
+
+// List returns all outbound webhook subscriptions (secrets hidden)
+func (ec *EventWebhookController) List(c *fiber.Ctx) error {
+	var subs []models.WebhookSubscription
+	if err := ec.db.Order("created_at desc").Find(&subs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch webhooks"})
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return c.JSON(fiber.Map{"webhooks": subs})
+}
+
+// Create registers a new subscription; the secret is only returned here
+func (ec *EventWebhookController) Create(c *fiber.Ctx) error {
+	var req eventWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Name == "" || !validWebhookURL(req.URL) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name and a valid http(s) url are required"})
+	}
+	events, ok := validateEvents(req.Events)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "events must list known event types"})
+	}
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate secret"})
+		}
+	}
+
+	user := c.Locals("user").(*models.User)
+	sub := models.WebhookSubscription{
+		Name:      req.Name,
+		URL:       req.URL,
+		Secret:    secret,
+		Events:    events,
+		IsActive:  true,
+		CreatedBy: user.ID,
+	}
+	if err := ec.db.Create(&sub).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create webhook"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(sub)
+}
+
+// Detail returns one subscription (secret hidden)
+func (ec *EventWebhookController) Detail(c *fiber.Ctx) error {
+	sub, err := ec.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+	}
+	sub.Secret = ""
+	return c.JSON(sub)
+}
+
+// Update edits a subscription; re-activating clears the failure counter
+func (ec *EventWebhookController) Update(c *fiber.Ctx) error {
+	sub, err := ec.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+	}
+	var req eventWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if req.Name != "" {
+		sub.Name = req.Name
+	}
+	if req.URL != "" {
+		if !validWebhookURL(req.URL) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "url must be a valid http(s) url"})
+		}
+		sub.URL = req.URL
+	}
+	if req.Events != nil {
+		events, ok := validateEvents(req.Events)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "events must list known event types"})
+		}
+		sub.Events = events
+	}
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+	if req.IsActive != nil {
+		if *req.IsActive && !sub.IsActive {
+			sub.ConsecutiveFailures = 0
+			sub.DisabledAt = nil
+		}
+		sub.IsActive = *req.IsActive
+	}
+
+	if err := ec.db.Save(sub).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update webhook"})
+	}
+	sub.Secret = ""
+	return c.JSON(sub)
+}
+
+// Delete removes a subscription and its delivery log
+func (ec *EventWebhookController) Delete(c *fiber.Ctx) error {
+	sub, err := ec.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+	}
+	err = ec.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", sub.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(sub).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete webhook"})
+	}
+	return c.JSON(fiber.Map{"message": "Webhook deleted successfully"})
+}
+
+// Deliveries returns the delivery log of a subscription, newest first
+func (ec *EventWebhookController) Deliveries(c *fiber.Ctx) error {
+	sub, err := ec.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+	}
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := ec.db.Model(&models.WebhookDelivery{}).Where("subscription_id = ?", sub.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event_type = ?", event)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at desc").Offset((page - 1) * limit).Limit(limit).Find(&deliveries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch deliveries"})
+	}
+
+	return c.JSON(fiber.Map{
+		"deliveries": deliveries,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// Redeliver sends a stored delivery again and returns the new attempt
+func (ec *EventWebhookController) Redeliver(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("deliveryId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid delivery ID"})
+	}
+	delivery, err := ec.events.Redeliver(id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Delivery not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to redeliver"})
+	}
+	return c.JSON(delivery)
+}
+
+func (ec *EventWebhookController) find(rawID string) (*models.WebhookSubscription, error) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return nil, err
+	}
+	var sub models.WebhookSubscription
+	if err := ec.db.First(&sub, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ExportController struct {
+	transcripts *services.TranscriptService
+}
+
+func NewExportController(transcripts *services.TranscriptService) *ExportController {
+	return &ExportController{transcripts: transcripts}
+}
+
+// Export downloads one conversation: GET /conversations/:id/export?format=txt|html|json|pdf&include_notes=true
+func (ec *ExportController) Export(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Conversation not found"})
+	}
+	format := c.Query("format", "txt")
+	contentType, ok := services.ExportFormats[format]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": services.ErrExportFormat.Error()})
+	}
+	t, err := ec.transcripts.Build(c.Context(), id, c.QueryBool("include_notes"))
+	if err != nil {
+		return ec.fail(c, err)
+	}
+	var buf bytes.Buffer
+	if err := ec.transcripts.Render(&buf, t, format); err != nil {
+		return ec.fail(c, err)
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="conversation-%s.%s"`, id, format))
+	return c.Send(buf.Bytes())
+}
+
+// BulkExport queues a zip of many transcripts:
+// POST /conversations/exports {"conversation_ids":[...]} or {"from":"YYYY-MM-DD","to":"YYYY-MM-DD","team_id":"..."}, plus "format" and "include_notes"
+func (ec *ExportController) BulkExport(c *fiber.Ctx) error {
+	var req struct {
+		ConversationIDs []uuid.UUID `json:"conversation_ids"`
+		From            string      `json:"from"`
+		To              string      `json:"to"`
+		TeamID          *uuid.UUID  `json:"team_id"`
+		Format          string      `json:"format"`
+		IncludeNotes    bool        `json:"include_notes"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	bulk := services.BulkExportRequest{ConversationIDs: req.ConversationIDs, TeamID: req.TeamID, Format: req.Format, IncludeNotes: req.IncludeNotes}
+	if bulk.Format == "" {
+		bulk.Format = "txt"
+	}
+	if req.From != "" {
+		t, err := time.Parse("2006-01-02", req.From)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from date"})
+		}
+		bulk.From = &t
+	}
+	if req.To != "" {
+		t, err := time.Parse("2006-01-02", req.To)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to date"})
+		}
+		t = t.AddDate(0, 0, 1)
+		bulk.To = &t
+	}
+	user := c.Locals("user").(*models.User)
+	job, err := ec.transcripts.QueueExport(bulk, user.ID)
+	if err != nil {
+		return ec.fail(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// Jobs lists the caller's bulk exports; admins see all of them
+func (ec *ExportController) Jobs(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	var owner *uuid.UUID
+	if user.Role != models.RoleAdmin {
+		owner = &user.ID
+	}
+	jobs, err := ec.transcripts.Jobs(owner)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch exports"})
+	}
+	return c.JSON(fiber.Map{"exports": jobs})
+}
+
+// Job shows a bulk export's progress, with a download link once it is completed
+func (ec *ExportController) Job(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("jobId"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Export not found"})
+	}
+	job, err := ec.transcripts.Job(id)
+	user := c.Locals("user").(*models.User)
+	if err != nil || (job.RequestedBy != user.ID && user.Role != models.RoleAdmin) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Export not found"})
+	}
+	result := fiber.Map{"export": job}
+	if job.Status == models.ExportJobCompleted {
+		url, err := ec.transcripts.DownloadURL(c.Context(), job)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to sign download link"})
+		}
+		result["download_url"] = url
+	}
+	return c.JSON(result)
+}
+
+func (ec *ExportController) fail(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Conversation not found"})
+	case err == services.ErrExportFormat, err == services.ErrExportScope, err == services.ErrExportEmpty, err == services.ErrExportTooLarge:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export conversation"})
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
+	"whatsapp-crm/internal/models"
 	"whatsapp-crm/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,9 +13,9 @@ import (
 	"gorm.io/gorm"
 )
 
-type MessageController struct { db *gorm.DB; ms *services.MessageService }
+type MessageController struct { db *gorm.DB; ms *services.MessageService; canned *services.CannedResponseService }
 
-func NewMessageController(db *gorm.DB, ms *services.MessageService) *MessageController { return &MessageController{db: db, ms: ms} }
+func NewMessageController(db *gorm.DB, ms *services.MessageService, canned *services.CannedResponseService) *MessageController { return &MessageController{db: db, ms: ms, canned: canned} }
 
 func (mc *MessageController) ListByConversation(c *fiber.Ctx) error {
 	cid, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid conversation id"}) }
@@ -28,9 +30,16 @@ func (mc *MessageController) ListByConversation(c *fiber.Ctx) error {
 
 func (mc *MessageController) SendText(c *fiber.Ctx) error {
 	cid, err := uuid.Parse(c.Params("id")); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid conversation id"}) }
-	var req struct{ Content string `json:"content"` }
+	var req struct{ Content string `json:"content"`; CannedResponseID *uuid.UUID `json:"canned_response_id"` }
 	if err := c.BodyParser(&req); err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid body"}) }
-	msg, err := mc.ms.SendText(cid, req.Content); if err != nil { return c.Status(500).JSON(fiber.Map{"error": err.Error()}) }
+	if req.CannedResponseID != nil {
+		// text started from a canned response, possibly edited by the agent; another agent's personal ones are not found
+		user := c.Locals("user").(*models.User)
+		canned, err := mc.canned.Get(*req.CannedResponseID, user.ID); if err != nil { return c.Status(400).JSON(fiber.Map{"error":"Invalid canned_response_id"}) }
+		msg, err := mc.ms.SendCanned(cid, canned, req.Content, "", user); if err != nil { return c.Status(500).JSON(fiber.Map{"error": err.Error()}) }
+		return c.Status(201).JSON(msg)
+	}
+	msg, err := mc.ms.SendText(cid, req.Content, c.Locals("user").(*models.User)); if err != nil { return c.Status(500).JSON(fiber.Map{"error": err.Error()}) }
 	return c.Status(201).JSON(msg)
 }
 
@@ -50,7 +59,7 @@ func (mc *MessageController) SendMedia(c *fiber.Ctx) error {
 	}
 	if req.URL == "" { return c.Status(400).JSON(fiber.Map{"error":"url is required"}) }
 
-	msg, err := mc.ms.SendMediaMessage(cid, mediaType, req.URL, req.Caption, req.Filename)
+	msg, err := mc.ms.SendMediaMessage(cid, mediaType, req.URL, req.Caption, req.Filename, c.Locals("user").(*models.User))
 	if err != nil {
 		return c.Status(502).JSON(fiber.Map{"error": fmt.Sprintf("failed to send media: %v", err)})
 	}
@@ -69,9 +78,61 @@ func (mc *MessageController) SendTemplate(c *fiber.Ctx) error {
 	tid, err := uuid.Parse(req.TemplateID)
 	if err != nil { return c.Status(400).JSON(fiber.Map{"error":"invalid template_id"}) }
 
-	msg, err := mc.ms.SendTemplateMessage(cid, tid, req.Variables)
+	msg, err := mc.ms.SendTemplateMessage(cid, tid, req.Variables, c.Locals("user").(*models.User))
 	if err != nil {
 		return c.Status(502).JSON(fiber.Map{"error": fmt.Sprintf("failed to send template: %v", err)})
 	}
 	return c.Status(201).JSON(msg)
 }
+
+// GET /api/v1/messages/failed?from=2006-01-02&to=2006-01-02
+func (mc *MessageController) FailedReport(c *fiber.Ctx) error {
+	var from, to *time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil { return c.Status(400).JSON(fiber.Map{"error":"invalid from date"}) }
+		from = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil { return c.Status(400).JSON(fiber.Map{"error":"invalid to date"}) }
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	reasons, err := mc.ms.FailureReport(from, to)
+	if err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to build report"}) }
+	var total int64
+	for _, r := range reasons { total += r.Count }
+	return c.JSON(fiber.Map{"reasons": reasons, "total": total})
+}
+
+// GET /api/v1/messages/response-times?from=YYYY-MM-DD&to=YYYY-MM-DD&agent_id=&team_id=
+// First response, per-reply and resolution times (avg/median/p90) for conversations started in range
+func (mc *MessageController) ResponseTimes(c *fiber.Ctx) error {
+	var from, to *time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil { return c.Status(400).JSON(fiber.Map{"error":"invalid from date"}) }
+		from = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil { return c.Status(400).JSON(fiber.Map{"error":"invalid to date"}) }
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	var agentID, teamID *uuid.UUID
+	if v := c.Query("agent_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil { return c.Status(400).JSON(fiber.Map{"error":"invalid agent_id"}) }
+		agentID = &id
+	}
+	if v := c.Query("team_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil { return c.Status(400).JSON(fiber.Map{"error":"invalid team_id"}) }
+		teamID = &id
+	}
+	report, err := mc.ms.ResponseTimes(from, to, agentID, teamID)
+	if err != nil { return c.Status(500).JSON(fiber.Map{"error":"Failed to build report"}) }
+	return c.JSON(report)
+}
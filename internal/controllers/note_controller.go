@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NoteController struct {
+	notes *services.NoteService
+}
+
+func NewNoteController(notes *services.NoteService) *NoteController {
+	return &NoteController{notes: notes}
+}
+
+type noteRequest struct {
+	Body     string     `json:"body"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+// List returns a conversation's internal note threads
+func (nc *NoteController) List(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	notes, err := nc.notes.List(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch notes"})
+	}
+	return c.JSON(fiber.Map{"notes": notes})
+}
+
+// Create adds a note or, with parent_id, a reply. @mentioned users are notified.
+func (nc *NoteController) Create(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	var req noteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	user := c.Locals("user").(*models.User)
+	note, err := nc.notes.Create(id, user.ID, req.Body, req.ParentID)
+	if err != nil {
+		return nc.fail(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(note)
+}
+
+// Detail returns one note with its edit history
+func (nc *NoteController) Detail(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	note, err := nc.notes.Get(id)
+	if err != nil {
+		return nc.fail(c, err)
+	}
+	return c.JSON(note)
+}
+
+// Update edits the caller's own note
+func (nc *NoteController) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	var req noteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	user := c.Locals("user").(*models.User)
+	note, err := nc.notes.Update(id, user.ID, req.Body)
+	if err != nil {
+		return nc.fail(c, err)
+	}
+	return c.JSON(note)
+}
+
+// Delete removes a note and its replies; supervisors and admins may remove any note
+func (nc *NoteController) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	user := c.Locals("user").(*models.User)
+	moderator := user.Role == models.RoleAdmin || user.Role == models.RoleSupervisor
+	if err := nc.notes.Delete(id, user.ID, moderator); err != nil {
+		return nc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Note deleted successfully"})
+}
+
+func (nc *NoteController) fail(c *fiber.Ctx, err error) error {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case services.ErrEmptyNote:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrNotNoteAuthor:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save note"})
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+)
+
+type NotificationController struct {
+	ns *services.NotificationService
+}
+
+func NewNotificationController(ns *services.NotificationService) *NotificationController {
+	return &NotificationController{ns: ns}
+}
+
+// List returns the caller's notifications: GET /notifications?unread=true&page=&limit=
+func (nc *NotificationController) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	notifications, total, err := nc.ns.List(user.ID, c.Query("unread") == "true", (page-1)*limit, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch notifications"})
+	}
+	return c.JSON(fiber.Map{
+		"notifications": notifications,
+		"unread":        nc.ns.Unread(user.ID),
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// MarkRead marks one of the caller's notifications read
+func (nc *NotificationController) MarkRead(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	user := c.Locals("user").(*models.User)
+	if err := nc.ns.MarkRead(user.ID, &id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update notification"})
+	}
+	return c.JSON(fiber.Map{"message": "Notification marked as read"})
+}
+
+// MarkAllRead marks all of the caller's notifications read
+func (nc *NotificationController) MarkAllRead(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	if err := nc.ns.MarkRead(user.ID, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update notifications"})
+	}
+	return c.JSON(fiber.Map{"message": "Notifications marked as read"})
+}
+
+// Stream pushes {"type":"notification","notification":{...}} to the caller as
+// notifications arrive
+func (nc *NotificationController) Stream() fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		user := conn.Locals("user").(*models.User)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			// the client never needs to send anything; reading notices it going away
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+
+		if err := conn.WriteJSON(fiber.Map{"type": "unread", "count": nc.ns.Unread(user.ID)}); err != nil {
+			return
+		}
+		for n := range nc.ns.Subscribe(ctx, user.ID) {
+			if err := conn.WriteJSON(fiber.Map{"type": "notification", "notification": n}); err != nil {
+				return
+			}
+		}
+	})
+}
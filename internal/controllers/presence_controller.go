@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+type PresenceController struct {
+	ps *services.PresenceService
+}
+
+func NewPresenceController(ps *services.PresenceService) *PresenceController {
+	return &PresenceController{ps: ps}
+}
+
+func validPresence(s models.PresenceStatus) bool {
+	return s == models.PresenceAvailable || s == models.PresenceAway || s == models.PresenceOffline
+}
+
+// Me returns the caller's current presence
+func (pc *PresenceController) Me(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	return c.JSON(fiber.Map{"user_id": user.ID, "presence": pc.ps.Get(c.Context(), user.ID)})
+}
+
+// Update toggles the caller between available, away and offline
+func (pc *PresenceController) Update(c *fiber.Ctx) error {
+	var req struct {
+		Status models.PresenceStatus `json:"status"`
+	}
+	if err := c.BodyParser(&req); err != nil || !validPresence(req.Status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "status must be available, away or offline"})
+	}
+	user := c.Locals("user").(*models.User)
+	if err := pc.ps.Set(c.Context(), user.ID, req.Status); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update presence"})
+	}
+	return c.JSON(fiber.Map{"user_id": user.ID, "presence": req.Status})
+}
+
+// Heartbeat refreshes the caller's presence TTL for clients without a WebSocket
+func (pc *PresenceController) Heartbeat(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	status, err := pc.ps.Heartbeat(c.Context(), user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record heartbeat"})
+	}
+	return c.JSON(fiber.Map{"user_id": user.ID, "presence": status})
+}
+
+// List is the supervisor view of every agent's availability
+func (pc *PresenceController) List(c *fiber.Ctx) error {
+	agents, err := pc.ps.List(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch presence"})
+	}
+	return c.JSON(fiber.Map{"agents": agents})
+}
+
+// RequireUpgrade rejects plain HTTP requests on WebSocket routes
+func (pc *PresenceController) RequireUpgrade(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return fiber.ErrUpgradeRequired
+}
+
+type presenceFrame struct {
+	Type   string                `json:"type"`
+	Status models.PresenceStatus `json:"status,omitempty"`
+}
+
+// Stream is the presence WebSocket. Clients send {"type":"heartbeat"} or
+// {"type":"status","status":"away"}; supervisors and admins also receive
+// {"type":"presence_change",...} for every agent.
+func (pc *PresenceController) Stream() fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		user := conn.Locals("user").(*models.User)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var writeMu sync.Mutex
+		write := func(v interface{}) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			return conn.WriteJSON(v)
+		}
+
+		if user.Role == models.RoleSupervisor || user.Role == models.RoleAdmin {
+			changes := pc.ps.Subscribe(ctx)
+			go func() {
+				for change := range changes {
+					if err := write(fiber.Map{"type": "presence_change", "user_id": change.UserID, "status": change.Status, "at": change.At}); err != nil {
+						cancel()
+						return
+					}
+				}
+			}()
+		}
+
+		// connecting counts as the first heartbeat
+		status, _ := pc.ps.Heartbeat(ctx, user.ID)
+		_ = write(fiber.Map{"type": "presence", "status": status})
+
+		for {
+			var frame presenceFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				// let the TTL expire rather than going offline at once, other tabs may still be open
+				return
+			}
+			switch frame.Type {
+			case "heartbeat":
+				status, _ = pc.ps.Heartbeat(ctx, user.ID)
+			case "status":
+				if !validPresence(frame.Status) {
+					_ = write(fiber.Map{"type": "error", "error": "status must be available, away or offline"})
+					continue
+				}
+				if err := pc.ps.Set(ctx, user.ID, frame.Status); err == nil {
+					status = frame.Status
+				}
+			default:
+				continue
+			}
+			if err := write(fiber.Map{"type": "presence", "status": status}); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type SearchController struct {
+	search *services.SearchService
+}
+
+func NewSearchController(search *services.SearchService) *SearchController {
+	return &SearchController{search: search}
+}
+
+// Search looks through messages, internal notes and customers:
+// GET /search?q=&scope=all|messages|notes|customers&agent_id=&from=YYYY-MM-DD&to=YYYY-MM-DD&type=&direction=&page=&limit=
+func (sc *SearchController) Search(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	f := services.SearchFilter{
+		Query:     c.Query("q"),
+		Type:      models.MessageType(c.Query("type")),
+		Direction: models.MessageDirection(c.Query("direction")),
+		Offset:    (page - 1) * limit,
+		Limit:     limit,
+	}
+	switch agent := c.Query("agent_id"); agent {
+	case "":
+	case "mine":
+		user := c.Locals("user").(*models.User)
+		f.AgentID = &user.ID
+	default:
+		id, err := uuid.Parse(agent)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid agent_id"})
+		}
+		f.AgentID = &id
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from date"})
+		}
+		f.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to date"})
+		}
+		t = t.AddDate(0, 0, 1)
+		f.To = &t
+	}
+	if f.Direction != "" && f.Direction != models.MessageDirectionInbound && f.Direction != models.MessageDirectionOutbound {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "direction must be inbound or outbound"})
+	}
+
+	scope := c.Query("scope", "all")
+	if scope != "all" && scope != "messages" && scope != "notes" && scope != "customers" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "scope must be all, messages, notes or customers"})
+	}
+	result := fiber.Map{"query": f.Query, "pagination": fiber.Map{"page": page, "limit": limit}}
+	if scope == "all" || scope == "messages" {
+		hits, total, err := sc.search.Messages(f)
+		if err != nil {
+			return sc.fail(c, err)
+		}
+		result["messages"] = fiber.Map{"results": hits, "total": total}
+	}
+	if scope == "all" || scope == "notes" {
+		hits, total, err := sc.search.Notes(f)
+		if err != nil {
+			return sc.fail(c, err)
+		}
+		result["notes"] = fiber.Map{"results": hits, "total": total}
+	}
+	if scope == "all" || scope == "customers" {
+		hits, total, err := sc.search.Customers(f)
+		if err != nil {
+			return sc.fail(c, err)
+		}
+		result["customers"] = fiber.Map{"results": hits, "total": total}
+	}
+	return c.JSON(result)
+}
+
+func (sc *SearchController) fail(c *fiber.Ctx, err error) error {
+	if err == services.ErrSearchQuery {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Search failed"})
+}
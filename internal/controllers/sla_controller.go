@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SLAController struct {
+	db  *gorm.DB
+	sla *services.SLAService
+}
+
+func NewSLAController(db *gorm.DB, sla *services.SLAService) *SLAController {
+	return &SLAController{db: db, sla: sla}
+}
+
+type slaPolicyRequest struct {
+	Name                 string                      `json:"name"`
+	Priority             models.ConversationPriority `json:"priority"`
+	TeamID               *string                     `json:"team_id"`
+	FirstResponseMinutes *int                        `json:"first_response_minutes"`
+	ResolutionMinutes    *int                        `json:"resolution_minutes"`
+	WarnBeforeMinutes    *int                        `json:"warn_before_minutes"`
+	BreachAction         models.SLABreachAction      `json:"breach_action"`
+	IsActive             *bool                       `json:"is_active"`
+}
+
+func validPriority(p models.ConversationPriority) bool {
+	switch p {
+	case models.PriorityLow, models.PriorityMedium, models.PriorityHigh, models.PriorityUrgent:
+		return true
+	}
+	return false
+}
+
+func validBreachAction(a models.SLABreachAction) bool {
+	switch a {
+	case models.SLAActionNone, models.SLAActionBumpPriority, models.SLAActionReassign:
+		return true
+	}
+	return false
+}
+
+// ListPolicies returns all SLA policies
+func (sc *SLAController) ListPolicies(c *fiber.Ctx) error {
+	var policies []models.SLAPolicy
+	if err := sc.db.Preload("Team").Order("priority asc, team_id asc").Find(&policies).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch SLA policies"})
+	}
+	return c.JSON(fiber.Map{"policies": policies})
+}
+
+// CreatePolicy adds a policy for a priority, optionally scoped to a team
+func (sc *SLAController) CreatePolicy(c *fiber.Ctx) error {
+	var req slaPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Name == "" || !validPriority(req.Priority) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name and a valid priority are required"})
+	}
+	if req.FirstResponseMinutes == nil || req.ResolutionMinutes == nil || *req.FirstResponseMinutes <= 0 || *req.ResolutionMinutes <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "first_response_minutes and resolution_minutes must be positive"})
+	}
+
+	policy := models.SLAPolicy{
+		Name:                 req.Name,
+		Priority:             req.Priority,
+		FirstResponseMinutes: *req.FirstResponseMinutes,
+		ResolutionMinutes:    *req.ResolutionMinutes,
+		WarnBeforeMinutes:    15,
+		BreachAction:         models.SLAActionNone,
+		IsActive:             true,
+	}
+	if req.TeamID != nil && *req.TeamID != "" {
+		teamID, err := sc.teamID(*req.TeamID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "team_id must reference an existing team"})
+		}
+		policy.TeamID = &teamID
+	}
+	if req.WarnBeforeMinutes != nil {
+		policy.WarnBeforeMinutes = *req.WarnBeforeMinutes
+	}
+	if req.BreachAction != "" {
+		if !validBreachAction(req.BreachAction) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "breach_action must be none, bump_priority or reassign"})
+		}
+		policy.BreachAction = req.BreachAction
+	}
+	if err := sc.db.Create(&policy).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create SLA policy"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(policy)
+}
+
+// UpdatePolicy edits a policy; due dates already stamped on conversations are kept
+func (sc *SLAController) UpdatePolicy(c *fiber.Ctx) error {
+	var policy models.SLAPolicy
+	if err := sc.db.First(&policy, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "SLA policy not found"})
+	}
+	var req slaPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if req.Name != "" {
+		policy.Name = req.Name
+	}
+	if req.Priority != "" {
+		if !validPriority(req.Priority) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid priority"})
+		}
+		policy.Priority = req.Priority
+	}
+	if req.TeamID != nil {
+		policy.TeamID = nil
+		if *req.TeamID != "" {
+			teamID, err := sc.teamID(*req.TeamID)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "team_id must reference an existing team"})
+			}
+			policy.TeamID = &teamID
+		}
+	}
+	if req.FirstResponseMinutes != nil && *req.FirstResponseMinutes > 0 {
+		policy.FirstResponseMinutes = *req.FirstResponseMinutes
+	}
+	if req.ResolutionMinutes != nil && *req.ResolutionMinutes > 0 {
+		policy.ResolutionMinutes = *req.ResolutionMinutes
+	}
+	if req.WarnBeforeMinutes != nil {
+		policy.WarnBeforeMinutes = *req.WarnBeforeMinutes
+	}
+	if req.BreachAction != "" {
+		if !validBreachAction(req.BreachAction) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "breach_action must be none, bump_priority or reassign"})
+		}
+		policy.BreachAction = req.BreachAction
+	}
+	if req.IsActive != nil {
+		policy.IsActive = *req.IsActive
+	}
+	if err := sc.db.Omit("Team").Save(&policy).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update SLA policy"})
+	}
+	return c.JSON(policy)
+}
+
+// DeletePolicy removes a policy; conversations stamped from it keep their due dates
+func (sc *SLAController) DeletePolicy(c *fiber.Ctx) error {
+	if err := sc.db.Delete(&models.SLAPolicy{}, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete SLA policy"})
+	}
+	return c.JSON(fiber.Map{"message": "SLA policy deleted successfully"})
+}
+
+// Breaches reports missed targets: GET /sla/breaches?from=YYYY-MM-DD&to=YYYY-MM-DD&team_id=&page=&limit=
+func (sc *SLAController) Breaches(c *fiber.Ctx) error {
+	var from, to *time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from date"})
+		}
+		from = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to date"})
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	var teamID *uuid.UUID
+	if v := c.Query("team_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid team_id"})
+		}
+		teamID = &id
+	}
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	summary, err := sc.sla.BreachReport(from, to, teamID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build report"})
+	}
+	breaches, total, err := sc.sla.Breaches(from, to, teamID, (page-1)*limit, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch breaches"})
+	}
+	return c.JSON(fiber.Map{
+		"summary":  summary,
+		"breaches": breaches,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+func (sc *SLAController) teamID(raw string) (uuid.UUID, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	var team models.Team
+	if err := sc.db.Select("id").First(&team, "id = ?", id).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return team.ID, nil
+}
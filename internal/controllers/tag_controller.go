@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TagController struct {
+	db   *gorm.DB
+	tags *services.TagService
+}
+
+func NewTagController(db *gorm.DB, tags *services.TagService) *TagController {
+	return &TagController{db: db, tags: tags}
+}
+
+type tagRequest struct {
+	Name  *string          `json:"name"`
+	Color *string          `json:"color"`
+	Scope *models.TagScope `json:"scope"`
+}
+
+// List returns all tags, or only those usable on ?scope=customer|conversation
+func (tc *TagController) List(c *fiber.Ctx) error {
+	tags, err := tc.tags.List(models.TagScope(c.Query("scope")))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch tags"})
+	}
+	return c.JSON(fiber.Map{"tags": tags})
+}
+
+// Create adds a tag
+func (tc *TagController) Create(c *fiber.Ctx) error {
+	var req tagRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	var tag models.Tag
+	req.apply(&tag)
+	if tag.Color == "" {
+		tag.Color = "#6b7280"
+	}
+	if err := tc.tags.Save(&tag); err != nil {
+		return tc.fail(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(tag)
+}
+
+// Update renames, recolors or rescopes a tag
+func (tc *TagController) Update(c *fiber.Ctx) error {
+	var tag models.Tag
+	if err := tc.db.First(&tag, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tag not found"})
+	}
+	var req tagRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.apply(&tag)
+	if err := tc.tags.Save(&tag); err != nil {
+		return tc.fail(c, err)
+	}
+	return c.JSON(tag)
+}
+
+// Delete removes a tag from every customer and conversation
+func (tc *TagController) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	if err := tc.tags.Delete(id); err != nil {
+		return tc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Tag deleted successfully"})
+}
+
+// AddToCustomer tags a customer: POST /customers/:id/tags {"tag_id":"..."}
+func (tc *TagController) AddToCustomer(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	var req struct {
+		TagID uuid.UUID `json:"tag_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.TagID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "tag_id is required"})
+	}
+	if err := tc.tags.AddToCustomer(id, req.TagID); err != nil {
+		return tc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Tag added"})
+}
+
+// RemoveFromCustomer untags a customer: DELETE /customers/:id/tags/:tagId
+func (tc *TagController) RemoveFromCustomer(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+	tagID, err := uuid.Parse(c.Params("tagId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid tag ID"})
+	}
+	if err := tc.tags.RemoveFromCustomer(id, tagID); err != nil {
+		return tc.fail(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Tag removed"})
+}
+
+func (r tagRequest) apply(tag *models.Tag) {
+	if r.Name != nil {
+		tag.Name = *r.Name
+	}
+	if r.Color != nil {
+		tag.Color = *r.Color
+	}
+	if r.Scope != nil {
+		tag.Scope = *r.Scope
+	}
+}
+
+func (tc *TagController) fail(c *fiber.Ctx, err error) error {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	case services.ErrTagName, services.ErrTagColor, services.ErrTagScope:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrTagTaken:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save tag"})
+}
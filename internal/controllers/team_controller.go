@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TeamController struct {
+	db    *gorm.DB
+	teams *services.TeamService
+}
+
+func NewTeamController(db *gorm.DB, teams *services.TeamService) *TeamController {
+	return &TeamController{db: db, teams: teams}
+}
+
+type teamRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Skills      string `json:"skills"`
+	AutoAssign  *bool  `json:"auto_assign"`
+	IsActive    *bool  `json:"is_active"`
+	// ScheduleID picks the team's business schedule; "" falls back to the default
+	ScheduleID *string `json:"schedule_id"`
+}
+
+// List returns all teams with their members
+func (tc *TeamController) List(c *fiber.Ctx) error {
+	var teams []models.Team
+	if err := tc.db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email, role, status")
+	}).Order("name asc").Find(&teams).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch teams"})
+	}
+	return c.JSON(fiber.Map{"teams": teams})
+}
+
+// Detail returns one team with its members
+func (tc *TeamController) Detail(c *fiber.Ctx) error {
+	team, err := tc.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Team not found"})
+	}
+	return c.JSON(team)
+}
+
+// Create adds a new team
+func (tc *TeamController) Create(c *fiber.Ctx) error {
+	var req teamRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name is required"})
+	}
+
+	var existing models.Team
+	if err := tc.db.First(&existing, "name = ?", req.Name).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Team name already exists"})
+	}
+
+	team := models.Team{Name: req.Name, Description: req.Description, Skills: req.Skills, IsActive: true}
+	if req.AutoAssign != nil {
+		team.AutoAssign = *req.AutoAssign
+	}
+	if req.ScheduleID != nil {
+		scheduleID, err := tc.schedule(*req.ScheduleID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Business schedule not found"})
+		}
+		team.ScheduleID = scheduleID
+	}
+	if err := tc.db.Create(&team).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create team"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(team)
+}
+
+// Update edits a team
+func (tc *TeamController) Update(c *fiber.Ctx) error {
+	team, err := tc.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Team not found"})
+	}
+	var req teamRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+	if req.Skills != "" {
+		updates["skills"] = req.Skills
+	}
+	if req.AutoAssign != nil {
+		updates["auto_assign"] = *req.AutoAssign
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.ScheduleID != nil {
+		scheduleID, err := tc.schedule(*req.ScheduleID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Business schedule not found"})
+		}
+		updates["schedule_id"] = scheduleID
+	}
+	if err := tc.db.Model(team).Updates(updates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update team"})
+	}
+	return c.JSON(team)
+}
+
+// Delete removes a team, its rules and memberships; its conversations fall back to no team
+func (tc *TeamController) Delete(c *fiber.Ctx) error {
+	team, err := tc.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Team not found"})
+	}
+	err = tc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Conversation{}).Where("team_id = ?", team.ID).Update("team_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", team.ID).Delete(&models.RoutingRule{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(team).Association("Members").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(team).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete team"})
+	}
+	return c.JSON(fiber.Map{"message": "Team deleted successfully"})
+}
+
+// AddMember puts a user in the team
+func (tc *TeamController) AddMember(c *fiber.Ctx) error {
+	team, err := tc.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Team not found"})
+	}
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user_id"})
+	}
+	var user models.User
+	if err := tc.db.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	if err := tc.teams.AddMember(team.ID, user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add member"})
+	}
+	return c.JSON(fiber.Map{"message": "Member added"})
+}
+
+// RemoveMember takes a user out of the team
+func (tc *TeamController) RemoveMember(c *fiber.Ctx) error {
+	team, err := tc.find(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Team not found"})
+	}
+	userID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	if err := tc.teams.RemoveMember(team.ID, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to remove member"})
+	}
+	return c.JSON(fiber.Map{"message": "Member removed"})
+}
+
+type routingRuleRequest struct {
+	TeamID   string                 `json:"team_id"`
+	Name     string                 `json:"name"`
+	Type     models.RoutingRuleType `json:"type"`
+	Pattern  string                 `json:"pattern"`
+	Position *int                   `json:"position"`
+	IsActive *bool                  `json:"is_active"`
+}
+
+func validRuleType(t models.RoutingRuleType) bool {
+	switch t {
+	case models.RuleKeyword, models.RuleCustomerTag, models.RuleEntryNumber, models.RuleMenuChoice:
+		return true
+	}
+	return false
+}
+
+// ListRules returns routing rules in evaluation order
+func (tc *TeamController) ListRules(c *fiber.Ctx) error {
+	query := tc.db.Preload("Team").Order("position asc, created_at asc")
+	if teamID := c.Query("team_id"); teamID != "" {
+		query = query.Where("team_id = ?", teamID)
+	}
+	var rules []models.RoutingRule
+	if err := query.Find(&rules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch rules"})
+	}
+	return c.JSON(fiber.Map{"rules": rules})
+}
+
+// CreateRule adds a rule that routes matching conversations to a team
+func (tc *TeamController) CreateRule(c *fiber.Ctx) error {
+	var req routingRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if _, err := tc.find(req.TeamID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "team_id must reference an existing team"})
+	}
+	if !validRuleType(req.Type) || req.Pattern == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "type must be keyword, customer_tag, entry_number or menu_choice and pattern is required"})
+	}
+
+	rule := models.RoutingRule{TeamID: uuid.MustParse(req.TeamID), Name: req.Name, Type: req.Type, Pattern: req.Pattern, IsActive: true}
+	if req.Position != nil {
+		rule.Position = *req.Position
+	}
+	if err := tc.db.Create(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create rule"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// UpdateRule edits a routing rule
+func (tc *TeamController) UpdateRule(c *fiber.Ctx) error {
+	var rule models.RoutingRule
+	if err := tc.db.First(&rule, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Rule not found"})
+	}
+	var req routingRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if req.TeamID != "" {
+		team, err := tc.find(req.TeamID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "team_id must reference an existing team"})
+		}
+		rule.TeamID = team.ID
+	}
+	if req.Type != "" {
+		if !validRuleType(req.Type) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid rule type"})
+		}
+		rule.Type = req.Type
+	}
+	if req.Name != "" {
+		rule.Name = req.Name
+	}
+	if req.Pattern != "" {
+		rule.Pattern = req.Pattern
+	}
+	if req.Position != nil {
+		rule.Position = *req.Position
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	if err := tc.db.Omit("Team").Save(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update rule"})
+	}
+	return c.JSON(rule)
+}
+
+// DeleteRule removes a routing rule
+func (tc *TeamController) DeleteRule(c *fiber.Ctx) error {
+	if err := tc.db.Delete(&models.RoutingRule{}, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete rule"})
+	}
+	return c.JSON(fiber.Map{"message": "Rule deleted successfully"})
+}
+
+func (tc *TeamController) find(rawID string) (*models.Team, error) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return nil, err
+	}
+	var team models.Team
+	if err := tc.db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email, role, status")
+	}).First(&team, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// schedule resolves a schedule_id from a request, "" meaning the default schedule
+func (tc *TeamController) schedule(id string) (*uuid.UUID, error) {
+	if id == "" {
+		return nil, nil
+	}
+	var schedule models.BusinessSchedule
+	if err := tc.db.Select("id").First(&schedule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule.ID, nil
+}
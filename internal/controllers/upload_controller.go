@@ -14,9 +14,9 @@ import (
 	"gorm.io/gorm"
 )
 
-type UploadController struct{ db *gorm.DB; mu *services.MediaUploader; cfg *config.Config }
+type UploadController struct{ db *gorm.DB; mu *services.MediaUploader; ms *services.MessageService; cfg *config.Config }
 
-func NewUploadController(db *gorm.DB, mu *services.MediaUploader, cfg *config.Config) *UploadController { return &UploadController{db: db, mu: mu, cfg: cfg} }
+func NewUploadController(db *gorm.DB, mu *services.MediaUploader, ms *services.MessageService, cfg *config.Config) *UploadController { return &UploadController{db: db, mu: mu, ms: ms, cfg: cfg} }
 
 func (uc *UploadController) withinSize(size int64) bool { return size <= uc.cfg.MaxFileSize }
 
@@ -69,8 +69,14 @@ func (uc *UploadController) UploadAndSend(c *fiber.Ctx) error {
 
 	// pass mediaType as declared type so uploader uses intended WA API
 	msg, err := uc.mu.UploadAndSend(context.Background(), &conv, file, mediaType, caption)
-	if err != nil { return c.Status(502).JSON(fiber.Map{"error": fmt.Sprintf("upload/send failed: %v", err)}) }
+	if msg != nil { uc.ms.StampSender(&conv, msg, c.Locals("user").(*models.User)) }
+	if err != nil {
+		if msg != nil { uc.db.Create(msg) }
+		return c.Status(502).JSON(fiber.Map{"error": fmt.Sprintf("upload/send failed: %v", err)})
+	}
 
+	uc.ms.StampReply(&conv, msg, *msg.SentAt)
 	if err := uc.db.Create(msg).Error; err != nil { return c.Status(500).JSON(fiber.Map{"error":"failed to save message"}) }
+	_ = uc.ms.SaveReply(&conv)
 	return c.Status(201).JSON(msg)
 }
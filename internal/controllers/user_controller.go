@@ -47,7 +47,7 @@ func (uc *UserController) GetUsers(c *fiber.Ctx) error {
 
 	// Get users
 	var users []models.User
-	if err := query.Select("id, name, email, phone, role, status, avatar, last_login, created_at, updated_at").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+	if err := query.Select("id, name, email, phone, role, status, avatar, last_login, presence_at, max_concurrent, created_at, updated_at").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch users",
 		})
@@ -69,7 +69,7 @@ func (uc *UserController) GetUser(c *fiber.Ctx) error {
 	userID := c.Params("id")
 
 	var user models.User
-	if err := uc.db.Select("id, name, email, phone, role, status, avatar, last_login, created_at, updated_at").First(&user, "id = ?", userID).Error; err != nil {
+	if err := uc.db.Select("id, name, email, phone, role, status, avatar, last_login, presence_at, max_concurrent, created_at, updated_at").First(&user, "id = ?", userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "User not found",
@@ -140,11 +140,12 @@ func (uc *UserController) UpdateUser(c *fiber.Ctx) error {
 	userID := c.Params("id")
 
 	var req struct {
-		Name   string `json:"name"`
-		Phone  string `json:"phone"`
-		Role   string `json:"role"`
-		Status string `json:"status"`
-		Avatar string `json:"avatar"`
+		Name          string `json:"name"`
+		Phone         string `json:"phone"`
+		Role          string `json:"role"`
+		Status        string `json:"status"`
+		Avatar        string `json:"avatar"`
+		MaxConcurrent *int   `json:"max_concurrent"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -181,6 +182,9 @@ func (uc *UserController) UpdateUser(c *fiber.Ctx) error {
 	if req.Avatar != "" {
 		user.Avatar = req.Avatar
 	}
+	if req.MaxConcurrent != nil {
+		user.MaxConcurrent = *req.MaxConcurrent
+	}
 
 	if err := uc.db.Save(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"strings"
 	"time"
 	"whatsapp-crm/internal/config"
 	"whatsapp-crm/internal/models"
@@ -18,14 +20,22 @@ type WebhookController struct {
 	messageService   *services.MessageService
 	customerService  *services.CustomerService
 	conversationService *services.ConversationService
+	outOfOffice         *services.OutOfOfficeService
+	chatbot             *services.ChatbotService
+	csat                *services.CSATService
+	events              *services.EventDispatcher
 }
 
 type WebhookMessage struct {
-	ID        string    `json:"id"`
-	From      string    `json:"from"`
-	To        string    `json:"to"`
-	Type      string    `json:"type"`
-	Timestamp time.Time `json:"timestamp"`
+	ID           string    `json:"id"`
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	Type         string    `json:"type"`
+	Timestamp    time.Time `json:"timestamp"`
+	PushName     string    `json:"push_name,omitempty"`
+	IsGroup      bool      `json:"is_group,omitempty"`
+	GroupSubject string    `json:"group_subject,omitempty"`
+	Participant  string    `json:"participant,omitempty"`
 	Text      *struct {
 		Body string `json:"body"`
 	} `json:"text,omitempty"`
@@ -54,13 +64,34 @@ type WebhookMessage struct {
 		Name  string `json:"name"`
 		Phone string `json:"phone"`
 	} `json:"contact,omitempty"`
+	// Interactive is the button or list row the customer tapped
+	Interactive *struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"interactive,omitempty"`
+}
+
+// isGroup reports whether the message was posted in a group chat
+func (m *WebhookMessage) isGroup() bool {
+	return m.IsGroup || strings.HasSuffix(m.From, "@g.us")
 }
 
 type WebhookStatus struct {
-	ID        string    `json:"id"`
-	Status    string    `json:"status"`
-	Timestamp time.Time `json:"timestamp"`
-	To        string    `json:"to"`
+	ID        string         `json:"id"`
+	Status    string         `json:"status"`
+	Timestamp time.Time      `json:"timestamp"`
+	To        string         `json:"to"`
+	Errors    []WebhookError `json:"errors,omitempty"`
+}
+
+// WebhookError is the provider's failure reason attached to a failed status
+type WebhookError struct {
+	Code      json.RawMessage `json:"code"`
+	Title     string          `json:"title"`
+	Message   string          `json:"message"`
+	ErrorData *struct {
+		Details string `json:"details"`
+	} `json:"error_data,omitempty"`
 }
 
 type WebhookPresence struct {
@@ -69,20 +100,37 @@ type WebhookPresence struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// WebhookGroupEvent describes a change to a group: subject/description edits
+// and participants joining, leaving or changing role
+type WebhookGroupEvent struct {
+	GroupID      string    `json:"group_id"`
+	Action       string    `json:"action"` // subject|description|add|join|remove|leave|promote|demote
+	Subject      string    `json:"subject,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	Participants []string  `json:"participants,omitempty"`
+	Author       string    `json:"author,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
 type WebhookPayload struct {
-	Type     string           `json:"type"`
-	Message  *WebhookMessage  `json:"message,omitempty"`
-	Status   *WebhookStatus   `json:"status,omitempty"`
-	Presence *WebhookPresence `json:"presence,omitempty"`
+	Type     string             `json:"type"`
+	Message  *WebhookMessage    `json:"message,omitempty"`
+	Status   *WebhookStatus     `json:"status,omitempty"`
+	Presence *WebhookPresence   `json:"presence,omitempty"`
+	Group    *WebhookGroupEvent `json:"group,omitempty"`
 }
 
-func NewWebhookController(db *gorm.DB, cfg *config.Config, messageService *services.MessageService, customerService *services.CustomerService, conversationService *services.ConversationService) *WebhookController {
+func NewWebhookController(db *gorm.DB, cfg *config.Config, messageService *services.MessageService, customerService *services.CustomerService, conversationService *services.ConversationService, outOfOffice *services.OutOfOfficeService, chatbot *services.ChatbotService, csat *services.CSATService, events *services.EventDispatcher) *WebhookController {
 	return &WebhookController{
 		db:                  db,
 		cfg:                 cfg,
 		messageService:      messageService,
 		customerService:     customerService,
 		conversationService: conversationService,
+		outOfOffice:         outOfOffice,
+		chatbot:             chatbot,
+		csat:                csat,
+		events:              events,
 	}
 }
 
@@ -153,6 +201,15 @@ func (wc *WebhookController) HandleWebhook(c *fiber.Ctx) error {
 			webhookLog.Status = models.WebhookStatusProcessed
 		}
 
+	case "group":
+		if err := wc.handleGroupEvent(webhookPayload.Group, &webhookLog); err != nil {
+			log.Printf("Failed to process group event: %v", err)
+			webhookLog.Status = models.WebhookStatusFailed
+			webhookLog.ErrorMessage = err.Error()
+		} else {
+			webhookLog.Status = models.WebhookStatusProcessed
+		}
+
 	default:
 		webhookLog.Status = models.WebhookStatusIgnored
 		webhookLog.ErrorMessage = "Unknown event type: " + webhookPayload.Type
@@ -169,15 +226,37 @@ func (wc *WebhookController) HandleWebhook(c *fiber.Ctx) error {
 func (wc *WebhookController) handleIncomingMessage(msg *WebhookMessage, webhookLog *models.WebhookLog) error {
 	webhookLog.EventType = models.WebhookEventMessage
 
-	// Get or create customer
-	customer, err := wc.customerService.GetOrCreateCustomer(msg.From, "")
+	// Get or create customer (a group is represented by its own customer record)
+	var customer *models.Customer
+	var err error
+	if msg.isGroup() {
+		customer, err = wc.customerService.GetOrCreateGroup(msg.From, msg.GroupSubject)
+	} else {
+		customer, err = wc.customerService.GetOrCreateCustomer(msg.From, "")
+	}
 	if err != nil {
 		return err
 	}
 
+	// A reply to a satisfaction survey is filed on the conversation it rates, which
+	// stays closed, instead of starting a new request
+	var replyText, choice string
+	if msg.Text != nil {
+		replyText = msg.Text.Body
+	}
+	if msg.Interactive != nil {
+		replyText, choice = msg.Interactive.Title, msg.Interactive.ID
+	}
+	survey, rating := wc.csat.Claim(customer.ID, replyText, choice)
+
 	// Get or create conversation
-	conversation, err := wc.conversationService.GetOrCreateConversation(customer.ID)
-	if err != nil {
+	var conversation *models.Conversation
+	if survey != nil {
+		conversation = &models.Conversation{}
+		if err := wc.db.First(conversation, "id = ?", survey.ConversationID).Error; err != nil {
+			return err
+		}
+	} else if conversation, err = wc.conversationService.GetOrCreateConversation(customer.ID); err != nil {
 		return err
 	}
 
@@ -192,6 +271,17 @@ func (wc *WebhookController) handleIncomingMessage(msg *WebhookMessage, webhookL
 		DeliveredAt:    &msg.Timestamp,
 	}
 
+	// Record which group member wrote the message
+	if msg.isGroup() && msg.Participant != "" {
+		message.ParticipantID = msg.Participant
+		message.ParticipantName = msg.PushName
+		if customer.Contact != nil {
+			if _, err := wc.customerService.EnsureParticipant(customer.Contact, msg.Participant, msg.PushName); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Set message content based on type
 	switch msg.Type {
 	case "text":
@@ -229,6 +319,10 @@ func (wc *WebhookController) handleIncomingMessage(msg *WebhookMessage, webhookL
 			message.ContactName = msg.Contact.Name
 			message.ContactPhone = msg.Contact.Phone
 		}
+	case "interactive":
+		if msg.Interactive != nil {
+			message.Content = msg.Interactive.Title
+		}
 	}
 
 	// Save message
@@ -239,13 +333,37 @@ func (wc *WebhookController) handleIncomingMessage(msg *WebhookMessage, webhookL
 	// Update conversation
 	now := time.Now()
 	conversation.LastMessageAt = &now
-	conversation.UnreadCount++
-	wc.db.Save(&conversation)
+	conversation.LastInboundAt = &now
+	conversation.LastActivityAt = now
+	wc.db.Model(&conversation).Select("last_message_at", "last_inbound_at", "last_activity_at").Updates(&conversation)
 
 	// Update customer last seen
 	customer.LastSeen = &now
 	wc.db.Save(&customer)
 
+	if survey != nil {
+		wc.csat.Record(survey, rating, replyText)
+		wc.events.Publish(models.EventMessageReceived, message)
+		return nil
+	}
+
+	// The chatbot answers first; humans get the conversation once it hands off
+	if wc.chatbot.HandleInbound(conversation, &message, choice) {
+		wc.events.Publish(models.EventMessageReceived, message)
+		return nil
+	}
+
+	// Route a fresh conversation now that its first message is known
+	if services.NeedsRouting(conversation) {
+		hints := services.RoutingHints{Text: strings.TrimSpace(message.Content + " " + message.Caption), EntryNumber: msg.To, MenuChoice: choice}
+		wc.conversationService.AutoRoute(conversation, hints)
+	}
+
+	// Let the customer know when we are closed
+	wc.outOfOffice.HandleInbound(conversation, msg.Timestamp)
+
+	wc.events.Publish(models.EventMessageReceived, message)
+
 	return nil
 }
 
@@ -266,9 +384,26 @@ func (wc *WebhookController) handleMessageStatus(status *WebhookStatus, webhookL
 		message.DeliveredAt = &status.Timestamp
 	case "read":
 		message.ReadAt = &status.Timestamp
+	case "failed":
+		message.FailedAt = &status.Timestamp
+		if len(status.Errors) > 0 {
+			reason := status.Errors[0]
+			// code may arrive as a number or a string depending on the gateway
+			message.ErrorCode = strings.Trim(string(reason.Code), `"`)
+			message.ErrorTitle = reason.Title
+			message.ErrorDetails = reason.Message
+			if reason.ErrorData != nil && reason.ErrorData.Details != "" {
+				message.ErrorDetails = reason.ErrorData.Details
+			}
+		}
 	}
 
-	return wc.db.Save(&message).Error
+	if err := wc.db.Save(&message).Error; err != nil {
+		return err
+	}
+
+	wc.events.Publish(models.EventMessageStatusChanged, message)
+	return nil
 }
 
 func (wc *WebhookController) handlePresenceUpdate(presence *WebhookPresence, webhookLog *models.WebhookLog) error {
@@ -283,4 +418,42 @@ func (wc *WebhookController) handlePresenceUpdate(presence *WebhookPresence, web
 
 	customer.LastSeen = &presence.Timestamp
 	return wc.db.Save(&customer).Error
-}
\ No newline at end of file
+}
+func (wc *WebhookController) handleGroupEvent(event *WebhookGroupEvent, webhookLog *models.WebhookLog) error {
+	webhookLog.EventType = models.WebhookEventGroup
+	if event == nil || event.GroupID == "" {
+		return errors.New("missing group event")
+	}
+
+	customer, err := wc.customerService.GetOrCreateGroup(event.GroupID, event.Subject)
+	if err != nil {
+		return err
+	}
+	contact := customer.Contact
+	if contact == nil {
+		return errors.New("group has no contact record")
+	}
+
+	at := event.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	switch event.Action {
+	case "subject":
+		return wc.customerService.UpdateGroupInfo(contact, &event.Subject, nil)
+	case "description":
+		return wc.customerService.UpdateGroupInfo(contact, nil, &event.Description)
+	case "add", "join":
+		return wc.customerService.JoinGroup(contact, event.Participants, at)
+	case "remove", "leave":
+		return wc.customerService.LeaveGroup(contact, event.Participants, at)
+	case "promote":
+		return wc.customerService.SetParticipantRole(contact, event.Participants, models.GroupRoleAdmin)
+	case "demote":
+		return wc.customerService.SetParticipantRole(contact, event.Participants, models.GroupRoleMember)
+	default:
+		webhookLog.ErrorMessage = "Unknown group action: " + event.Action
+		return nil
+	}
+}
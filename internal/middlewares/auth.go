@@ -6,6 +6,7 @@ import (
 	"whatsapp-crm/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"gorm.io/gorm"
 )
@@ -21,6 +22,10 @@ func NewAuthMiddleware(db *gorm.DB) *AuthMiddleware {
 func (a *AuthMiddleware) RequireAuth(c *fiber.Ctx) error {
 	// Get token from Authorization header
 	authorization := c.Get("Authorization")
+	if authorization == "" && websocket.IsWebSocketUpgrade(c) && c.Query("token") != "" {
+		// Browsers cannot set headers on a WebSocket handshake
+		authorization = "Bearer " + c.Query("token")
+	}
 	if authorization == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Authorization header is required",
@@ -53,7 +58,7 @@ func (a *AuthMiddleware) RequireAuth(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check user status
+	// Check user status (online/away/offline are presence states of an active account)
 	if user.Status == models.UserStatusInactive {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Account is inactive",
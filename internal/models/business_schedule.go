@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BusinessSchedule is a set of opening hours in one timezone with its holidays and
+// out-of-office reply. The default schedule applies to conversations whose team has none.
+type BusinessSchedule struct {
+	ID                uuid.UUID `json:"id" gorm:"type:char(36);primaryKey"`
+	Name              string    `json:"name" gorm:"size:191;uniqueIndex;not null"`
+	Timezone          string    `json:"timezone" gorm:"default:'UTC'"`
+	Hours             string    `json:"hours" gorm:"comment:'e.g. mon-fri=08:00-17:00,sat=08:00-12:00; empty is 24/7'"`
+	IsDefault         bool      `json:"is_default" gorm:"default:false;index"`
+	AutoReplyEnabled  bool      `json:"auto_reply_enabled" gorm:"default:false"`
+	AutoReplyMessage  string    `json:"auto_reply_message" gorm:"type:text;comment:'{{next_open}} is replaced by the next opening time'"`
+	AutoReplyTemplate string    `json:"auto_reply_template" gorm:"comment:'Template name used outside the 24h window'"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+
+	// Relationships
+	Holidays []Holiday `json:"holidays,omitempty" gorm:"foreignKey:ScheduleID"`
+}
+
+func (s *BusinessSchedule) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return
+}
+
+// Holiday closes a schedule for a whole day; recurring holidays repeat every year
+type Holiday struct {
+	ID         uuid.UUID `json:"id" gorm:"type:char(36);primaryKey"`
+	ScheduleID uuid.UUID `json:"schedule_id" gorm:"type:char(36);index;not null"`
+	Name       string    `json:"name"`
+	Date       time.Time `json:"date" gorm:"type:date;not null"`
+	Recurring  bool      `json:"recurring" gorm:"default:false"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (h *Holiday) BeforeCreate(tx *gorm.DB) (err error) {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return
+}
+
+// Key is the BusinessHours holiday key for this date
+func (h *Holiday) Key() string {
+	if h.Recurring {
+		return h.Date.Format("01-02")
+	}
+	return h.Date.Format("2006-01-02")
+}
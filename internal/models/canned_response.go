@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CannedResponse is a saved reply agents insert by shortcode. Shared responses have
+// no owner; personal ones belong to one agent and win over a shared one with the
+// same shortcode.
+type CannedResponse struct {
+	ID         uuid.UUID      `json:"id" gorm:"type:char(36);primaryKey"`
+	Shortcode  string         `json:"shortcode" gorm:"size:64;not null;index"`
+	Title      string         `json:"title"`
+	Content    string         `json:"content" gorm:"type:text;not null"`
+	OwnerID    *uuid.UUID     `json:"owner_id" gorm:"type:char(36);index;comment:'Null for shared responses'"`
+	MediaPath  string         `json:"media_path" gorm:"comment:'Storage object, signed when sent'"`
+	MediaType  MessageType    `json:"media_type"`
+	FileName   string         `json:"file_name"`
+	UsageCount int            `json:"usage_count" gorm:"default:0"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	CreatedBy  uuid.UUID      `json:"created_by" gorm:"type:char(36);not null"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Owner *User `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+}
+
+func (c *CannedResponse) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return
+}
+
+// Shared reports whether every agent can use the response
+func (c *CannedResponse) Shared() bool {
+	return c.OwnerID == nil
+}
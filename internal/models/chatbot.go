@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChatbotMatch string
+
+const (
+	ChatbotMatchKeyword ChatbotMatch = "keyword"
+	ChatbotMatchRegex   ChatbotMatch = "regex"
+)
+
+type ChatbotAction string
+
+const (
+	ChatbotActionReply   ChatbotAction = "reply"
+	ChatbotActionFlow    ChatbotAction = "flow"
+	ChatbotActionHandoff ChatbotAction = "handoff"
+)
+
+// ChatbotRule answers inbound messages matching keywords or a regex: with a fixed
+// reply, by starting a flow, or by handing the conversation to a human
+type ChatbotRule struct {
+	ID        uuid.UUID     `json:"id" gorm:"type:char(36);primaryKey"`
+	Name      string        `json:"name"`
+	MatchType ChatbotMatch  `json:"match_type" gorm:"type:enum('keyword','regex');default:'keyword'"`
+	Pattern   string        `json:"pattern" gorm:"type:text;not null;comment:'Comma-separated keywords or a regex'"`
+	Action    ChatbotAction `json:"action" gorm:"type:enum('reply','flow','handoff');default:'reply'"`
+	Reply     string        `json:"reply" gorm:"type:text"`
+	FlowKey   string        `json:"flow_key" gorm:"size:64;comment:'Flow started by the flow action'"`
+	Priority  int           `json:"priority" gorm:"default:0;comment:'Higher is tried first'"`
+	IsActive  bool          `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+func (r *ChatbotRule) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+// ChatbotFlow is one version of a multi-step flow. Editing a flow saves a new
+// version under the same key; only the active version starts new sessions.
+type ChatbotFlow struct {
+	ID         uuid.UUID       `json:"id" gorm:"type:char(36);primaryKey"`
+	Key        string          `json:"key" gorm:"column:flow_key;size:64;not null;uniqueIndex:idx_flow_version"`
+	Version    int             `json:"version" gorm:"not null;uniqueIndex:idx_flow_version"`
+	Name       string          `json:"name"`
+	Definition string          `json:"-" gorm:"type:longtext;not null"`
+	Spec       json.RawMessage `json:"definition" gorm:"-"`
+	IsActive   bool            `json:"is_active" gorm:"default:false;index"`
+	CreatedBy  *uuid.UUID      `json:"created_by" gorm:"type:char(36)"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func (f *ChatbotFlow) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return
+}
+
+func (f *ChatbotFlow) AfterFind(tx *gorm.DB) (err error) {
+	f.Spec = json.RawMessage(f.Definition)
+	return
+}
+
+// ChatbotSession is where a conversation is in a flow. It stays on the flow version
+// it started with.
+type ChatbotSession struct {
+	ID             uuid.UUID `json:"id" gorm:"type:char(36);primaryKey"`
+	ConversationID uuid.UUID `json:"conversation_id" gorm:"type:char(36);uniqueIndex;not null"`
+	FlowID         uuid.UUID `json:"flow_id" gorm:"type:char(36);index;not null"`
+	Step           string    `json:"step"`
+	Retries        int       `json:"retries" gorm:"default:0"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// Relationships
+	Flow *ChatbotFlow `json:"flow,omitempty" gorm:"foreignKey:FlowID"`
+}
+
+func (s *ChatbotSession) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return
+}
@@ -31,7 +31,8 @@ type Contact struct {
 	UpdatedAt     time.Time     `json:"updated_at"`
 
 	// Relationships
-	Customer Customer `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+	Customer     Customer           `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+	Participants []GroupParticipant `json:"participants,omitempty" gorm:"foreignKey:ContactID"`
 }
 
 func (c *Contact) BeforeCreate(tx *gorm.DB) (err error) {
@@ -25,33 +25,64 @@ const (
 	PriorityUrgent ConversationPriority = "urgent"
 )
 
+// ConversationControl says whether the chatbot or a human is answering the customer
+type ConversationControl string
+
+const (
+	ControlHuman ConversationControl = "human"
+	ControlBot   ConversationControl = "bot"
+)
+
 type Conversation struct {
-	ID               uuid.UUID            `json:"id" gorm:"type:char(36);primaryKey"`
-	CustomerID       uuid.UUID            `json:"customer_id" gorm:"type:char(36);index;not null"`
-	AgentID          *uuid.UUID           `json:"agent_id" gorm:"type:char(36);index"`
-	Status           ConversationStatus   `json:"status" gorm:"type:enum('open','assigned','closed','pending');default:'open'"`
-	Priority         ConversationPriority `json:"priority" gorm:"type:enum('low','medium','high','urgent');default:'medium'"`
-	Subject          string               `json:"subject"`
-	Tags             string               `json:"tags" gorm:"type:text"`
-	Notes            string               `json:"notes" gorm:"type:text"`
-	LastMessageAt    *time.Time           `json:"last_message_at"`
-	AssignedAt       *time.Time           `json:"assigned_at"`
-	ClosedAt         *time.Time           `json:"closed_at"`
-	ResponseTime     int                  `json:"response_time" gorm:"comment:'Response time in seconds'"`
-	ResolutionTime   int                  `json:"resolution_time" gorm:"comment:'Resolution time in seconds'"`
-	UnreadCount      int                  `json:"unread_count" gorm:"default:0"`
-	CreatedAt        time.Time            `json:"created_at"`
-	UpdatedAt        time.Time            `json:"updated_at"`
+	ID                     uuid.UUID            `json:"id" gorm:"type:char(36);primaryKey;index:idx_conversation_activity,priority:2"`
+	CustomerID             uuid.UUID            `json:"customer_id" gorm:"type:char(36);index;not null"`
+	AgentID                *uuid.UUID           `json:"agent_id" gorm:"type:char(36);index"`
+	TeamID                 *uuid.UUID           `json:"team_id" gorm:"type:char(36);index"`
+	Status                 ConversationStatus   `json:"status" gorm:"type:enum('open','assigned','closed','pending');default:'open'"`
+	Priority               ConversationPriority `json:"priority" gorm:"type:enum('low','medium','high','urgent');default:'medium'"`
+	Subject                string               `json:"subject"`
+	IsGroup                bool                 `json:"is_group" gorm:"default:false;index"`
+	ControlledBy           ConversationControl  `json:"controlled_by" gorm:"type:enum('human','bot');default:'human';index"`
+	LastMessageAt          *time.Time           `json:"last_message_at" gorm:"index"`
+	LastActivityAt         time.Time            `json:"last_activity_at" gorm:"not null;default:CURRENT_TIMESTAMP(3);index:idx_conversation_activity,priority:1;comment:'Last message, or creation while there is none; the list sorts on it'"`
+	LastInboundAt          *time.Time           `json:"last_inbound_at" gorm:"index;comment:'Last message from the customer'"`
+	SnoozedAt              *time.Time           `json:"snoozed_at" gorm:"index"`
+	SnoozedUntil           *time.Time           `json:"snoozed_until" gorm:"index;comment:'Null while snoozed means until the customer replies'"`
+	AutoClosed             bool                 `json:"auto_closed" gorm:"default:false"`
+	AutoReplyFor           *time.Time           `json:"auto_reply_for" gorm:"comment:'Opening time the last out-of-office reply was sent for'"`
+	AssignedAt             *time.Time           `json:"assigned_at"`
+	QueuedAt               *time.Time           `json:"queued_at" gorm:"index;comment:'Set while waiting in the routing queue'"`
+	ReopenedAt             *time.Time           `json:"reopened_at"`
+	ReopenCount            int                  `json:"reopen_count" gorm:"default:0"`
+	ClosedAt               *time.Time           `json:"closed_at"`
+	SLAPolicyID            *uuid.UUID           `json:"sla_policy_id" gorm:"type:char(36);index"`
+	FirstRespondedAt       *time.Time           `json:"first_responded_at"`
+	FirstResponseDue       *time.Time           `json:"first_response_due" gorm:"index"`
+	ResolutionDue          *time.Time           `json:"resolution_due" gorm:"index"`
+	SLABreached            bool                 `json:"sla_breached" gorm:"default:false;index"`
+	ResponseTime           int                  `json:"response_time" gorm:"comment:'Response time in seconds'"`
+	ResponseTimeBusiness   int                  `json:"response_time_business" gorm:"comment:'First response time in business-hours seconds'"`
+	ResolutionTime         int                  `json:"resolution_time" gorm:"comment:'Resolution time in seconds'"`
+	ResolutionTimeBusiness int                  `json:"resolution_time_business" gorm:"comment:'Resolution time in business-hours seconds'"`
+	UnreadCount            int                  `json:"unread_count" gorm:"-"` // for the agent viewing it, from their read cursor
+	CreatedAt              time.Time            `json:"created_at"`
+	UpdatedAt              time.Time            `json:"updated_at"`
 
 	// Relationships
-	Customer Customer `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
-	Agent    *User    `json:"agent,omitempty" gorm:"foreignKey:AgentID"`
-	Messages []Message `json:"messages,omitempty" gorm:"foreignKey:ConversationID"`
+	Customer  Customer   `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+	Agent     *User      `json:"agent,omitempty" gorm:"foreignKey:AgentID"`
+	Team      *Team      `json:"team,omitempty" gorm:"foreignKey:TeamID"`
+	SLAPolicy *SLAPolicy `json:"sla_policy,omitempty" gorm:"foreignKey:SLAPolicyID"`
+	Tags      []Tag      `json:"tags,omitempty" gorm:"many2many:conversation_tags"`
+	Messages  []Message  `json:"messages,omitempty" gorm:"foreignKey:ConversationID"`
 }
 
 func (c *Conversation) BeforeCreate(tx *gorm.DB) (err error) {
 	if c.ID == uuid.Nil {
 		c.ID = uuid.New()
 	}
+	if c.LastActivityAt.IsZero() {
+		c.LastActivityAt = time.Now()
+	}
 	return
-}
\ No newline at end of file
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ActorType string
+
+const (
+	ActorUser     ActorType = "user"
+	ActorSystem   ActorType = "system"
+	ActorCustomer ActorType = "customer"
+)
+
+type ConversationEventType string
+
+const (
+	ConversationEventStatusChanged   ConversationEventType = "status_changed"
+	ConversationEventReopened        ConversationEventType = "reopened"
+	ConversationEventAssigned        ConversationEventType = "assigned"
+	ConversationEventTransferred     ConversationEventType = "transferred"
+	ConversationEventTransferAccept  ConversationEventType = "transfer_accepted"
+	ConversationEventTransferDecline ConversationEventType = "transfer_declined"
+	ConversationEventTransferExpired ConversationEventType = "transfer_expired"
+	ConversationEventQueued          ConversationEventType = "queued"
+	ConversationEventTeamChanged     ConversationEventType = "team_changed"
+	ConversationEventPriorityChanged ConversationEventType = "priority_changed"
+	ConversationEventTagsChanged     ConversationEventType = "tags_changed"
+	ConversationEventNotesChanged    ConversationEventType = "notes_changed" // legacy notes field, no longer recorded
+	ConversationEventSLABreached     ConversationEventType = "sla_breached"
+	ConversationEventSnoozed         ConversationEventType = "snoozed"
+	ConversationEventUnsnoozed       ConversationEventType = "unsnoozed"
+	ConversationEventAutoClosed      ConversationEventType = "auto_closed"
+	ConversationEventControlChanged  ConversationEventType = "control_changed"
+)
+
+// ConversationEvent is one entry in a conversation's history: what changed, from
+// what to what, and who (or what) did it.
+type ConversationEvent struct {
+	ID             uuid.UUID             `json:"id" gorm:"type:char(36);primaryKey"`
+	ConversationID uuid.UUID             `json:"conversation_id" gorm:"type:char(36);index;not null"`
+	Type           ConversationEventType `json:"type" gorm:"type:enum('status_changed','reopened','assigned','transferred','transfer_accepted','transfer_declined','transfer_expired','queued','team_changed','priority_changed','tags_changed','notes_changed','sla_breached','snoozed','unsnoozed','auto_closed','control_changed');not null;index"`
+	ActorType      ActorType             `json:"actor_type" gorm:"type:enum('user','system','customer');not null"`
+	ActorID        *uuid.UUID            `json:"actor_id" gorm:"type:char(36);index"`
+	FromValue      string                `json:"from" gorm:"type:text"`
+	ToValue        string                `json:"to" gorm:"type:text"`
+	Note           string                `json:"note" gorm:"type:text"`
+	CreatedAt      time.Time             `json:"created_at" gorm:"index"`
+
+	// Relationships
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+func (e *ConversationEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ConversationNote is an internal note on a conversation. Notes are only visible to
+// agents and are never sent to the customer. Replies point at their thread's root note.
+type ConversationNote struct {
+	ID             uuid.UUID      `json:"id" gorm:"type:char(36);primaryKey"`
+	ConversationID uuid.UUID      `json:"conversation_id" gorm:"type:char(36);index;not null"`
+	ParentID       *uuid.UUID     `json:"parent_id" gorm:"type:char(36);index"`
+	AuthorID       uuid.UUID      `json:"author_id" gorm:"type:char(36);index;not null"`
+	Body           string         `json:"body" gorm:"type:text;not null;index:idx_note_search,class:FULLTEXT"`
+	EditedAt       *time.Time     `json:"edited_at"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"index"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Author    *User              `json:"author,omitempty" gorm:"foreignKey:AuthorID"`
+	Replies   []ConversationNote `json:"replies,omitempty" gorm:"foreignKey:ParentID"`
+	Mentions  []NoteMention      `json:"mentions,omitempty" gorm:"foreignKey:NoteID"`
+	Revisions []NoteRevision     `json:"revisions,omitempty" gorm:"foreignKey:NoteID"`
+}
+
+func (n *ConversationNote) BeforeCreate(tx *gorm.DB) (err error) {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return
+}
+
+// NoteRevision keeps the body a note had before an edit
+type NoteRevision struct {
+	ID        uuid.UUID `json:"id" gorm:"type:char(36);primaryKey"`
+	NoteID    uuid.UUID `json:"note_id" gorm:"type:char(36);index;not null"`
+	Body      string    `json:"body" gorm:"type:text;not null"`
+	EditedBy  uuid.UUID `json:"edited_by" gorm:"type:char(36);not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (r *NoteRevision) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+// NoteMention is a user @mentioned in a note
+type NoteMention struct {
+	ID        uuid.UUID `json:"id" gorm:"type:char(36);primaryKey"`
+	NoteID    uuid.UUID `json:"note_id" gorm:"type:char(36);not null;uniqueIndex:idx_note_mention"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:char(36);not null;uniqueIndex:idx_note_mention;index"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (m *NoteMention) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ConversationRead is one agent's read cursor in a conversation: inbound messages
+// after LastReadAt are unread for that agent.
+type ConversationRead struct {
+	ID                uuid.UUID  `json:"id" gorm:"type:char(36);primaryKey"`
+	ConversationID    uuid.UUID  `json:"conversation_id" gorm:"type:char(36);uniqueIndex:idx_conversation_read;not null"`
+	UserID            uuid.UUID  `json:"user_id" gorm:"type:char(36);uniqueIndex:idx_conversation_read;index;not null"`
+	LastReadAt        time.Time  `json:"last_read_at"`
+	LastReadMessageID *uuid.UUID `json:"last_read_message_id" gorm:"type:char(36)"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func (r *ConversationRead) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TransferStatus string
+
+const (
+	TransferPending   TransferStatus = "pending"
+	TransferAccepted  TransferStatus = "accepted"
+	TransferDeclined  TransferStatus = "declined"
+	TransferExpired   TransferStatus = "expired"
+	TransferCompleted TransferStatus = "completed"
+)
+
+// ConversationTransfer is a handoff of a conversation to another agent or team.
+// Transfers to an agent wait for that agent to accept; transfers to a team go
+// straight to the team queue and are completed on creation.
+type ConversationTransfer struct {
+	ID             uuid.UUID      `json:"id" gorm:"type:char(36);primaryKey"`
+	ConversationID uuid.UUID      `json:"conversation_id" gorm:"type:char(36);index;not null"`
+	FromAgentID    *uuid.UUID     `json:"from_agent_id" gorm:"type:char(36);index"`
+	ToAgentID      *uuid.UUID     `json:"to_agent_id" gorm:"type:char(36);index"`
+	ToTeamID       *uuid.UUID     `json:"to_team_id" gorm:"type:char(36);index"`
+	RequestedBy    uuid.UUID      `json:"requested_by" gorm:"type:char(36);not null"`
+	Note           string         `json:"note" gorm:"type:text;not null"`
+	Status         TransferStatus `json:"status" gorm:"type:enum('pending','accepted','declined','expired','completed');default:'pending';index"`
+	DeclineReason  string         `json:"decline_reason"`
+	ExpiresAt      *time.Time     `json:"expires_at" gorm:"index"`
+	RespondedAt    *time.Time     `json:"responded_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+
+	// Relationships
+	Conversation *Conversation `json:"conversation,omitempty" gorm:"foreignKey:ConversationID"`
+	FromAgent    *User         `json:"from_agent,omitempty" gorm:"foreignKey:FromAgentID"`
+	ToAgent      *User         `json:"to_agent,omitempty" gorm:"foreignKey:ToAgentID"`
+	ToTeam       *Team         `json:"to_team,omitempty" gorm:"foreignKey:ToTeamID"`
+}
+
+func (t *ConversationTransfer) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CSATStatus string
+
+const (
+	CSATSent      CSATStatus = "sent"      // waiting for a rating
+	CSATRated     CSATStatus = "rated"     // rated, waiting for an optional comment
+	CSATCompleted CSATStatus = "completed" // rated, comment window over or comment received
+	CSATExpired   CSATStatus = "expired"   // never rated
+	CSATFailed    CSATStatus = "failed"    // could not be sent
+)
+
+// CSATSurvey is the satisfaction survey sent when a conversation closes. It keeps the
+// agent and team who handled the conversation so ratings are reported against them.
+type CSATSurvey struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:char(36);primaryKey"`
+	ConversationID uuid.UUID  `json:"conversation_id" gorm:"type:char(36);index;not null"`
+	CustomerID     uuid.UUID  `json:"customer_id" gorm:"type:char(36);index:idx_csat_customer;not null"`
+	AgentID        *uuid.UUID `json:"agent_id" gorm:"type:char(36);index"`
+	TeamID         *uuid.UUID `json:"team_id" gorm:"type:char(36);index"`
+	MessageID      *uuid.UUID `json:"message_id" gorm:"type:char(36);comment:'The survey message'"`
+	Channel        string     `json:"channel" gorm:"type:enum('interactive','template');not null"`
+	Status         CSATStatus `json:"status" gorm:"type:enum('sent','rated','completed','expired','failed');default:'sent';index:idx_csat_customer"`
+	Rating         *int       `json:"rating"`
+	Comment        string     `json:"comment" gorm:"type:text"`
+	SentAt         time.Time  `json:"sent_at" gorm:"index"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RatedAt        *time.Time `json:"rated_at"`
+	CommentedAt    *time.Time `json:"commented_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relationships
+	Agent *User `json:"agent,omitempty" gorm:"foreignKey:AgentID"`
+}
+
+func (s *CSATSurvey) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,17 +10,17 @@ import (
 
 type Customer struct {
 	ID          uuid.UUID `json:"id" gorm:"type:char(36);primaryKey"`
-	Name        string    `json:"name" gorm:"not null"`
-	Email       string    `json:"email" gorm:"index"`
-	Phone       string    `json:"phone" gorm:"index"`
+	Name        string    `json:"name" gorm:"not null;index:idx_customer_search,class:FULLTEXT"`
+	Email       string    `json:"email" gorm:"index;index:idx_customer_search,class:FULLTEXT"`
+	Phone       string    `json:"phone" gorm:"index;index:idx_customer_search,class:FULLTEXT"`
+	PhoneDigits string    `json:"-" gorm:"index;comment:'Phone without formatting, kept by BeforeSave for matching'"`
 	WhatsAppID  string    `json:"whatsapp_id" gorm:"uniqueIndex"`
 	ProfilePic  string    `json:"profile_pic"`
 	Company     string    `json:"company"`
 	Address     string    `json:"address"`
 	City        string    `json:"city"`
 	Country     string    `json:"country"`
-	Notes       string    `json:"notes" gorm:"type:text"`
-	Tags        string    `json:"tags" gorm:"type:text"`
+	Notes       string    `json:"notes" gorm:"type:text;index:idx_customer_search,class:FULLTEXT"`
 	LastSeen    *time.Time `json:"last_seen"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -27,6 +28,7 @@ type Customer struct {
 
 	// Relationships
 	Contact       *Contact       `json:"contact,omitempty" gorm:"foreignKey:CustomerID"`
+	Tags          []Tag          `json:"tags,omitempty" gorm:"many2many:customer_tags"`
 	Conversations []Conversation `json:"conversations,omitempty" gorm:"foreignKey:CustomerID"`
 }
 
@@ -35,4 +37,27 @@ func (c *Customer) BeforeCreate(tx *gorm.DB) (err error) {
 		c.ID = uuid.New()
 	}
 	return
+}
+
+// BeforeSave keeps PhoneDigits in step with Phone, for saves of the struct and for
+// updates given as a map
+func (c *Customer) BeforeSave(tx *gorm.DB) (err error) {
+	if updates, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+		if phone, ok := updates["phone"].(string); ok {
+			updates["phone_digits"] = PhoneDigits(phone)
+		}
+		return
+	}
+	c.PhoneDigits = PhoneDigits(c.Phone)
+	return
+}
+
+// PhoneDigits is a phone number with everything but its digits removed
+func PhoneDigits(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
 }
\ No newline at end of file
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CustomerImportStatus string
+
+const (
+	CustomerImportDraft     CustomerImportStatus = "draft"
+	CustomerImportPending   CustomerImportStatus = "pending"
+	CustomerImportRunning   CustomerImportStatus = "running"
+	CustomerImportCompleted CustomerImportStatus = "completed"
+	CustomerImportFailed    CustomerImportStatus = "failed"
+)
+
+// CustomerImportJob is a customer spreadsheet upload. It stays a draft while the
+// columns are mapped and previewed, then the worker imports it.
+type CustomerImportJob struct {
+	ID          uuid.UUID            `json:"id" gorm:"type:char(36);primaryKey"`
+	RequestedBy uuid.UUID            `json:"requested_by" gorm:"type:char(36);index;not null"`
+	FileName    string               `json:"file_name"`
+	Format      string               `json:"format" gorm:"size:8;not null"`
+	Headers     string               `json:"-" gorm:"type:text;comment:'JSON array of the header row'"`
+	Records     string               `json:"-" gorm:"type:longtext;comment:'JSON array of data rows'"`
+	Mapping     string               `json:"-" gorm:"type:text;comment:'JSON object of field to header'"`
+	OnMatch     string               `json:"on_match" gorm:"size:8;default:'update'"`
+	OnNew       string               `json:"on_new" gorm:"size:8;default:'create'"`
+	Status      CustomerImportStatus `json:"status" gorm:"type:enum('draft','pending','running','completed','failed');default:'draft';index"`
+	Total       int                  `json:"total"`
+	Processed   int                  `json:"processed"`
+	Created     int                  `json:"created"`
+	Updated     int                  `json:"updated"`
+	Skipped     int                  `json:"skipped"`
+	Failed      int                  `json:"failed"`
+	ErrorPath   string               `json:"-" gorm:"comment:'Storage object of the CSV of failed rows'"`
+	Error       string               `json:"error" gorm:"type:text"`
+	StartedAt   *time.Time           `json:"started_at"`
+	CompletedAt *time.Time           `json:"completed_at"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+func (j *CustomerImportJob) BeforeCreate(tx *gorm.DB) (err error) {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return
+}
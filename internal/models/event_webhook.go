@@ -0,0 +1,106 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EventType string
+
+const (
+	EventMessageReceived      EventType = "message.received"
+	EventMessageStatusChanged EventType = "message.status_changed"
+	EventConversationCreated  EventType = "conversation.created"
+	EventConversationAssigned EventType = "conversation.assigned"
+	EventConversationClosed   EventType = "conversation.closed"
+	EventCustomerCreated      EventType = "customer.created"
+	EventCustomerUpdated      EventType = "customer.updated"
+	EventSLAWarning           EventType = "sla.warning"
+	EventSLABreached          EventType = "sla.breached"
+)
+
+// AllEventTypes lists every event an outbound webhook can subscribe to
+var AllEventTypes = []EventType{
+	EventMessageReceived,
+	EventMessageStatusChanged,
+	EventConversationCreated,
+	EventConversationAssigned,
+	EventConversationClosed,
+	EventCustomerCreated,
+	EventCustomerUpdated,
+	EventSLAWarning,
+	EventSLABreached,
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending DeliveryStatus = "pending"
+	DeliveryStatusSuccess DeliveryStatus = "success"
+	DeliveryStatusFailed  DeliveryStatus = "failed"
+)
+
+// WebhookSubscription is an external endpoint that receives signed CRM events
+type WebhookSubscription struct {
+	ID                  uuid.UUID  `json:"id" gorm:"type:char(36);primaryKey"`
+	Name                string     `json:"name" gorm:"not null"`
+	URL                 string     `json:"url" gorm:"not null"`
+	Secret              string     `json:"secret,omitempty" gorm:"not null"`
+	Events              string     `json:"events" gorm:"type:text;comment:'Comma-separated event types, * for all'"`
+	IsActive            bool       `json:"is_active" gorm:"default:true"`
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"default:0"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	LastDeliveryAt      *time.Time `json:"last_delivery_at"`
+	CreatedBy           uuid.UUID  `json:"created_by" gorm:"type:char(36);index"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Subscribes reports whether the subscription wants the given event
+func (s *WebhookSubscription) Subscribes(event EventType) bool {
+	for _, e := range strings.Split(s.Events, ",") {
+		e = strings.TrimSpace(e)
+		if e == "*" || e == string(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *WebhookSubscription) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return
+}
+
+// WebhookDelivery is one event sent (or to be sent) to a subscription
+type WebhookDelivery struct {
+	ID             uuid.UUID      `json:"id" gorm:"type:char(36);primaryKey"`
+	SubscriptionID uuid.UUID      `json:"subscription_id" gorm:"type:char(36);index;not null"`
+	EventID        uuid.UUID      `json:"event_id" gorm:"type:char(36);index"`
+	EventType      EventType      `json:"event_type" gorm:"index;not null"`
+	Status         DeliveryStatus `json:"status" gorm:"type:enum('pending','success','failed');default:'pending';index"`
+	Payload        string         `json:"payload" gorm:"type:longtext;not null"`
+	Attempts       int            `json:"attempts" gorm:"default:0"`
+	ResponseCode   int            `json:"response_code"`
+	ResponseBody   string         `json:"response_body" gorm:"type:text"`
+	ErrorMessage   string         `json:"error_message" gorm:"type:text"`
+	NextAttemptAt  *time.Time     `json:"next_attempt_at" gorm:"index"`
+	DeliveredAt    *time.Time     `json:"delivered_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+
+	// Relationships
+	Subscription WebhookSubscription `json:"-" gorm:"foreignKey:SubscriptionID"`
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) (err error) {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return
+}
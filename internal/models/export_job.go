@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ExportJobStatus string
+
+const (
+	ExportJobPending   ExportJobStatus = "pending"
+	ExportJobRunning   ExportJobStatus = "running"
+	ExportJobCompleted ExportJobStatus = "completed"
+	ExportJobFailed    ExportJobStatus = "failed"
+)
+
+// ExportJob is a bulk transcript export. The worker writes one transcript per
+// conversation into a zip that is kept in storage.
+type ExportJob struct {
+	ID              uuid.UUID       `json:"id" gorm:"type:char(36);primaryKey"`
+	RequestedBy     uuid.UUID       `json:"requested_by" gorm:"type:char(36);index;not null"`
+	Format          string          `json:"format" gorm:"size:8;not null"`
+	IncludeNotes    bool            `json:"include_notes" gorm:"default:false"`
+	ConversationIDs string          `json:"-" gorm:"type:longtext;not null;comment:'JSON array of conversation ids'"`
+	Status          ExportJobStatus `json:"status" gorm:"type:enum('pending','running','completed','failed');default:'pending';index"`
+	Total           int             `json:"total"`
+	Processed       int             `json:"processed"`
+	FilePath        string          `json:"-" gorm:"comment:'Storage object of the zip'"`
+	Error           string          `json:"error" gorm:"type:text"`
+	StartedAt       *time.Time      `json:"started_at"`
+	CompletedAt     *time.Time      `json:"completed_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+func (j *ExportJob) BeforeCreate(tx *gorm.DB) (err error) {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return
+}
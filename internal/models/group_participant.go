@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GroupRole string
+
+const (
+	GroupRoleMember GroupRole = "member"
+	GroupRoleAdmin  GroupRole = "admin"
+)
+
+// GroupParticipant is a member of a WhatsApp group contact
+type GroupParticipant struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:char(36);primaryKey"`
+	ContactID  uuid.UUID  `json:"contact_id" gorm:"type:char(36);uniqueIndex:idx_group_participant;not null"`
+	WhatsAppID string     `json:"whatsapp_id" gorm:"size:191;uniqueIndex:idx_group_participant;not null"`
+	Name       string     `json:"name"`
+	Role       GroupRole  `json:"role" gorm:"type:enum('member','admin');default:'member'"`
+	JoinedAt   *time.Time `json:"joined_at"`
+	LeftAt     *time.Time `json:"left_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (p *GroupParticipant) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return
+}
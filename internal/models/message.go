@@ -10,15 +10,16 @@ import (
 type MessageType string
 
 const (
-	MessageTypeText     MessageType = "text"
-	MessageTypeImage    MessageType = "image"
-	MessageTypeDocument MessageType = "document"
-	MessageTypeAudio    MessageType = "audio"
-	MessageTypeVideo    MessageType = "video"
-	MessageTypeSticker  MessageType = "sticker"
-	MessageTypeLocation MessageType = "location"
-	MessageTypeContact  MessageType = "contact"
-	MessageTypeTemplate MessageType = "template"
+	MessageTypeText        MessageType = "text"
+	MessageTypeImage       MessageType = "image"
+	MessageTypeDocument    MessageType = "document"
+	MessageTypeAudio       MessageType = "audio"
+	MessageTypeVideo       MessageType = "video"
+	MessageTypeSticker     MessageType = "sticker"
+	MessageTypeLocation    MessageType = "location"
+	MessageTypeContact     MessageType = "contact"
+	MessageTypeTemplate    MessageType = "template"
+	MessageTypeInteractive MessageType = "interactive"
 )
 
 type MessageDirection string
@@ -39,35 +40,50 @@ const (
 )
 
 type Message struct {
-	ID             uuid.UUID        `json:"id" gorm:"type:char(36);primaryKey"`
-	ConversationID uuid.UUID        `json:"conversation_id" gorm:"type:char(36);index;not null"`
-	WhatsAppID     string           `json:"whatsapp_id" gorm:"uniqueIndex"`
-	Type           MessageType      `json:"type" gorm:"type:enum('text','image','document','audio','video','sticker','location','contact','template');not null"`
-	Direction      MessageDirection `json:"direction" gorm:"type:enum('inbound','outbound');not null"`
-	Status         MessageStatus    `json:"status" gorm:"type:enum('sent','delivered','read','failed','pending');default:'pending'"`
-	Content        string           `json:"content" gorm:"type:text"`
-	MediaURL       string           `json:"media_url"`
-	MediaMimeType  string           `json:"media_mime_type"`
-	MediaSize      int64            `json:"media_size"`
-	FileName       string           `json:"file_name"`
-	Caption        string           `json:"caption"`
-	Latitude       float64          `json:"latitude"`
-	Longitude      float64          `json:"longitude"`
-	LocationName   string           `json:"location_name"`
-	ContactName    string           `json:"contact_name"`
-	ContactPhone   string           `json:"contact_phone"`
-	QuotedID       *uuid.UUID       `json:"quoted_id" gorm:"type:char(36);index"`
-	TemplateID     *uuid.UUID       `json:"template_id" gorm:"type:char(36);index"`
-	SentAt         *time.Time       `json:"sent_at"`
-	DeliveredAt    *time.Time       `json:"delivered_at"`
-	ReadAt         *time.Time       `json:"read_at"`
-	CreatedAt      time.Time        `json:"created_at"`
-	UpdatedAt      time.Time        `json:"updated_at"`
+	ID                   uuid.UUID        `json:"id" gorm:"type:char(36);primaryKey"`
+	ConversationID       uuid.UUID        `json:"conversation_id" gorm:"type:char(36);index;not null"`
+	WhatsAppID           string           `json:"whatsapp_id" gorm:"uniqueIndex;default:null"`
+	Type                 MessageType      `json:"type" gorm:"type:enum('text','image','document','audio','video','sticker','location','contact','template','interactive');not null"`
+	Direction            MessageDirection `json:"direction" gorm:"type:enum('inbound','outbound');not null"`
+	Status               MessageStatus    `json:"status" gorm:"type:enum('sent','delivered','read','failed','pending');default:'pending'"`
+	Content              string           `json:"content" gorm:"type:text;index:idx_message_search,class:FULLTEXT"`
+	MediaURL             string           `json:"media_url"`
+	MediaPath            string           `json:"media_path" gorm:"comment:'Storage object of media we hold, signed again when exported'"`
+	MediaMimeType        string           `json:"media_mime_type"`
+	MediaSize            int64            `json:"media_size"`
+	FileName             string           `json:"file_name" gorm:"index:idx_message_search,class:FULLTEXT"`
+	Caption              string           `json:"caption" gorm:"index:idx_message_search,class:FULLTEXT"`
+	Latitude             float64          `json:"latitude"`
+	Longitude            float64          `json:"longitude"`
+	LocationName         string           `json:"location_name"`
+	ContactName          string           `json:"contact_name"`
+	ContactPhone         string           `json:"contact_phone"`
+	ParticipantID        string           `json:"participant_id" gorm:"index;comment:'Group member who sent an inbound group message'"`
+	ParticipantName      string           `json:"participant_name"`
+	QuotedID             *uuid.UUID       `json:"quoted_id" gorm:"type:char(36);index"`
+	TemplateID           *uuid.UUID       `json:"template_id" gorm:"type:char(36);index"`
+	CannedResponseID     *uuid.UUID       `json:"canned_response_id" gorm:"type:char(36);index"`
+	Automated            bool             `json:"automated" gorm:"default:false;comment:'Sent by the system rather than an agent'"`
+	SentByID             *uuid.UUID       `json:"sent_by_id" gorm:"type:char(36);index;comment:'Agent who sent an outbound message'"`
+	ByNonAssignee        bool             `json:"by_non_assignee" gorm:"default:false;comment:'Sent by an agent while the conversation was assigned to someone else'"`
+	Imported             bool             `json:"imported" gorm:"default:false;index;comment:'History copied from a chat export, never sent by us'"`
+	SentAt               *time.Time       `json:"sent_at"`
+	DeliveredAt          *time.Time       `json:"delivered_at"`
+	ReadAt               *time.Time       `json:"read_at"`
+	FailedAt             *time.Time       `json:"failed_at"`
+	ErrorCode            string           `json:"error_code" gorm:"index"`
+	ErrorTitle           string           `json:"error_title"`
+	ErrorDetails         string           `json:"error_details" gorm:"type:text"`
+	ResponseTime         *int             `json:"response_time" gorm:"comment:'Seconds the customer waited for this reply'"`
+	ResponseTimeBusiness *int             `json:"response_time_business" gorm:"comment:'Same, counted in business hours'"`
+	CreatedAt            time.Time        `json:"created_at"`
+	UpdatedAt            time.Time        `json:"updated_at"`
 
 	// Relationships
-	Conversation Conversation `json:"conversation,omitempty" gorm:"foreignKey:ConversationID"`
-	QuotedMessage *Message    `json:"quoted_message,omitempty" gorm:"foreignKey:QuotedID"`
-	Template     *Template   `json:"template,omitempty" gorm:"foreignKey:TemplateID"`
+	Conversation  Conversation `json:"conversation,omitempty" gorm:"foreignKey:ConversationID"`
+	QuotedMessage *Message     `json:"quoted_message,omitempty" gorm:"foreignKey:QuotedID"`
+	Template      *Template    `json:"template,omitempty" gorm:"foreignKey:TemplateID"`
+	SentBy        *User        `json:"sent_by,omitempty" gorm:"foreignKey:SentByID"`
 }
 
 func (m *Message) BeforeCreate(tx *gorm.DB) (err error) {
@@ -75,4 +91,4 @@ func (m *Message) BeforeCreate(tx *gorm.DB) (err error) {
 		m.ID = uuid.New()
 	}
 	return
-}
\ No newline at end of file
+}
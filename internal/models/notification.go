@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NotificationType string
+
+const (
+	NotificationMention NotificationType = "mention"
+)
+
+// Notification is something a user should look at, such as being @mentioned in a note
+type Notification struct {
+	ID             uuid.UUID        `json:"id" gorm:"type:char(36);primaryKey"`
+	UserID         uuid.UUID        `json:"user_id" gorm:"type:char(36);index:idx_notification_user;not null"`
+	Type           NotificationType `json:"type" gorm:"type:enum('mention');not null"`
+	ConversationID *uuid.UUID       `json:"conversation_id" gorm:"type:char(36);index"`
+	NoteID         *uuid.UUID       `json:"note_id" gorm:"type:char(36);index"`
+	ActorID        *uuid.UUID       `json:"actor_id" gorm:"type:char(36)"`
+	Message        string           `json:"message" gorm:"type:text"`
+	ReadAt         *time.Time       `json:"read_at" gorm:"index:idx_notification_user"`
+	CreatedAt      time.Time        `json:"created_at"`
+
+	// Relationships
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+func (n *Notification) BeforeCreate(tx *gorm.DB) (err error) {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return
+}
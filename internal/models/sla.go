@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SLABreachAction string
+
+const (
+	SLAActionNone         SLABreachAction = "none"
+	SLAActionBumpPriority SLABreachAction = "bump_priority"
+	SLAActionReassign     SLABreachAction = "reassign"
+)
+
+type SLATarget string
+
+const (
+	SLATargetFirstResponse SLATarget = "first_response"
+	SLATargetResolution    SLATarget = "resolution"
+)
+
+type SLAEventKind string
+
+const (
+	SLAEventWarning SLAEventKind = "warning"
+	SLAEventBreach  SLAEventKind = "breach"
+)
+
+// SLAPolicy sets response and resolution targets, in business minutes, for a
+// priority. A policy with a team only applies to that team's conversations and
+// wins over the team-less policy for the same priority.
+type SLAPolicy struct {
+	ID                   uuid.UUID            `json:"id" gorm:"type:char(36);primaryKey"`
+	Name                 string               `json:"name" gorm:"not null"`
+	Priority             ConversationPriority `json:"priority" gorm:"type:enum('low','medium','high','urgent');not null;index"`
+	TeamID               *uuid.UUID           `json:"team_id" gorm:"type:char(36);index"`
+	FirstResponseMinutes int                  `json:"first_response_minutes" gorm:"not null"`
+	ResolutionMinutes    int                  `json:"resolution_minutes" gorm:"not null"`
+	WarnBeforeMinutes    int                  `json:"warn_before_minutes" gorm:"default:15;comment:'Flag as approaching this many minutes before a target'"`
+	BreachAction         SLABreachAction      `json:"breach_action" gorm:"type:enum('none','bump_priority','reassign');default:'none'"`
+	IsActive             bool                 `json:"is_active" gorm:"default:true"`
+	CreatedAt            time.Time            `json:"created_at"`
+	UpdatedAt            time.Time            `json:"updated_at"`
+
+	// Relationships
+	Team *Team `json:"team,omitempty" gorm:"foreignKey:TeamID"`
+}
+
+// SLAEvent records a target approaching or being missed. The unique index on
+// the due time keeps the checker from flagging the same deadline twice.
+type SLAEvent struct {
+	ID             uuid.UUID            `json:"id" gorm:"type:char(36);primaryKey"`
+	ConversationID uuid.UUID            `json:"conversation_id" gorm:"type:char(36);not null;uniqueIndex:idx_sla_event"`
+	PolicyID       uuid.UUID            `json:"policy_id" gorm:"type:char(36);index;not null"`
+	Target         SLATarget            `json:"target" gorm:"type:enum('first_response','resolution');not null;uniqueIndex:idx_sla_event"`
+	Kind           SLAEventKind         `json:"kind" gorm:"type:enum('warning','breach');not null;uniqueIndex:idx_sla_event"`
+	DueAt          time.Time            `json:"due_at" gorm:"not null;uniqueIndex:idx_sla_event"`
+	Priority       ConversationPriority `json:"priority" gorm:"type:enum('low','medium','high','urgent')"`
+	TeamID         *uuid.UUID           `json:"team_id" gorm:"type:char(36);index"`
+	AgentID        *uuid.UUID           `json:"agent_id" gorm:"type:char(36);index"`
+	Action         SLABreachAction      `json:"action" gorm:"type:enum('none','bump_priority','reassign');default:'none'"`
+	CreatedAt      time.Time            `json:"created_at" gorm:"index"`
+
+	// Relationships
+	Conversation *Conversation `json:"conversation,omitempty" gorm:"foreignKey:ConversationID"`
+	Policy       *SLAPolicy    `json:"policy,omitempty" gorm:"foreignKey:PolicyID"`
+}
+
+func (p *SLAPolicy) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return
+}
+
+func (e *SLAEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
+
+// NextPriority is one step more urgent than p; urgent stays urgent
+func NextPriority(p ConversationPriority) ConversationPriority {
+	switch p {
+	case PriorityLow:
+		return PriorityMedium
+	case PriorityMedium:
+		return PriorityHigh
+	default:
+		return PriorityUrgent
+	}
+}
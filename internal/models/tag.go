@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TagScope limits which records a tag may be put on
+type TagScope string
+
+const (
+	TagScopeAll          TagScope = "all"
+	TagScopeCustomer     TagScope = "customer"
+	TagScopeConversation TagScope = "conversation"
+)
+
+type Tag struct {
+	ID        uuid.UUID `json:"id" gorm:"type:char(36);primaryKey"`
+	Name      string    `json:"name" gorm:"size:100;uniqueIndex;not null"`
+	Color     string    `json:"color" gorm:"size:7;default:'#6b7280'"`
+	Scope     TagScope  `json:"scope" gorm:"type:enum('all','customer','conversation');default:'all'"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (t *Tag) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+// Allows reports whether the tag may be put on records of the given scope
+func (t *Tag) Allows(scope TagScope) bool {
+	return t.Scope == TagScopeAll || t.Scope == scope
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Team groups agents that share a queue (sales, billing, tech support)
+type Team struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:char(36);primaryKey"`
+	Name        string     `json:"name" gorm:"size:191;uniqueIndex;not null"`
+	Description string     `json:"description" gorm:"type:text"`
+	Skills      string     `json:"skills" gorm:"type:text;comment:'Comma-separated skills'"`
+	AutoAssign  bool       `json:"auto_assign" gorm:"default:false;comment:'Route to a member automatically instead of waiting for pickup'"`
+	IsActive    bool       `json:"is_active" gorm:"default:true"`
+	ScheduleID  *uuid.UUID `json:"schedule_id" gorm:"type:char(36);index;comment:'Business schedule; null uses the default'"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relationships
+	Members []User `json:"members,omitempty" gorm:"many2many:team_members"`
+}
+
+func (t *Team) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+type RoutingRuleType string
+
+const (
+	RuleKeyword     RoutingRuleType = "keyword"
+	RuleCustomerTag RoutingRuleType = "customer_tag"
+	RuleEntryNumber RoutingRuleType = "entry_number"
+	RuleMenuChoice  RoutingRuleType = "menu_choice"
+)
+
+// RoutingRule sends matching conversations to a team queue; lower Position is evaluated first
+type RoutingRule struct {
+	ID        uuid.UUID       `json:"id" gorm:"type:char(36);primaryKey"`
+	TeamID    uuid.UUID       `json:"team_id" gorm:"type:char(36);index;not null"`
+	Name      string          `json:"name"`
+	Type      RoutingRuleType `json:"type" gorm:"type:enum('keyword','customer_tag','entry_number','menu_choice');not null"`
+	Pattern   string          `json:"pattern" gorm:"type:text;not null;comment:'Comma-separated values, any of which matches'"`
+	Position  int             `json:"position" gorm:"default:0;index"`
+	IsActive  bool            `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+
+	// Relationships
+	Team Team `json:"team,omitempty" gorm:"foreignKey:TeamID"`
+}
+
+func (r *RoutingRule) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
@@ -21,22 +21,46 @@ const (
 	UserStatusActive   UserStatus = "active"
 	UserStatusInactive UserStatus = "inactive"
 	UserStatusOnline   UserStatus = "online"
+	UserStatusAway     UserStatus = "away"
 	UserStatusOffline  UserStatus = "offline"
 )
 
+// PresenceStatus is what an agent reports about their availability
+type PresenceStatus string
+
+const (
+	PresenceAvailable PresenceStatus = "available"
+	PresenceAway      PresenceStatus = "away"
+	PresenceOffline   PresenceStatus = "offline"
+)
+
+// UserStatus maps a presence to the value mirrored on the user row
+func (p PresenceStatus) UserStatus() UserStatus {
+	switch p {
+	case PresenceAvailable:
+		return UserStatusOnline
+	case PresenceAway:
+		return UserStatusAway
+	default:
+		return UserStatusOffline
+	}
+}
+
 type User struct {
-	ID        uuid.UUID  `json:"id" gorm:"type:char(36);primaryKey"`
-	Email     string     `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string     `json:"-" gorm:"not null"`
-	Name      string     `json:"name" gorm:"not null"`
-	Phone     string     `json:"phone" gorm:"index"`
-	Role      UserRole   `json:"role" gorm:"type:enum('admin','agent','supervisor');default:'agent'"`
-	Status    UserStatus `json:"status" gorm:"type:enum('active','inactive','online','offline');default:'active'"`
-	Avatar    string     `json:"avatar"`
-	LastLogin *time.Time `json:"last_login"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID            uuid.UUID      `json:"id" gorm:"type:char(36);primaryKey"`
+	Email         string         `json:"email" gorm:"uniqueIndex;not null"`
+	Password      string         `json:"-" gorm:"not null"`
+	Name          string         `json:"name" gorm:"not null"`
+	Phone         string         `json:"phone" gorm:"index"`
+	Role          UserRole       `json:"role" gorm:"type:enum('admin','agent','supervisor');default:'agent'"`
+	Status        UserStatus     `json:"status" gorm:"type:enum('active','inactive','online','away','offline');default:'active'"`
+	Avatar        string         `json:"avatar"`
+	LastLogin     *time.Time     `json:"last_login"`
+	PresenceAt    *time.Time     `json:"presence_at"`
+	MaxConcurrent int            `json:"max_concurrent" gorm:"default:0;comment:'Routing cap, 0 uses ROUTING_MAX_CONCURRENT'"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
 	Conversations []Conversation `json:"conversations,omitempty" gorm:"foreignKey:AgentID"`
@@ -47,4 +71,4 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 		u.ID = uuid.New()
 	}
 	return
-}
\ No newline at end of file
+}
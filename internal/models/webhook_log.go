@@ -15,6 +15,7 @@ const (
 	WebhookEventPresence      WebhookEventType = "presence"
 	WebhookEventTyping        WebhookEventType = "typing"
 	WebhookEventContact       WebhookEventType = "contact"
+	WebhookEventGroup         WebhookEventType = "group"
 )
 
 type WebhookStatus string
@@ -28,7 +29,7 @@ const (
 
 type WebhookLog struct {
 	ID           uuid.UUID        `json:"id" gorm:"type:char(36);primaryKey"`
-	EventType    WebhookEventType `json:"event_type" gorm:"type:enum('message','message_status','presence','typing','contact');not null"`
+	EventType    WebhookEventType `json:"event_type" gorm:"type:enum('message','message_status','presence','typing','contact','group');not null"`
 	Status       WebhookStatus    `json:"status" gorm:"type:enum('received','processed','failed','ignored');default:'received'"`
 	Payload      string           `json:"payload" gorm:"type:longtext;not null"`
 	Response     string           `json:"response" gorm:"type:text"`
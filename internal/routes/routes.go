@@ -1,9 +1,11 @@
 package routes
 
 import (
+	"context"
 	"whatsapp-crm/internal/config"
 	"whatsapp-crm/internal/controllers"
 	"whatsapp-crm/internal/middlewares"
+	"whatsapp-crm/internal/models"
 	"whatsapp-crm/internal/services"
 	"whatsapp-crm/internal/storage"
 
@@ -19,9 +21,30 @@ func Setup(app *fiber.App, db *gorm.DB, rdb *redis.Client, cfg *config.Config) {
 	authMw := middlewares.NewAuthMiddleware(db)
 
 	// Services
-	customerSvc := services.NewCustomerService(db)
-	conversationSvc := services.NewConversationService(db)
-	messageSvc := services.NewMessageService(db, cfg)
+	eventDispatcher := services.NewEventDispatcher(db, cfg)
+	customerSvc := services.NewCustomerService(db, eventDispatcher)
+	presenceSvc := services.NewPresenceService(db, rdb, cfg)
+	collisionSvc := services.NewCollisionService(db, rdb, cfg)
+	teamSvc := services.NewTeamService(db)
+	routingSvc := services.NewRoutingService(db, rdb, presenceSvc, teamSvc, cfg)
+	hoursSvc := services.NewBusinessHoursService(db, cfg)
+	slaSvc := services.NewSLAService(db, eventDispatcher, hoursSvc, cfg)
+	conversationSvc := services.NewConversationService(db, eventDispatcher, routingSvc, teamSvc, slaSvc, hoursSvc, cfg)
+	slaSvc.OnBreach(conversationSvc.Escalate)
+	presenceSvc.OnChange(func(change services.PresenceChange) {
+		// an agent coming back can pick up queued conversations
+		if change.Status == models.PresenceAvailable { go conversationSvc.DrainQueue() }
+	})
+	// so do conversations held back while their team was closed
+	hoursSvc.OnOpen(func() { go conversationSvc.DrainQueue() })
+	messageSvc := services.NewMessageService(db, cfg, hoursSvc)
+	outOfOfficeSvc := services.NewOutOfOfficeService(hoursSvc, messageSvc)
+	chatbotSvc := services.NewChatbotService(db, conversationSvc, messageSvc)
+	autoCloseSvc := services.NewAutoCloseService(conversationSvc, messageSvc, cfg)
+	notificationSvc := services.NewNotificationService(db, rdb)
+	noteSvc := services.NewNoteService(db, notificationSvc)
+	tagSvc := services.NewTagService(db)
+	searchSvc := services.NewSearchService(db)
 
 	// Storage factory
 	var store storage.Storage
@@ -34,15 +57,48 @@ func Setup(app *fiber.App, db *gorm.DB, rdb *redis.Client, cfg *config.Config) {
 		store = storage.NewLocalStorage(cfg.UploadPath, cfg.PublicBaseURL)
 	}
 	mediaUploader := services.NewMediaUploader(store, cfg)
+	transcriptSvc := services.NewTranscriptService(db, store, cfg)
+	chatImportSvc := services.NewChatImportService(db, store, cfg)
+	customerImportSvc := services.NewCustomerImportService(db, store, eventDispatcher, cfg)
+	csatSvc := services.NewCSATService(db, messageSvc, cfg)
+	conversationSvc.OnClosed(csatSvc.Send)
+	cannedSvc := services.NewCannedResponseService(db, messageSvc, mediaUploader)
 
 	// Controllers
 	authCtl := controllers.NewAuthController(db)
 	userCtl := controllers.NewUserController(db)
 	customerCtl := controllers.NewCustomerController(db, customerSvc)
-	conversationCtl := controllers.NewConversationController(db, conversationSvc, messageSvc)
-	messageCtl := controllers.NewMessageController(db, messageSvc)
-	webhookCtl := controllers.NewWebhookController(db, cfg, messageSvc, customerSvc, conversationSvc)
-	uploadCtl := controllers.NewUploadController(db, mediaUploader, cfg)
+	conversationCtl := controllers.NewConversationController(db, conversationSvc, messageSvc, teamSvc, cfg)
+	messageCtl := controllers.NewMessageController(db, messageSvc, cannedSvc)
+	webhookCtl := controllers.NewWebhookController(db, cfg, messageSvc, customerSvc, conversationSvc, outOfOfficeSvc, chatbotSvc, csatSvc, eventDispatcher)
+	uploadCtl := controllers.NewUploadController(db, mediaUploader, messageSvc, cfg)
+	eventWebhookCtl := controllers.NewEventWebhookController(db, eventDispatcher)
+	presenceCtl := controllers.NewPresenceController(presenceSvc)
+	collisionCtl := controllers.NewCollisionController(db, collisionSvc)
+	teamCtl := controllers.NewTeamController(db, teamSvc)
+	slaCtl := controllers.NewSLAController(db, slaSvc)
+	hoursCtl := controllers.NewBusinessHoursController(db, hoursSvc)
+	chatbotCtl := controllers.NewChatbotController(db, chatbotSvc)
+	noteCtl := controllers.NewNoteController(noteSvc)
+	tagCtl := controllers.NewTagController(db, tagSvc)
+	searchCtl := controllers.NewSearchController(searchSvc)
+	cannedCtl := controllers.NewCannedResponseController(cannedSvc, mediaUploader)
+	notificationCtl := controllers.NewNotificationController(notificationSvc)
+	exportCtl := controllers.NewExportController(transcriptSvc)
+	chatImportCtl := controllers.NewChatImportController(chatImportSvc, cfg)
+	customerImportCtl := controllers.NewCustomerImportController(customerImportSvc, customerSvc)
+	csatCtl := controllers.NewCSATController(csatSvc)
+
+	// Background workers
+	go eventDispatcher.Run(context.Background())
+	go presenceSvc.Run(context.Background())
+	go hoursSvc.Run(context.Background())
+	go slaSvc.Run(context.Background())
+	go conversationSvc.Run(context.Background())
+	go autoCloseSvc.Run(context.Background())
+	go transcriptSvc.Run(context.Background())
+	go customerImportSvc.Run(context.Background())
+	go csatSvc.Run(context.Background())
 
 	// Auth
 	auth := api.Group("/auth")
@@ -63,30 +119,173 @@ func Setup(app *fiber.App, db *gorm.DB, rdb *redis.Client, cfg *config.Config) {
 	customers := api.Group("/customers", authMw.RequireAuth)
 	customers.Get("/", customerCtl.List)
 	customers.Post("/", customerCtl.Create)
+	customers.Get("/export", authMw.RequireRole("admin", "supervisor"), customerImportCtl.Export)
+	customers.Post("/imports", authMw.RequireRole("admin", "supervisor"), customerImportCtl.Upload)
+	customers.Get("/imports", authMw.RequireRole("admin", "supervisor"), customerImportCtl.Jobs)
+	customers.Get("/imports/:id", authMw.RequireRole("admin", "supervisor"), customerImportCtl.Job)
+	customers.Post("/imports/:id/preview", authMw.RequireRole("admin", "supervisor"), customerImportCtl.Preview)
+	customers.Post("/imports/:id/start", authMw.RequireRole("admin", "supervisor"), customerImportCtl.Start)
 	customers.Get("/:id", customerCtl.Detail)
 	customers.Put("/:id", customerCtl.Update)
 	customers.Delete("/:id", customerCtl.Delete)
+	customers.Post("/:id/import-chat", chatImportCtl.Import)
+	customers.Post("/:id/tags", tagCtl.AddToCustomer)
+	customers.Delete("/:id/tags/:tagId", tagCtl.RemoveFromCustomer)
+
+	// Tags: everyone can list, supervisors manage
+	tags := api.Group("/tags", authMw.RequireAuth)
+	tags.Get("/", tagCtl.List)
+	tags.Post("/", authMw.RequireRole("admin", "supervisor"), tagCtl.Create)
+	tags.Put("/:id", authMw.RequireRole("admin", "supervisor"), tagCtl.Update)
+	tags.Delete("/:id", authMw.RequireRole("admin", "supervisor"), tagCtl.Delete)
+
+	// Search
+	search := api.Group("/search", authMw.RequireAuth)
+	search.Get("/", searchCtl.Search)
 
 	// Conversations
 	convs := api.Group("/conversations", authMw.RequireAuth)
 	convs.Get("/", conversationCtl.List)
 	convs.Post("/", conversationCtl.Create)
+	convs.Get("/queue", conversationCtl.Queue)
+	convs.Get("/transfers", conversationCtl.PendingTransfers)
+	convs.Post("/transfers/:transferId/accept", conversationCtl.AcceptTransfer)
+	convs.Post("/transfers/:transferId/decline", conversationCtl.DeclineTransfer)
+	convs.Post("/exports", authMw.RequireRole("admin", "supervisor"), exportCtl.BulkExport)
+	convs.Get("/exports", authMw.RequireRole("admin", "supervisor"), exportCtl.Jobs)
+	convs.Get("/exports/:jobId", authMw.RequireRole("admin", "supervisor"), exportCtl.Job)
 	convs.Get("/:id", conversationCtl.Detail)
+	convs.Get("/:id/timeline", conversationCtl.Timeline)
+	convs.Get("/:id/viewers", collisionCtl.Viewers)
+	convs.Post("/:id/read", conversationCtl.MarkRead)
+	convs.Post("/:id/activity", collisionCtl.Activity)
+	convs.Get("/:id/export", authMw.RequireRole("admin", "supervisor"), exportCtl.Export)
+	convs.Get("/:id/notes", noteCtl.List)
+	convs.Post("/:id/notes", noteCtl.Create)
 	convs.Put("/:id/assign", conversationCtl.Assign)
+	convs.Post("/:id/route", conversationCtl.Route)
+	convs.Post("/:id/pickup", conversationCtl.PickUp)
+	convs.Post("/:id/transfer", conversationCtl.Transfer)
 	convs.Put("/:id/status", conversationCtl.UpdateStatus)
 	convs.Put("/:id/priority", conversationCtl.UpdatePriority)
-	convs.Put("/:id/notes", conversationCtl.UpdateNotes)
+	convs.Post("/:id/tags", conversationCtl.AddTag)
+	convs.Delete("/:id/tags/:tagId", conversationCtl.RemoveTag)
+	convs.Post("/:id/snooze", conversationCtl.Snooze)
+	convs.Delete("/:id/snooze", conversationCtl.Unsnooze)
+	convs.Put("/:id/control", chatbotCtl.SetControl)
 
 	// Messages
 	msgs := api.Group("/messages", authMw.RequireAuth)
+	msgs.Get("/failed", authMw.RequireRole("admin", "supervisor"), messageCtl.FailedReport)
+	msgs.Get("/response-times", authMw.RequireRole("admin", "supervisor"), messageCtl.ResponseTimes)
 	msgs.Get("/conversation/:id", messageCtl.ListByConversation)
-	msgs.Post("/conversation/:id/text", messageCtl.SendText)
-	msgs.Post("/conversation/:id/media", messageCtl.SendMedia)
-	msgs.Post("/conversation/:id/template", messageCtl.SendTemplate)
+	msgs.Post("/conversation/:id/text", collisionCtl.SoftLock("id"), messageCtl.SendText)
+	msgs.Post("/conversation/:id/media", collisionCtl.SoftLock("id"), messageCtl.SendMedia)
+	msgs.Post("/conversation/:id/template", collisionCtl.SoftLock("id"), messageCtl.SendTemplate)
 
 	// Upload (multipart upload then send)
 	upl := api.Group("/messages", authMw.RequireAuth)
-	upl.Post("/conversation/:id/upload", uploadCtl.UploadAndSend)
+	upl.Post("/conversation/:id/upload", collisionCtl.SoftLock("id"), uploadCtl.UploadAndSend)
+
+	// Teams
+	teams := api.Group("/teams", authMw.RequireAuth)
+	teams.Get("/", teamCtl.List)
+	teams.Get("/:id", teamCtl.Detail)
+	teams.Post("/", authMw.RequireRole("admin", "supervisor"), teamCtl.Create)
+	teams.Put("/:id", authMw.RequireRole("admin", "supervisor"), teamCtl.Update)
+	teams.Delete("/:id", authMw.RequireRole("admin", "supervisor"), teamCtl.Delete)
+	teams.Post("/:id/members", authMw.RequireRole("admin", "supervisor"), teamCtl.AddMember)
+	teams.Delete("/:id/members/:userId", authMw.RequireRole("admin", "supervisor"), teamCtl.RemoveMember)
+
+	// Team routing rules
+	rules := api.Group("/routing-rules", authMw.RequireAuth, authMw.RequireRole("admin", "supervisor"))
+	rules.Get("/", teamCtl.ListRules)
+	rules.Post("/", teamCtl.CreateRule)
+	rules.Put("/:id", teamCtl.UpdateRule)
+	rules.Delete("/:id", teamCtl.DeleteRule)
+
+	// SLA policies and breach report
+	sla := api.Group("/sla", authMw.RequireAuth, authMw.RequireRole("admin", "supervisor"))
+	sla.Get("/policies", slaCtl.ListPolicies)
+	sla.Post("/policies", slaCtl.CreatePolicy)
+	sla.Put("/policies/:id", slaCtl.UpdatePolicy)
+	sla.Delete("/policies/:id", slaCtl.DeletePolicy)
+	sla.Get("/breaches", slaCtl.Breaches)
+
+	// Customer satisfaction surveys
+	csat := api.Group("/csat", authMw.RequireAuth, authMw.RequireRole("admin", "supervisor"))
+	csat.Get("/report", csatCtl.Report)
+	csat.Get("/surveys", csatCtl.Surveys)
+
+	// Business hours: everyone can check status, supervisors manage schedules
+	hours := api.Group("/business-hours", authMw.RequireAuth)
+	hours.Get("/status", hoursCtl.Status)
+	hours.Get("/", authMw.RequireRole("admin", "supervisor"), hoursCtl.List)
+	hours.Post("/", authMw.RequireRole("admin", "supervisor"), hoursCtl.Create)
+	hours.Put("/:id", authMw.RequireRole("admin", "supervisor"), hoursCtl.Update)
+	hours.Delete("/:id", authMw.RequireRole("admin", "supervisor"), hoursCtl.Delete)
+	hours.Post("/:id/holidays", authMw.RequireRole("admin", "supervisor"), hoursCtl.AddHoliday)
+	hours.Delete("/:id/holidays/:holidayId", authMw.RequireRole("admin", "supervisor"), hoursCtl.RemoveHoliday)
+
+	// Chatbot rules and versioned flows (admin/supervisor)
+	bot := api.Group("/chatbot", authMw.RequireAuth, authMw.RequireRole("admin", "supervisor"))
+	bot.Get("/rules", chatbotCtl.ListRules)
+	bot.Post("/rules", chatbotCtl.CreateRule)
+	bot.Put("/rules/:id", chatbotCtl.UpdateRule)
+	bot.Delete("/rules/:id", chatbotCtl.DeleteRule)
+	bot.Get("/flows", chatbotCtl.ListFlows)
+	bot.Post("/flows", chatbotCtl.CreateFlow)
+	bot.Put("/flows/:key", chatbotCtl.UpdateFlow)
+	bot.Delete("/flows/:key", chatbotCtl.Deactivate)
+	bot.Get("/flows/:key/versions", chatbotCtl.Versions)
+	bot.Post("/flows/:key/versions/:version/activate", chatbotCtl.Activate)
+
+	// Canned responses: personal for everyone, shared managed by supervisors
+	canned := api.Group("/canned-responses", authMw.RequireAuth)
+	canned.Get("/", cannedCtl.List)
+	canned.Post("/", cannedCtl.Create)
+	canned.Get("/usage", authMw.RequireRole("admin", "supervisor"), cannedCtl.Usage)
+	canned.Put("/:id", cannedCtl.Update)
+	canned.Delete("/:id", cannedCtl.Delete)
+	canned.Post("/:id/media", cannedCtl.UploadMedia)
+	canned.Get("/:id/render", cannedCtl.Render)
+	canned.Post("/:id/send", collisionCtl.SoftLock(""), cannedCtl.Send)
+
+	// Internal notes
+	notes := api.Group("/notes", authMw.RequireAuth)
+	notes.Get("/:id", noteCtl.Detail)
+	notes.Put("/:id", noteCtl.Update)
+	notes.Delete("/:id", noteCtl.Delete)
+
+	// Notifications for the logged-in user
+	notifications := api.Group("/notifications", authMw.RequireAuth)
+	notifications.Get("/", notificationCtl.List)
+	notifications.Put("/read-all", notificationCtl.MarkAllRead)
+	notifications.Put("/:id/read", notificationCtl.MarkRead)
+
+	// Agent presence
+	presence := api.Group("/presence", authMw.RequireAuth)
+	presence.Get("/", authMw.RequireRole("admin", "supervisor"), presenceCtl.List)
+	presence.Get("/me", presenceCtl.Me)
+	presence.Put("/me", presenceCtl.Update)
+	presence.Post("/heartbeat", presenceCtl.Heartbeat)
+
+	// WebSocket (token via ?token= since browsers cannot send headers)
+	ws := api.Group("/ws", presenceCtl.RequireUpgrade, authMw.RequireAuth)
+	ws.Get("/presence", presenceCtl.Stream())
+	ws.Get("/notifications", notificationCtl.Stream())
+	ws.Get("/conversations", collisionCtl.Stream())
+
+	// Outbound event webhooks (admin only)
+	hooks := api.Group("/integrations/webhooks", authMw.RequireAuth, authMw.RequireRole("admin"))
+	hooks.Get("/events", eventWebhookCtl.ListEventTypes)
+	hooks.Get("/", eventWebhookCtl.List)
+	hooks.Post("/", eventWebhookCtl.Create)
+	hooks.Get("/:id", eventWebhookCtl.Detail)
+	hooks.Put("/:id", eventWebhookCtl.Update)
+	hooks.Delete("/:id", eventWebhookCtl.Delete)
+	hooks.Get("/:id/deliveries", eventWebhookCtl.Deliveries)
+	hooks.Post("/deliveries/:deliveryId/redeliver", eventWebhookCtl.Redeliver)
 
 	// Webhook
 	webhook := api.Group("/webhook")
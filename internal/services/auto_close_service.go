@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+)
+
+// AutoCloseService closes conversations the customer has gone quiet on
+type AutoCloseService struct {
+	conversations *ConversationService
+	messages      *MessageService
+	idle          time.Duration
+	template      string
+}
+
+func NewAutoCloseService(conversations *ConversationService, messages *MessageService, cfg *config.Config) *AutoCloseService {
+	return &AutoCloseService{
+		conversations: conversations,
+		messages:      messages,
+		idle:          time.Duration(cfg.AutoCloseIdleMinutes) * time.Minute,
+		template:      cfg.AutoCloseTemplate,
+	}
+}
+
+// Enabled reports whether an idle time is configured
+func (as *AutoCloseService) Enabled() bool {
+	return as.idle > 0
+}
+
+// Run closes idle conversations every minute until ctx is cancelled
+func (as *AutoCloseService) Run(ctx context.Context) {
+	if !as.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			as.Check(time.Now())
+		}
+	}
+}
+
+// Check closes every open conversation without a customer message since now minus the
+// idle time. Snoozed conversations are left alone until they wake up. The closing
+// template goes out once the close has gone through, so a close that fails is not
+// announced again on every pass.
+func (as *AutoCloseService) Check(now time.Time) {
+	var convs []models.Conversation
+	err := as.conversations.db.
+		Where("status <> ? AND snoozed_at IS NULL AND COALESCE(last_inbound_at, created_at) < ?", models.ConversationStatusClosed, now.Add(-as.idle)).
+		Limit(100).Find(&convs).Error
+	if err != nil {
+		log.Printf("loading idle conversations: %v", err)
+		return
+	}
+	for i := range convs {
+		conv := &convs[i]
+		if err := as.conversations.AutoClose(conv); err != nil {
+			if err != ErrStatusChanged {
+				log.Printf("auto-closing conversation %s: %v", conv.ID, err)
+			}
+			continue
+		}
+		if as.template != "" && !conv.IsGroup {
+			if _, err := as.messages.SendAutomatedTemplate(conv.ID, as.template, nil); err != nil {
+				log.Printf("closing template for conversation %s: %v", conv.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dayWindow is one opening window in minutes after local midnight
+type dayWindow struct{ Open, Close int }
+
+// BusinessHours is a weekly opening schedule in one timezone. A schedule
+// with no days configured is treated as open around the clock.
+type BusinessHours struct {
+	Location *time.Location
+	Weekly   map[time.Weekday]dayWindow
+	// Holidays are closed all day, keyed "2006-01-02", or "01-02" for every year
+	Holidays map[string]bool
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseBusinessHours reads a schedule such as "mon-fri=08:00-17:00,sat=09:00-12:00"
+func ParseBusinessHours(timezone, spec string) (*BusinessHours, error) {
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+	}
+	bh := &BusinessHours{Location: loc, Weekly: map[time.Weekday]dayWindow{}}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(strings.ToLower(part))
+		if part == "" {
+			continue
+		}
+		days, hours, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("business hours %q: expected day=HH:MM-HH:MM", part)
+		}
+		window, err := parseWindow(hours)
+		if err != nil {
+			return nil, err
+		}
+		from, to, isRange := strings.Cut(days, "-")
+		first, ok1 := weekdayNames[from]
+		last, ok2 := first, true
+		if isRange {
+			last, ok2 = weekdayNames[to]
+		}
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("business hours %q: unknown day", part)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			bh.Weekly[d] = window
+			if d == last {
+				break
+			}
+		}
+	}
+	return bh, nil
+}
+
+func parseWindow(s string) (dayWindow, error) {
+	open, close, ok := strings.Cut(s, "-")
+	if !ok {
+		return dayWindow{}, fmt.Errorf("business hours %q: expected HH:MM-HH:MM", s)
+	}
+	o, err1 := parseClock(open)
+	c, err2 := parseClock(close)
+	if err1 != nil || err2 != nil || c <= o {
+		return dayWindow{}, fmt.Errorf("business hours %q: invalid window", s)
+	}
+	return dayWindow{Open: o, Close: c}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		if strings.TrimSpace(s) == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// AlwaysOpen reports whether no schedule is configured
+func (bh *BusinessHours) AlwaysOpen() bool { return bh == nil || len(bh.Weekly) == 0 }
+
+// window returns the opening interval of the local day containing t, if any
+func (bh *BusinessHours) window(t time.Time) (time.Time, time.Time, bool) {
+	local := t.In(bh.Location)
+	w, ok := bh.Weekly[local.Weekday()]
+	if !ok || bh.Holidays[local.Format("2006-01-02")] || bh.Holidays[local.Format("01-02")] {
+		return time.Time{}, time.Time{}, false
+	}
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, bh.Location)
+	return midnight.Add(time.Duration(w.Open) * time.Minute), midnight.Add(time.Duration(w.Close) * time.Minute), true
+}
+
+func (bh *BusinessHours) nextMidnight(t time.Time) time.Time {
+	local := t.In(bh.Location)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, bh.Location)
+}
+
+// IsOpen reports whether t falls inside business hours
+func (bh *BusinessHours) IsOpen(t time.Time) bool {
+	if bh.AlwaysOpen() {
+		return true
+	}
+	open, close, ok := bh.window(t)
+	return ok && !t.Before(open) && t.Before(close)
+}
+
+// NextOpen returns t if business hours are open then, otherwise the next opening time
+func (bh *BusinessHours) NextOpen(t time.Time) time.Time {
+	if bh.IsOpen(t) {
+		return t
+	}
+	cursor := t
+	for i := 0; i < 366*2; i++ {
+		open, close, ok := bh.window(cursor)
+		if ok && cursor.Before(close) {
+			if cursor.Before(open) {
+				return open
+			}
+			return cursor
+		}
+		cursor = bh.nextMidnight(cursor)
+	}
+	return t
+}
+
+// Add returns the moment d of business time after from
+func (bh *BusinessHours) Add(from time.Time, d time.Duration) time.Time {
+	if bh.AlwaysOpen() {
+		return from.Add(d)
+	}
+	cursor := from
+	remaining := d
+	// a year of closed days means the schedule can never satisfy d
+	for i := 0; i < 366*2; i++ {
+		open, close, ok := bh.window(cursor)
+		if ok && cursor.Before(close) {
+			if cursor.Before(open) {
+				cursor = open
+			}
+			if avail := close.Sub(cursor); remaining <= avail {
+				return cursor.Add(remaining)
+			} else {
+				remaining -= avail
+			}
+		}
+		cursor = bh.nextMidnight(cursor)
+	}
+	return from.Add(d)
+}
+
+// Between returns how much business time elapsed from from to to
+func (bh *BusinessHours) Between(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+	if bh.AlwaysOpen() {
+		return to.Sub(from)
+	}
+	var total time.Duration
+	for cursor := from; cursor.Before(to); cursor = bh.nextMidnight(cursor) {
+		open, close, ok := bh.window(cursor)
+		if !ok {
+			continue
+		}
+		start, end := open, close
+		if cursor.After(start) {
+			start = cursor
+		}
+		if to.Before(end) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrScheduleName  = errors.New("schedule name is required")
+	ErrScheduleTaken = errors.New("a schedule with that name already exists")
+	ErrHolidayDate   = errors.New("holiday date must be YYYY-MM-DD")
+)
+
+// AutoReply is the out-of-office reply configured for a schedule
+type AutoReply struct {
+	Enabled  bool   `json:"enabled"`
+	Message  string `json:"message"`
+	Template string `json:"template"`
+}
+
+type compiledSchedule struct {
+	hours *BusinessHours
+	reply AutoReply
+}
+
+// BusinessHoursService resolves the business hours that apply to a team. Schedules
+// live in the database and are cached; the BUSINESS_* settings are used when no
+// default schedule is stored.
+type BusinessHoursService struct {
+	db       *gorm.DB
+	fallback compiledSchedule
+
+	mu        sync.RWMutex
+	schedules map[uuid.UUID]*compiledSchedule
+	defaultID *uuid.UUID
+	open      map[uuid.UUID]bool
+	listeners []func()
+}
+
+func NewBusinessHoursService(db *gorm.DB, cfg *config.Config) *BusinessHoursService {
+	hours, err := ParseBusinessHours(cfg.BusinessTimezone, cfg.BusinessHours)
+	if err != nil {
+		log.Printf("business hours: %v; falling back to 24/7", err)
+		hours = nil
+	}
+	if hours != nil {
+		hours.Holidays = map[string]bool{}
+		for _, d := range splitHolidays(cfg.BusinessHolidays) {
+			hours.Holidays[d] = true
+		}
+	}
+	hs := &BusinessHoursService{
+		db: db,
+		fallback: compiledSchedule{hours: hours, reply: AutoReply{
+			Enabled:  cfg.OutOfOfficeMessage != "" || cfg.OutOfOfficeTemplate != "",
+			Message:  cfg.OutOfOfficeMessage,
+			Template: cfg.OutOfOfficeTemplate,
+		}},
+		open: map[uuid.UUID]bool{},
+	}
+	if err := hs.Reload(); err != nil {
+		log.Printf("loading business schedules: %v", err)
+	}
+	return hs
+}
+
+// splitHolidays reads BUSINESS_HOLIDAYS, e.g. "2026-03-20,12-25"
+func splitHolidays(s string) []string {
+	var days []string
+	for _, d := range strings.Split(s, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// Reload recompiles the stored schedules
+func (hs *BusinessHoursService) Reload() error {
+	var rows []models.BusinessSchedule
+	if err := hs.db.Preload("Holidays").Find(&rows).Error; err != nil {
+		return err
+	}
+	schedules := make(map[uuid.UUID]*compiledSchedule, len(rows))
+	var defaultID *uuid.UUID
+	for i := range rows {
+		s := &rows[i]
+		compiled, err := compileSchedule(s)
+		if err != nil {
+			log.Printf("business schedule %s: %v", s.Name, err)
+			continue
+		}
+		schedules[s.ID] = compiled
+		if s.IsDefault {
+			defaultID = &s.ID
+		}
+	}
+	hs.mu.Lock()
+	hs.schedules, hs.defaultID = schedules, defaultID
+	hs.mu.Unlock()
+	return nil
+}
+
+func compileSchedule(s *models.BusinessSchedule) (*compiledSchedule, error) {
+	hours, err := ParseBusinessHours(s.Timezone, s.Hours)
+	if err != nil {
+		return nil, err
+	}
+	hours.Holidays = make(map[string]bool, len(s.Holidays))
+	for i := range s.Holidays {
+		hours.Holidays[s.Holidays[i].Key()] = true
+	}
+	return &compiledSchedule{hours: hours, reply: AutoReply{
+		Enabled:  s.AutoReplyEnabled,
+		Message:  s.AutoReplyMessage,
+		Template: s.AutoReplyTemplate,
+	}}, nil
+}
+
+// schedule returns the team's schedule, or the default one
+func (hs *BusinessHoursService) schedule(teamID *uuid.UUID) *compiledSchedule {
+	var scheduleID *uuid.UUID
+	if teamID != nil {
+		var team models.Team
+		if err := hs.db.Select("id, schedule_id").First(&team, "id = ?", *teamID).Error; err == nil {
+			scheduleID = team.ScheduleID
+		}
+	}
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	if scheduleID != nil {
+		if s, ok := hs.schedules[*scheduleID]; ok {
+			return s
+		}
+	}
+	if hs.defaultID != nil {
+		if s, ok := hs.schedules[*hs.defaultID]; ok {
+			return s
+		}
+	}
+	return &hs.fallback
+}
+
+// ForTeam returns the business hours a team works, nil meaning 24/7
+func (hs *BusinessHoursService) ForTeam(teamID *uuid.UUID) *BusinessHours {
+	if hs == nil {
+		return nil
+	}
+	return hs.schedule(teamID).hours
+}
+
+// AutoReplyFor returns the out-of-office reply and hours that apply to a team
+func (hs *BusinessHoursService) AutoReplyFor(teamID *uuid.UUID) (AutoReply, *BusinessHours) {
+	if hs == nil {
+		return AutoReply{}, nil
+	}
+	s := hs.schedule(teamID)
+	return s.reply, s.hours
+}
+
+// OnOpen registers fn to be called when any schedule opens
+func (hs *BusinessHoursService) OnOpen(fn func()) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.listeners = append(hs.listeners, fn)
+}
+
+// Run reloads schedules every minute and tells listeners when one opens, until ctx is cancelled
+func (hs *BusinessHoursService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := hs.Reload(); err != nil {
+				log.Printf("reloading business schedules: %v", err)
+			}
+			hs.checkOpened(now)
+		}
+	}
+}
+
+// checkOpened fires the listeners once if a schedule went from closed to open
+func (hs *BusinessHoursService) checkOpened(now time.Time) {
+	hs.mu.Lock()
+	current := map[uuid.UUID]bool{uuid.Nil: hs.fallback.hours.IsOpen(now)}
+	for id, s := range hs.schedules {
+		current[id] = s.hours.IsOpen(now)
+	}
+	opened := false
+	for id, isOpen := range current {
+		if wasOpen, seen := hs.open[id]; seen && isOpen && !wasOpen {
+			opened = true
+		}
+	}
+	hs.open = current
+	listeners := append([]func(){}, hs.listeners...)
+	hs.mu.Unlock()
+	if !opened {
+		return
+	}
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+// List returns the stored schedules with their holidays
+func (hs *BusinessHoursService) List() ([]models.BusinessSchedule, error) {
+	var schedules []models.BusinessSchedule
+	err := hs.db.Preload("Holidays", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date asc")
+	}).Order("name asc").Find(&schedules).Error
+	return schedules, err
+}
+
+// Save validates and creates or updates a schedule. Marking it default clears the
+// flag on every other schedule.
+func (hs *BusinessHoursService) Save(s *models.BusinessSchedule) error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return ErrScheduleName
+	}
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	if _, err := ParseBusinessHours(s.Timezone, s.Hours); err != nil {
+		return err
+	}
+	var taken int64
+	hs.db.Model(&models.BusinessSchedule{}).Where("name = ? AND id <> ?", s.Name, s.ID).Count(&taken)
+	if taken > 0 {
+		return ErrScheduleTaken
+	}
+	err := hs.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Holidays").Save(s).Error; err != nil {
+			return err
+		}
+		if s.IsDefault {
+			return tx.Model(&models.BusinessSchedule{}).Where("id <> ?", s.ID).Update("is_default", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return hs.Reload()
+}
+
+// Delete removes a schedule and its holidays; its teams fall back to the default
+func (hs *BusinessHoursService) Delete(id uuid.UUID) error {
+	err := hs.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Team{}).Where("schedule_id = ?", id).Update("schedule_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("schedule_id = ?", id).Delete(&models.Holiday{}).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&models.BusinessSchedule{}, "id = ?", id)
+		if res.Error == nil && res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return res.Error
+	})
+	if err != nil {
+		return err
+	}
+	return hs.Reload()
+}
+
+// AddHoliday closes a schedule on date (YYYY-MM-DD), every year when recurring
+func (hs *BusinessHoursService) AddHoliday(scheduleID uuid.UUID, date, name string, recurring bool) (*models.Holiday, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, ErrHolidayDate
+	}
+	if err := hs.db.Select("id").First(&models.BusinessSchedule{}, "id = ?", scheduleID).Error; err != nil {
+		return nil, err
+	}
+	holiday := models.Holiday{ScheduleID: scheduleID, Date: day, Name: strings.TrimSpace(name), Recurring: recurring}
+	if err := hs.db.Create(&holiday).Error; err != nil {
+		return nil, err
+	}
+	return &holiday, hs.Reload()
+}
+
+// RemoveHoliday deletes one of a schedule's holidays
+func (hs *BusinessHoursService) RemoveHoliday(scheduleID, holidayID uuid.UUID) error {
+	res := hs.db.Delete(&models.Holiday{}, "id = ? AND schedule_id = ?", holidayID, scheduleID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return hs.Reload()
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCannedForbidden = errors.New("you cannot change this canned response")
+	ErrCannedInvalid   = errors.New("shortcode (letters, digits, - or _) and content are required")
+	ErrCannedTaken     = errors.New("shortcode is already in use")
+	ErrCannedMedia     = errors.New("canned responses can only attach images or documents")
+)
+
+var (
+	shortcodePattern   = regexp.MustCompile(`^[a-z0-9_-]+$`)
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\.([a-z_]+)\s*\}\}`)
+)
+
+// CannedResponseService manages the canned-response library and fills in placeholders
+type CannedResponseService struct {
+	db       *gorm.DB
+	messages *MessageService
+	media    *MediaUploader
+}
+
+func NewCannedResponseService(db *gorm.DB, messages *MessageService, media *MediaUploader) *CannedResponseService {
+	return &CannedResponseService{db: db, messages: messages, media: media}
+}
+
+// List returns the shared responses and the user's own, optionally matching search
+// against shortcode, title and content
+func (cs *CannedResponseService) List(userID uuid.UUID, search string) ([]models.CannedResponse, error) {
+	query := cs.db.Where("owner_id IS NULL OR owner_id = ?", userID)
+	if search = strings.TrimPrefix(strings.TrimSpace(search), "/"); search != "" {
+		like := "%" + escapeLike(search) + "%"
+		query = query.Where("shortcode LIKE ? OR title LIKE ? OR content LIKE ?", like, like, like)
+	}
+	var responses []models.CannedResponse
+	err := query.Order("shortcode asc, owner_id desc").Find(&responses).Error
+	return responses, err
+}
+
+// Get returns a response the user can use
+func (cs *CannedResponseService) Get(id, userID uuid.UUID) (*models.CannedResponse, error) {
+	var c models.CannedResponse
+	if err := cs.db.First(&c, "id = ? AND (owner_id IS NULL OR owner_id = ?)", id, userID).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CanManage reports whether user may edit c: owners edit their own, supervisors and
+// admins edit shared ones
+func CanManage(user *models.User, c *models.CannedResponse) bool {
+	if c.Shared() {
+		return user.Role == models.RoleAdmin || user.Role == models.RoleSupervisor
+	}
+	return *c.OwnerID == user.ID
+}
+
+// Save validates and creates or updates a response
+func (cs *CannedResponseService) Save(c *models.CannedResponse) error {
+	c.Shortcode = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c.Shortcode), "/"))
+	if !shortcodePattern.MatchString(c.Shortcode) || strings.TrimSpace(c.Content) == "" {
+		return ErrCannedInvalid
+	}
+	if c.MediaPath != "" && c.MediaType != models.MessageTypeImage && c.MediaType != models.MessageTypeDocument {
+		return ErrCannedMedia
+	}
+	query := cs.db.Model(&models.CannedResponse{}).Where("shortcode = ? AND id <> ?", c.Shortcode, c.ID)
+	if c.OwnerID == nil {
+		query = query.Where("owner_id IS NULL")
+	} else {
+		query = query.Where("owner_id = ?", *c.OwnerID)
+	}
+	var taken int64
+	query.Count(&taken)
+	if taken > 0 {
+		return ErrCannedTaken
+	}
+	return cs.db.Save(c).Error
+}
+
+// AttachMedia stores an uploaded file on the response, replacing any earlier one
+func (cs *CannedResponseService) AttachMedia(c *models.CannedResponse, path, mediaType, fileName string) error {
+	if mediaType != string(models.MessageTypeImage) && mediaType != string(models.MessageTypeDocument) {
+		return ErrCannedMedia
+	}
+	c.MediaPath, c.MediaType, c.FileName = path, models.MessageType(mediaType), fileName
+	return cs.db.Model(c).Updates(map[string]interface{}{"media_path": path, "media_type": mediaType, "file_name": fileName}).Error
+}
+
+// Delete removes a response
+func (cs *CannedResponseService) Delete(c *models.CannedResponse) error {
+	return cs.db.Delete(c).Error
+}
+
+// Render fills in the response's placeholders for a conversation, sent by agent
+func (cs *CannedResponseService) Render(c *models.CannedResponse, conversationID uuid.UUID, agent *models.User) (string, error) {
+	var conv models.Conversation
+	if err := cs.db.Preload("Customer").First(&conv, "id = ?", conversationID).Error; err != nil {
+		return "", err
+	}
+	return renderPlaceholders(c.Content, &conv, agent), nil
+}
+
+// Send renders the response for a conversation and sends it, with its media if it has any
+func (cs *CannedResponseService) Send(ctx context.Context, c *models.CannedResponse, conversationID uuid.UUID, agent *models.User) (*models.Message, error) {
+	content, err := cs.Render(c, conversationID, agent)
+	if err != nil {
+		return nil, err
+	}
+	var mediaURL string
+	if c.MediaPath != "" {
+		if mediaURL, err = cs.media.SignedURL(ctx, c.MediaPath); err != nil {
+			return nil, err
+		}
+	}
+	return cs.messages.SendCanned(conversationID, c, content, mediaURL, agent)
+}
+
+// renderPlaceholders replaces {{customer.name}}, {{agent.name}}, {{conversation.id}} and
+// friends; unknown placeholders are left as they are
+func renderPlaceholders(text string, conv *models.Conversation, agent *models.User) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := placeholderPattern.FindStringSubmatch(m)
+		if v, ok := placeholderValue(parts[1], parts[2], conv, agent); ok {
+			return v
+		}
+		return m
+	})
+}
+
+func placeholderValue(object, field string, conv *models.Conversation, agent *models.User) (string, bool) {
+	switch object {
+	case "customer":
+		c := conv.Customer
+		switch field {
+		case "name":
+			return c.Name, true
+		case "first_name":
+			if f := strings.Fields(c.Name); len(f) > 0 {
+				return f[0], true
+			}
+			return "", true
+		case "phone":
+			return c.Phone, true
+		case "email":
+			return c.Email, true
+		case "company":
+			return c.Company, true
+		}
+	case "agent":
+		if agent == nil {
+			return "", false
+		}
+		switch field {
+		case "name":
+			return agent.Name, true
+		case "first_name":
+			if f := strings.Fields(agent.Name); len(f) > 0 {
+				return f[0], true
+			}
+			return "", true
+		case "email":
+			return agent.Email, true
+		}
+	case "conversation":
+		switch field {
+		case "id":
+			return conv.ID.String(), true
+		case "subject":
+			return conv.Subject, true
+		case "priority":
+			return string(conv.Priority), true
+		}
+	}
+	return "", false
+}
+
+// CannedUsage is how often one response was sent in a period
+type CannedUsage struct {
+	ID        uuid.UUID  `json:"id"`
+	Shortcode string     `json:"shortcode"`
+	Title     string     `json:"title"`
+	OwnerID   *uuid.UUID `json:"owner_id"`
+	Uses      int64      `json:"uses"`
+}
+
+// Usage counts successful sends per canned response, most used first
+func (cs *CannedResponseService) Usage(from, to *time.Time) ([]CannedUsage, error) {
+	query := cs.db.Table("messages").
+		Select("canned_responses.id, canned_responses.shortcode, canned_responses.title, canned_responses.owner_id, COUNT(*) AS uses").
+		Joins("JOIN canned_responses ON canned_responses.id = messages.canned_response_id").
+		Where("messages.status <> ?", models.MessageStatusFailed)
+	if from != nil {
+		query = query.Where("messages.created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("messages.created_at < ?", *to)
+	}
+	var usage []CannedUsage
+	err := query.Group("canned_responses.id, canned_responses.shortcode, canned_responses.title, canned_responses.owner_id").
+		Order("uses desc").Scan(&usage).Error
+	return usage, err
+}
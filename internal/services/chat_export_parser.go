@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrChatExportEmpty is returned when no line of a file looks like a WhatsApp chat export
+var ErrChatExportEmpty = errors.New("no chat messages found; expected a WhatsApp \"Export chat\" .txt or .zip")
+
+// Date orders of exported timestamps; which one a file uses depends on the phone's locale
+const (
+	DateOrderDMY = "dmy"
+	DateOrderMDY = "mdy"
+)
+
+// chatExportLine matches the start of an exported message in its Android and iOS forms:
+//
+//	31/12/21, 22:15 - Name: text          (Android)
+//	12/31/21, 10:15 PM - Name: text       (Android, 12-hour)
+//	31.12.21 22.15 - Name: text           (Android, dotted locales such as id-ID)
+//	[31/12/21, 22:15:03] Name: text       (iOS)
+//	2021-12-31, 22:15 - Name: text        (ISO date)
+var chatExportLine = regexp.MustCompile(`^\[?(\d{1,4})[./-](\d{1,2})[./-](\d{1,4}),?\s+(\d{1,2})[:.](\d{2})(?:[:.](\d{2}))?\s*([AaPp])?\.?\s?(?:[Mm]\.?)?\]?\s*(?:-\s+)?(.*)$`)
+
+var (
+	// <attached: 00000012-PHOTO-2021-12-31-22-15-03.jpg> on iOS, in any language
+	iosAttachment = regexp.MustCompile(`^<[^<>:]+:\s*([^<>]+\.\w{2,5})>$`)
+	// IMG-20211231-WA0001.jpg (file attached) on Android
+	androidAttachment = regexp.MustCompile(`^(\S[^()]*\.\w{2,5}) \(([^()]+)\)$`)
+	// names WhatsApp gives media, so the note in brackets can be in any language
+	whatsAppMediaName = regexp.MustCompile(`^(IMG|VID|AUD|PTT|STK|DOC)-\d{8}-WA\d+`)
+)
+
+// androidAttachedNotes are the bracketed notes after a file name in common locales
+var androidAttachedNotes = map[string]bool{
+	"file attached": true, "file terlampir": true, "archivo adjunto": true, "arquivo anexado": true,
+	"datei angehängt": true, "fichier joint": true, "file allegato": true, "bestand bijgevoegd": true,
+}
+
+// invisible marks WhatsApp puts around names and attachments
+var chatExportCleaner = strings.NewReplacer("\u200e", "", "\u200f", "", "\u202a", "", "\u202c", "", "\ufeff", "", "\u202f", " ", "\u00a0", " ")
+
+// ChatExportMessage is one message read from an export
+type ChatExportMessage struct {
+	At         time.Time
+	Author     string
+	Text       string
+	Attachment string // file name as it appears in the export
+}
+
+type rawExportLine struct {
+	parts  []string
+	author string
+	text   []string
+	system bool
+}
+
+// ParseChatExport reads a WhatsApp chat export. Dates are ambiguous in most locales,
+// so the order is taken from the file when any day is over 12 and from dateOrder
+// otherwise. System lines, such as the encryption notice, are dropped.
+func ParseChatExport(r io.Reader, dateOrder string, loc *time.Location) ([]ChatExportMessage, error) {
+	var lines []*rawExportLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		text := strings.TrimRight(chatExportCleaner.Replace(scanner.Text()), "\r")
+		if m := chatExportLine.FindStringSubmatch(text); m != nil {
+			line := &rawExportLine{parts: m}
+			if i := strings.Index(m[8], ": "); i > 0 {
+				line.author, line.text = strings.TrimSpace(m[8][:i]), []string{m[8][i+2:]}
+			} else if strings.HasSuffix(m[8], ":") && len(m[8]) > 1 {
+				line.author = strings.TrimSpace(strings.TrimSuffix(m[8], ":"))
+			} else {
+				line.system = true
+			}
+			lines = append(lines, line)
+			continue
+		}
+		// a continuation of a multi-line message
+		if len(lines) > 0 && !lines[len(lines)-1].system {
+			last := lines[len(lines)-1]
+			last.text = append(last.text, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	dayFirst := dateOrder != DateOrderMDY
+	for _, l := range lines {
+		if len(l.parts[1]) == 4 {
+			continue
+		}
+		a, _ := strconv.Atoi(l.parts[1])
+		b, _ := strconv.Atoi(l.parts[2])
+		if a > 12 {
+			dayFirst = true
+			break
+		}
+		if b > 12 {
+			dayFirst = false
+			break
+		}
+	}
+
+	var messages []ChatExportMessage
+	for _, l := range lines {
+		if l.system {
+			continue
+		}
+		at, ok := exportTime(l.parts, dayFirst, loc)
+		if !ok {
+			continue
+		}
+		msg := ChatExportMessage{At: at, Author: l.author, Text: strings.TrimSpace(strings.Join(l.text, "\n"))}
+		first, rest := msg.Text, ""
+		if i := strings.IndexByte(msg.Text, '\n'); i >= 0 {
+			first, rest = msg.Text[:i], strings.TrimSpace(msg.Text[i+1:])
+		}
+		if m := iosAttachment.FindStringSubmatch(first); m != nil {
+			msg.Attachment, msg.Text = strings.TrimSpace(m[1]), rest
+		} else if m := androidAttachment.FindStringSubmatch(first); m != nil &&
+			(androidAttachedNotes[strings.ToLower(m[2])] || whatsAppMediaName.MatchString(m[1])) {
+			msg.Attachment, msg.Text = strings.TrimSpace(m[1]), rest
+		}
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return nil, ErrChatExportEmpty
+	}
+	return messages, nil
+}
+
+// exportTime builds the time of a matched header line
+func exportTime(m []string, dayFirst bool, loc *time.Location) (time.Time, bool) {
+	n := func(s string) int {
+		v, _ := strconv.Atoi(s)
+		return v
+	}
+	var year, month, day int
+	switch {
+	case len(m[1]) == 4:
+		year, month, day = n(m[1]), n(m[2]), n(m[3])
+	case dayFirst:
+		day, month, year = n(m[1]), n(m[2]), n(m[3])
+	default:
+		month, day, year = n(m[1]), n(m[2]), n(m[3])
+	}
+	if year < 100 {
+		year += 2000
+	}
+	hour, min, sec := n(m[4]), n(m[5]), n(m[6])
+	switch strings.ToLower(m[7]) {
+	case "a":
+		if hour == 12 {
+			hour = 0
+		}
+	case "p":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || min > 59 || sec > 59 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), day, hour, min, sec, 0, loc), true
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/storage"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrImportFile    = errors.New("upload a WhatsApp chat export .txt, or the .zip with media")
+	ErrImportAuthors = errors.New("cannot tell which messages are ours; pass me with the name our side has in the chat")
+	ErrImportTooBig  = errors.New("the export unpacks to more than the import size limit, or has a file over the upload size limit")
+)
+
+// ChatImportOptions says how to read an export. Me lists the author names of our side;
+// without it the author who is not the customer is taken to be us.
+type ChatImportOptions struct {
+	Me        []string
+	DateOrder string
+	Location  *time.Location
+}
+
+// ChatImportResult summarises an import
+type ChatImportResult struct {
+	ConversationID     uuid.UUID `json:"conversation_id"`
+	Imported           int       `json:"imported"`
+	Duplicates         int       `json:"duplicates"`
+	Attachments        int       `json:"attachments"`
+	MissingAttachments []string  `json:"missing_attachments"`
+	CustomerAuthors    []string  `json:"customer_authors"`
+	OurAuthors         []string  `json:"our_authors"`
+	From               time.Time `json:"from"`
+	To                 time.Time `json:"to"`
+}
+
+// ChatImportService turns WhatsApp "Export chat" files into historical messages
+type ChatImportService struct {
+	db       *gorm.DB
+	store    storage.Storage
+	loc      *time.Location
+	maxFile  int64 // per unpacked file
+	maxTotal int64 // for everything unpacked from one export
+}
+
+func NewChatImportService(db *gorm.DB, store storage.Storage, cfg *config.Config) *ChatImportService {
+	loc, err := time.LoadLocation(cfg.BusinessTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return &ChatImportService{db: db, store: store, loc: loc, maxFile: cfg.MaxFileSize, maxTotal: cfg.ChatImportMaxSize}
+}
+
+// ImportFile imports an uploaded export: the chat .txt alone, or the .zip WhatsApp
+// makes when media is included
+func (is *ChatImportService) ImportFile(ctx context.Context, customerID uuid.UUID, file *multipart.FileHeader, opts ChatImportOptions) (*ChatImportResult, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".txt":
+		return is.Import(ctx, customerID, f, nil, opts)
+	case ".zip":
+		zr, err := zip.NewReader(f, file.Size)
+		if err != nil {
+			return nil, ErrImportFile
+		}
+		var chat *zip.File
+		media := map[string]*zip.File{}
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			name := path.Base(zf.Name)
+			if strings.EqualFold(path.Ext(name), ".txt") && (chat == nil || name == "_chat.txt") {
+				chat = zf
+				continue
+			}
+			media[name] = zf
+		}
+		if chat == nil {
+			return nil, ErrImportFile
+		}
+		rc, err := chat.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		text := &cappedReader{r: rc, max: is.maxTotal}
+		result, err := is.Import(ctx, customerID, text, media, opts)
+		if text.over {
+			return nil, ErrImportTooBig
+		}
+		return result, err
+	}
+	return nil, ErrImportFile
+}
+
+// Import adds the messages of a chat export to the customer's latest conversation,
+// or to a new closed one. Messages are stored as already sent and flagged imported,
+// so nothing sends them again, and importing the same export twice adds nothing.
+func (is *ChatImportService) Import(ctx context.Context, customerID uuid.UUID, chat io.Reader, media map[string]*zip.File, opts ChatImportOptions) (*ChatImportResult, error) {
+	var customer models.Customer
+	if err := is.db.First(&customer, "id = ?", customerID).Error; err != nil {
+		return nil, err
+	}
+	loc := opts.Location
+	if loc == nil {
+		loc = is.loc
+	}
+	parsed, err := ParseChatExport(chat, opts.DateOrder, loc)
+	if err != nil {
+		return nil, err
+	}
+	ours, err := ourAuthors(parsed, &customer, opts.Me)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ChatImportResult{From: parsed[0].At, To: parsed[len(parsed)-1].At, MissingAttachments: []string{}}
+	seen := map[string]bool{}
+	for _, m := range parsed {
+		if !seen[m.Author] {
+			seen[m.Author] = true
+			if ours[m.Author] {
+				result.OurAuthors = append(result.OurAuthors, m.Author)
+			} else {
+				result.CustomerAuthors = append(result.CustomerAuthors, m.Author)
+			}
+		}
+	}
+
+	conv, err := is.conversation(&customer, result.From, result.To)
+	if err != nil {
+		return nil, err
+	}
+	result.ConversationID = conv.ID
+	existing, err := is.importedKeys(conv.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	budget := is.maxTotal
+	for _, m := range parsed {
+		msg := models.Message{
+			ConversationID: conv.ID,
+			Type:           models.MessageTypeText,
+			Direction:      models.MessageDirectionInbound,
+			Status:         models.MessageStatusSent,
+			Content:        m.Text,
+			Imported:       true,
+			CreatedAt:      m.At,
+			UpdatedAt:      m.At,
+		}
+		if ours[m.Author] {
+			at := m.At
+			msg.Direction, msg.SentAt = models.MessageDirectionOutbound, &at
+		}
+		if m.Attachment != "" {
+			msg.Type, msg.FileName, msg.Caption, msg.Content = importedMediaType(m.Attachment), m.Attachment, m.Text, ""
+			msg.MediaMimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(m.Attachment)))
+		}
+		key := importKey(&msg)
+		if existing[key] > 0 {
+			existing[key]--
+			result.Duplicates++
+			continue
+		}
+		if m.Attachment != "" {
+			zf, ok := media[m.Attachment]
+			if !ok {
+				result.MissingAttachments = append(result.MissingAttachments, m.Attachment)
+			} else if err := is.storeAttachment(ctx, conv.ID, zf, &msg, &budget); err != nil {
+				return nil, fmt.Errorf("attachment %s: %w", m.Attachment, err)
+			} else {
+				result.Attachments++
+			}
+		}
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return result, nil
+	}
+
+	err = is.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&messages, 200).Error; err != nil {
+			return err
+		}
+		// history never moves the 24h window or unread counts, only the activity time
+		last := messages[len(messages)-1].CreatedAt
+		return tx.Model(&models.Conversation{}).Where("id = ? AND (last_message_at IS NULL OR last_message_at < ?)", conv.ID, last).
+			Updates(map[string]interface{}{"last_message_at": last, "last_activity_at": last}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Imported = len(messages)
+	return result, nil
+}
+
+// ourAuthors decides which authors are our side
+func ourAuthors(parsed []ChatExportMessage, customer *models.Customer, me []string) (map[string]bool, error) {
+	authors := map[string]bool{}
+	for _, m := range parsed {
+		authors[m.Author] = true
+	}
+	ours := map[string]bool{}
+	if len(me) > 0 {
+		for a := range authors {
+			for _, name := range me {
+				if strings.EqualFold(strings.TrimSpace(name), a) {
+					ours[a] = true
+				}
+			}
+		}
+		if len(ours) == 0 {
+			return nil, ErrImportAuthors
+		}
+		return ours, nil
+	}
+
+	// a two-person chat where one author is the customer, by name or number
+	if len(authors) != 2 {
+		return nil, ErrImportAuthors
+	}
+	isCustomer := func(a string) bool {
+		if strings.EqualFold(a, customer.Name) {
+			return true
+		}
+		digits := phoneDigits(a)
+		return len(digits) >= 8 && strings.HasSuffix(phoneDigits(customer.Phone), strings.TrimPrefix(digits, "0"))
+	}
+	var customers int
+	for a := range authors {
+		if isCustomer(a) {
+			customers++
+		} else {
+			ours[a] = true
+		}
+	}
+	if customers != 1 {
+		return nil, ErrImportAuthors
+	}
+	return ours, nil
+}
+
+func phoneDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// conversation returns the customer's latest one-to-one conversation, creating a
+// closed one spanning the imported history when there is none
+func (is *ChatImportService) conversation(customer *models.Customer, from, to time.Time) (*models.Conversation, error) {
+	var conv models.Conversation
+	err := is.db.Where("customer_id = ? AND is_group = ?", customer.ID, false).Order("created_at desc").First(&conv).Error
+	if err == nil {
+		return &conv, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	conv = models.Conversation{
+		CustomerID:     customer.ID,
+		Status:         models.ConversationStatusClosed,
+		Subject:        "Imported WhatsApp chat",
+		LastMessageAt:  &to,
+		LastActivityAt: to,
+		ClosedAt:       &to,
+		CreatedAt:      from,
+	}
+	if err := is.db.Create(&conv).Error; err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// importedKeys counts the messages already imported into a conversation by importKey
+func (is *ChatImportService) importedKeys(conversationID uuid.UUID) (map[string]int, error) {
+	var rows []models.Message
+	if err := is.db.Select("created_at, direction, content, caption, file_name").
+		Where("conversation_id = ? AND imported = ?", conversationID, true).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	keys := map[string]int{}
+	for i := range rows {
+		keys[importKey(&rows[i])]++
+	}
+	return keys, nil
+}
+
+func importKey(m *models.Message) string {
+	return fmt.Sprintf("%d|%s|%s|%s|%s", m.CreatedAt.Unix(), m.Direction, m.Content, m.Caption, m.FileName)
+}
+
+// storeAttachment copies a file from the export into storage, at most the per-file
+// limit and what is left of budget, which it reduces by the bytes unpacked. Sizes in
+// the zip header are only trusted to reject early.
+func (is *ChatImportService) storeAttachment(ctx context.Context, conversationID uuid.UUID, zf *zip.File, msg *models.Message, budget *int64) error {
+	max := is.maxFile
+	if *budget < max {
+		max = *budget
+	}
+	if zf.UncompressedSize64 > uint64(max) {
+		return ErrImportTooBig
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	contentType := msg.MediaMimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	objectPath := storage.Join("whatsapp-crm", "imported", conversationID.String(), uuid.New().String()+filepath.Ext(zf.Name))
+	body := &cappedReader{r: rc, max: max}
+	saved, err := is.store.Save(ctx, body, objectPath, contentType)
+	if body.over {
+		return ErrImportTooBig
+	}
+	if err != nil {
+		return err
+	}
+	*budget -= body.n
+	msg.MediaPath, msg.MediaSize = saved, body.n
+	return nil
+}
+
+// cappedReader fails once more than max bytes have been read from r
+type cappedReader struct {
+	r    io.Reader
+	n    int64
+	max  int64
+	over bool
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.n > c.max {
+		c.over = true
+		return n, ErrImportTooBig
+	}
+	return n, err
+}
+
+// importedMediaType guesses a message type from an exported file name
+func importedMediaType(name string) models.MessageType {
+	if strings.HasPrefix(name, "STK-") || strings.Contains(name, "-STICKER-") {
+		return models.MessageTypeSticker
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return models.MessageTypeImage
+	case ".webp":
+		return models.MessageTypeSticker
+	case ".mp4", ".3gp", ".mov", ".mkv", ".avi":
+		return models.MessageTypeVideo
+	case ".opus", ".ogg", ".m4a", ".mp3", ".aac", ".amr", ".wav":
+		return models.MessageTypeAudio
+	}
+	return models.MessageTypeDocument
+}
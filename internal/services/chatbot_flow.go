@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Flow step types
+const (
+	StepMessage  = "message"  // send text, then continue with next
+	StepQuestion = "question" // send text, store the reply in field, then continue
+	StepButtons  = "buttons"  // up to 3 reply buttons
+	StepList     = "list"     // up to 10 list rows behind a menu button
+	StepHandoff  = "handoff"  // send text if any, then hand over to a human
+	StepEnd      = "end"      // send text if any, then end the flow
+)
+
+// FlowDefinition is the JSON body of a chatbot flow:
+//
+//	{"start":"menu","steps":{"menu":{"type":"buttons","text":"Hi! What do you need?",
+//	  "options":[{"id":"hours","title":"Opening hours","next":"hours"}]}, ...}}
+type FlowDefinition struct {
+	Start string              `json:"start"`
+	Steps map[string]FlowStep `json:"steps"`
+}
+
+type FlowStep struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	// Field stores the answer, or the chosen option's title, on the customer
+	Field   string       `json:"field,omitempty"`
+	Pattern string       `json:"pattern,omitempty"` // optional regex a question's answer must match
+	Button  string       `json:"button,omitempty"`  // list menu label
+	Options []FlowOption `json:"options,omitempty"`
+	Next    string       `json:"next,omitempty"`
+}
+
+type FlowOption struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Next        string `json:"next"`
+}
+
+// flowFields maps the fields a flow may fill to customer columns; notes are appended
+var flowFields = map[string]string{
+	"customer.name":    "name",
+	"customer.email":   "email",
+	"customer.company": "company",
+	"customer.address": "address",
+	"customer.city":    "city",
+	"customer.country": "country",
+	"customer.notes":   "notes",
+}
+
+// waits reports whether the step stops to wait for the customer
+func (s FlowStep) waits() bool {
+	return s.Type == StepQuestion || s.Type == StepButtons || s.Type == StepList
+}
+
+// ParseFlow reads and validates a flow definition
+func ParseFlow(raw []byte) (*FlowDefinition, error) {
+	var def FlowDefinition
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, fmt.Errorf("flow definition: %w", err)
+	}
+	if _, ok := def.Steps[def.Start]; !ok {
+		return nil, fmt.Errorf("flow definition: start step %q does not exist", def.Start)
+	}
+	for id, step := range def.Steps {
+		if err := def.validateStep(id, step); err != nil {
+			return nil, err
+		}
+	}
+	return &def, nil
+}
+
+func (def *FlowDefinition) validateStep(id string, step FlowStep) error {
+	exists := func(next string) bool {
+		_, ok := def.Steps[next]
+		return ok
+	}
+	if _, ok := flowFields[step.Field]; step.Field != "" && !ok {
+		return fmt.Errorf("flow step %q: unknown field %q", id, step.Field)
+	}
+	switch step.Type {
+	case StepMessage, StepQuestion:
+		if step.Text == "" {
+			return fmt.Errorf("flow step %q: text is required", id)
+		}
+		if !exists(step.Next) {
+			return fmt.Errorf("flow step %q: next step %q does not exist", id, step.Next)
+		}
+		if step.Pattern != "" {
+			if _, err := regexp.Compile(step.Pattern); err != nil {
+				return fmt.Errorf("flow step %q: pattern: %w", id, err)
+			}
+		}
+	case StepButtons, StepList:
+		max := 3
+		if step.Type == StepList {
+			max = 10
+		}
+		if step.Text == "" || len(step.Options) == 0 || len(step.Options) > max {
+			return fmt.Errorf("flow step %q: text and 1 to %d options are required", id, max)
+		}
+		seen := map[string]bool{}
+		for _, o := range step.Options {
+			if o.ID == "" || o.Title == "" || seen[o.ID] {
+				return fmt.Errorf("flow step %q: options need a unique id and a title", id)
+			}
+			seen[o.ID] = true
+			if !exists(o.Next) {
+				return fmt.Errorf("flow step %q: option %q goes to missing step %q", id, o.ID, o.Next)
+			}
+		}
+	case StepHandoff, StepEnd:
+	default:
+		return fmt.Errorf("flow step %q: unknown type %q", id, step.Type)
+	}
+	return nil
+}
@@ -0,0 +1,472 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/pkg/whatsapp"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRuleInvalid  = errors.New("rule needs a pattern, and a reply or flow_key for its action")
+	ErrRulePattern  = errors.New("rule pattern is not a valid regex")
+	ErrFlowKey      = errors.New("flow key must be lowercase letters, digits, - or _")
+	ErrFlowTaken    = errors.New("a flow with that key already exists")
+	ErrFlowInactive = errors.New("flow has no active version")
+)
+
+const (
+	// maxFlowRetries is how many unusable answers a step takes before a human steps in
+	maxFlowRetries = 2
+	// flowSessionTTL drops flows the customer walked away from
+	flowSessionTTL = 24 * time.Hour
+	// maxFlowSteps stops flows whose message steps loop without waiting
+	maxFlowSteps = 20
+)
+
+// ChatbotService answers inbound messages from keyword/regex rules and multi-step
+// flows while the bot controls a conversation, and hands it to a human when needed
+type ChatbotService struct {
+	db            *gorm.DB
+	conversations *ConversationService
+	messages      *MessageService
+}
+
+func NewChatbotService(db *gorm.DB, conversations *ConversationService, messages *MessageService) *ChatbotService {
+	return &ChatbotService{db: db, conversations: conversations, messages: messages}
+}
+
+// ListRules returns the rules in the order they are tried
+func (cb *ChatbotService) ListRules() ([]models.ChatbotRule, error) {
+	var rules []models.ChatbotRule
+	err := cb.db.Order("priority desc, created_at asc").Find(&rules).Error
+	return rules, err
+}
+
+// SaveRule validates and creates or updates a rule
+func (cb *ChatbotService) SaveRule(r *models.ChatbotRule) error {
+	r.Pattern = strings.TrimSpace(r.Pattern)
+	if r.MatchType == "" {
+		r.MatchType = models.ChatbotMatchKeyword
+	}
+	if r.Action == "" {
+		r.Action = models.ChatbotActionReply
+	}
+	if r.Pattern == "" {
+		return ErrRuleInvalid
+	}
+	switch r.MatchType {
+	case models.ChatbotMatchKeyword:
+	case models.ChatbotMatchRegex:
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return ErrRulePattern
+		}
+	default:
+		return ErrRuleInvalid
+	}
+	switch r.Action {
+	case models.ChatbotActionReply:
+		if strings.TrimSpace(r.Reply) == "" {
+			return ErrRuleInvalid
+		}
+	case models.ChatbotActionFlow:
+		if _, err := cb.activeFlow(r.FlowKey); err != nil {
+			return err
+		}
+	case models.ChatbotActionHandoff:
+	default:
+		return ErrRuleInvalid
+	}
+	return cb.db.Save(r).Error
+}
+
+// DeleteRule removes a rule
+func (cb *ChatbotService) DeleteRule(id uuid.UUID) error {
+	res := cb.db.Delete(&models.ChatbotRule{}, "id = ?", id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return res.Error
+}
+
+// ListFlows returns the newest version of every flow
+func (cb *ChatbotService) ListFlows() ([]models.ChatbotFlow, error) {
+	var flows []models.ChatbotFlow
+	err := cb.db.Where("version = (SELECT MAX(v.version) FROM chatbot_flows v WHERE v.flow_key = chatbot_flows.flow_key)").
+		Order("flow_key asc").Find(&flows).Error
+	return flows, err
+}
+
+// Versions returns every version of a flow, newest first
+func (cb *ChatbotService) Versions(key string) ([]models.ChatbotFlow, error) {
+	var flows []models.ChatbotFlow
+	if err := cb.db.Where("flow_key = ?", key).Order("version desc").Find(&flows).Error; err != nil {
+		return nil, err
+	}
+	if len(flows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return flows, nil
+}
+
+// SaveFlow validates a definition and stores it as the next version of the flow,
+// making it the active one. create distinguishes adding a flow from editing one.
+func (cb *ChatbotService) SaveFlow(key, name string, definition []byte, createdBy uuid.UUID, create bool) (*models.ChatbotFlow, error) {
+	if !shortcodePattern.MatchString(key) || len(key) > 64 {
+		return nil, ErrFlowKey
+	}
+	if _, err := ParseFlow(definition); err != nil {
+		return nil, err
+	}
+	flow := models.ChatbotFlow{Key: key, Name: name, Definition: string(definition), IsActive: true, CreatedBy: &createdBy}
+	err := cb.db.Transaction(func(tx *gorm.DB) error {
+		var latest models.ChatbotFlow
+		err := tx.Where("flow_key = ?", key).Order("version desc").First(&latest).Error
+		switch {
+		case err == nil && create:
+			return ErrFlowTaken
+		case err == gorm.ErrRecordNotFound && !create:
+			return err
+		case err != nil && err != gorm.ErrRecordNotFound:
+			return err
+		}
+		flow.Version = latest.Version + 1
+		if flow.Name == "" {
+			flow.Name = latest.Name
+		}
+		if err := tx.Model(&models.ChatbotFlow{}).Where("flow_key = ?", key).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Create(&flow).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	flow.Spec = []byte(flow.Definition)
+	return &flow, nil
+}
+
+// Activate makes one version of a flow the one new sessions start on
+func (cb *ChatbotService) Activate(key string, version int) error {
+	return cb.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.ChatbotFlow{}).Where("flow_key = ? AND version = ?", key, version).Update("is_active", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.ChatbotFlow{}).Where("flow_key = ? AND version <> ?", key, version).Update("is_active", false).Error
+	})
+}
+
+// Deactivate stops a flow from starting; customers already in it finish their version
+func (cb *ChatbotService) Deactivate(key string) error {
+	res := cb.db.Model(&models.ChatbotFlow{}).Where("flow_key = ?", key).Update("is_active", false)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return res.Error
+}
+
+func (cb *ChatbotService) activeFlow(key string) (*models.ChatbotFlow, error) {
+	var flow models.ChatbotFlow
+	err := cb.db.Where("flow_key = ? AND is_active = ?", key, true).First(&flow).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrFlowInactive
+	}
+	return &flow, err
+}
+
+// SetControl switches a conversation between the bot and humans. Handing it to a
+// human routes it; handing it to the bot starts over from the rules.
+func (cb *ChatbotService) SetControl(conv *models.Conversation, to models.ConversationControl, actor Actor) error {
+	switch to {
+	case models.ControlBot:
+		if err := cb.conversations.TakeByBot(conv, actor, ""); err != nil {
+			return err
+		}
+		cb.endSession(conv)
+		return nil
+	case models.ControlHuman:
+		if err := cb.conversations.Handoff(conv, actor, ""); err != nil {
+			return err
+		}
+		cb.endSession(conv)
+		cb.conversations.AutoRoute(conv, RoutingHints{})
+		return nil
+	}
+	return ErrInvalidControl
+}
+
+// HandleInbound lets the bot answer a message the customer just sent. It only acts
+// on conversations it controls, or new ones that match a rule, and reports whether
+// it is still in control afterwards; when it is not, the caller routes as usual.
+func (cb *ChatbotService) HandleInbound(conv *models.Conversation, msg *models.Message, choice string) bool {
+	if conv.IsGroup || conv.Status == models.ConversationStatusClosed {
+		return false
+	}
+	if conv.ControlledBy != models.ControlBot && !NeedsRouting(conv) {
+		return false
+	}
+	text := strings.TrimSpace(msg.Content)
+	if text == "" {
+		text = strings.TrimSpace(msg.Caption)
+	}
+
+	if conv.ControlledBy == models.ControlBot {
+		if session := cb.session(conv.ID); session != nil {
+			cb.continueFlow(conv, session, text, choice)
+			return conv.ControlledBy == models.ControlBot
+		}
+	}
+
+	rule := cb.match(text)
+	if rule == nil {
+		if conv.ControlledBy == models.ControlBot {
+			cb.handoff(conv, "no rule matched")
+		}
+		return conv.ControlledBy == models.ControlBot
+	}
+	if conv.ControlledBy != models.ControlBot {
+		if rule.Action == models.ChatbotActionHandoff {
+			// humans already have it
+			return false
+		}
+		if err := cb.conversations.TakeByBot(conv, SystemActor, "rule: "+rule.Name); err != nil {
+			log.Printf("chatbot taking conversation %s: %v", conv.ID, err)
+			return false
+		}
+	}
+
+	switch rule.Action {
+	case models.ChatbotActionReply:
+		cb.say(conv, rule.Reply)
+	case models.ChatbotActionFlow:
+		cb.startFlow(conv, rule.FlowKey)
+	case models.ChatbotActionHandoff:
+		if rule.Reply != "" {
+			cb.say(conv, rule.Reply)
+		}
+		cb.handoff(conv, "rule: "+rule.Name)
+	}
+	return conv.ControlledBy == models.ControlBot
+}
+
+// match returns the first active rule matching text
+func (cb *ChatbotService) match(text string) *models.ChatbotRule {
+	if text == "" {
+		return nil
+	}
+	rules, err := cb.ListRules()
+	if err != nil {
+		log.Printf("loading chatbot rules: %v", err)
+		return nil
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.IsActive && botRuleMatches(r, text) {
+			return r
+		}
+	}
+	return nil
+}
+
+// botRuleMatches compares case-insensitively: keywords as whole words, regexes anywhere
+func botRuleMatches(r *models.ChatbotRule, text string) bool {
+	if r.MatchType == models.ChatbotMatchRegex {
+		re, err := regexp.Compile("(?i)" + r.Pattern)
+		return err == nil && re.MatchString(text)
+	}
+	for _, k := range strings.Split(r.Pattern, ",") {
+		if k = strings.TrimSpace(k); k == "" {
+			continue
+		}
+		if regexp.MustCompile(`(?i)(^|\W)` + regexp.QuoteMeta(k) + `($|\W)`).MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// session returns the conversation's flow position, dropping it once stale
+func (cb *ChatbotService) session(conversationID uuid.UUID) *models.ChatbotSession {
+	var session models.ChatbotSession
+	if err := cb.db.Preload("Flow").First(&session, "conversation_id = ?", conversationID).Error; err != nil {
+		return nil
+	}
+	if time.Since(session.UpdatedAt) > flowSessionTTL || session.Flow == nil {
+		cb.db.Delete(&session)
+		return nil
+	}
+	return &session
+}
+
+func (cb *ChatbotService) endSession(conv *models.Conversation) {
+	cb.db.Where("conversation_id = ?", conv.ID).Delete(&models.ChatbotSession{})
+}
+
+func (cb *ChatbotService) startFlow(conv *models.Conversation, key string) {
+	flow, err := cb.activeFlow(key)
+	if err != nil {
+		log.Printf("chatbot flow %s: %v", key, err)
+		cb.handoff(conv, "flow "+key+" unavailable")
+		return
+	}
+	def, err := ParseFlow([]byte(flow.Definition))
+	if err != nil {
+		cb.handoff(conv, err.Error())
+		return
+	}
+	cb.endSession(conv)
+	session := &models.ChatbotSession{ConversationID: conv.ID, FlowID: flow.ID}
+	cb.run(conv, session, def, def.Start)
+}
+
+// continueFlow applies the customer's answer to the step the session waits on
+func (cb *ChatbotService) continueFlow(conv *models.Conversation, session *models.ChatbotSession, text, choice string) {
+	def, err := ParseFlow([]byte(session.Flow.Definition))
+	if err != nil {
+		cb.handoff(conv, err.Error())
+		return
+	}
+	step, ok := def.Steps[session.Step]
+	if !ok {
+		cb.handoff(conv, fmt.Sprintf("flow %s v%d has no step %q", session.Flow.Key, session.Flow.Version, session.Step))
+		return
+	}
+	next, answer, ok := step.accept(text, choice)
+	if !ok {
+		session.Retries++
+		if session.Retries > maxFlowRetries {
+			cb.handoff(conv, "no usable answer to step "+session.Step)
+			return
+		}
+		cb.db.Model(session).Update("retries", session.Retries)
+		cb.prompt(conv, step)
+		return
+	}
+	if step.Field != "" {
+		cb.store(conv, step.Field, answer)
+	}
+	cb.run(conv, session, def, next)
+}
+
+// accept returns where the flow goes for an answer, and the value to store
+func (s FlowStep) accept(text, choice string) (string, string, bool) {
+	switch s.Type {
+	case StepQuestion:
+		if text == "" {
+			return "", "", false
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(text) {
+			return "", "", false
+		}
+		return s.Next, text, true
+	case StepButtons, StepList:
+		for i, o := range s.Options {
+			if (choice != "" && choice == o.ID) || strings.EqualFold(text, o.Title) || text == strconv.Itoa(i+1) {
+				return o.Next, o.Title, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// run walks the flow from stepID, sending each step, until one waits for the customer
+func (cb *ChatbotService) run(conv *models.Conversation, session *models.ChatbotSession, def *FlowDefinition, stepID string) {
+	for i := 0; i < maxFlowSteps; i++ {
+		step := def.Steps[stepID]
+		switch {
+		case step.Type == StepMessage:
+			cb.say(conv, step.Text)
+			stepID = step.Next
+			continue
+		case step.Type == StepHandoff:
+			if step.Text != "" {
+				cb.say(conv, step.Text)
+			}
+			cb.handoff(conv, "flow step "+stepID)
+			return
+		case step.Type == StepEnd:
+			if step.Text != "" {
+				cb.say(conv, step.Text)
+			}
+			cb.endSession(conv)
+			return
+		case step.waits():
+			session.Step, session.Retries = stepID, 0
+			if err := cb.db.Omit("Flow").Save(session).Error; err != nil {
+				log.Printf("saving chatbot session for conversation %s: %v", conv.ID, err)
+			}
+			cb.prompt(conv, step)
+			return
+		}
+	}
+	cb.handoff(conv, "flow did not stop for an answer")
+}
+
+// prompt sends a step that waits for the customer
+func (cb *ChatbotService) prompt(conv *models.Conversation, step FlowStep) {
+	if step.Type == StepQuestion {
+		cb.say(conv, step.Text)
+		return
+	}
+	interactive := whatsapp.InteractiveMessage{Type: "button", Body: cb.render(conv, step.Text)}
+	if step.Type == StepList {
+		interactive.Type, interactive.Button = "list", step.Button
+		if interactive.Button == "" {
+			interactive.Button = "Menu"
+		}
+	}
+	for _, o := range step.Options {
+		interactive.Options = append(interactive.Options, whatsapp.InteractiveOption{ID: o.ID, Title: o.Title, Description: o.Description})
+	}
+	if _, err := cb.messages.SendAutomatedInteractive(conv.ID, interactive); err != nil {
+		log.Printf("chatbot prompt for conversation %s: %v", conv.ID, err)
+	}
+}
+
+// say sends text with the customer placeholders filled in
+func (cb *ChatbotService) say(conv *models.Conversation, text string) {
+	if _, err := cb.messages.SendAutomatedText(conv.ID, cb.render(conv, text)); err != nil {
+		log.Printf("chatbot reply for conversation %s: %v", conv.ID, err)
+	}
+}
+
+func (cb *ChatbotService) render(conv *models.Conversation, text string) string {
+	if conv.Customer.ID == uuid.Nil {
+		cb.db.First(&conv.Customer, "id = ?", conv.CustomerID)
+	}
+	return renderPlaceholders(text, conv, nil)
+}
+
+// store saves an answer on the customer
+func (cb *ChatbotService) store(conv *models.Conversation, field, value string) {
+	column := flowFields[field]
+	var update interface{} = value
+	if column == "notes" {
+		update = gorm.Expr("CONCAT(COALESCE(notes, ''), IF(COALESCE(notes, '') = '', '', '\n'), ?)", value)
+	}
+	if err := cb.db.Model(&models.Customer{}).Where("id = ?", conv.CustomerID).Update(column, update).Error; err != nil {
+		log.Printf("chatbot storing %s for conversation %s: %v", field, conv.ID, err)
+		return
+	}
+	// keep placeholders in later steps current
+	conv.Customer = models.Customer{}
+}
+
+func (cb *ChatbotService) handoff(conv *models.Conversation, note string) {
+	cb.endSession(conv)
+	if err := cb.conversations.Handoff(conv, SystemActor, note); err != nil {
+		log.Printf("chatbot handoff for conversation %s: %v", conv.ID, err)
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const collisionChannel = "collision:changes"
+
+// What an agent is doing in a conversation
+const (
+	ActivityViewing = "viewing"
+	ActivityTyping  = "typing"
+	ActivityIdle    = "idle" // stopped typing, still viewing
+	ActivityLeft    = "left"
+)
+
+// Soft locking modes for sends from someone other than the assignee
+const (
+	CollisionLockOff   = "off"
+	CollisionLockWarn  = "warn"
+	CollisionLockBlock = "block"
+)
+
+// ErrAssigneeTyping is returned when soft locking holds back a send because the
+// assigned agent is typing
+var ErrAssigneeTyping = errors.New("the assigned agent is typing a reply in this conversation")
+
+// CollisionChange is broadcast when an agent starts or stops viewing or typing in a
+// conversation. Viewing and typing lapse at ExpiresAt unless refreshed.
+type CollisionChange struct {
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	Name           string     `json:"name"`
+	Activity       string     `json:"activity"`
+	At             time.Time  `json:"at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// ConversationViewer is an agent who has a conversation open right now
+type ConversationViewer struct {
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	Typing bool      `json:"typing"`
+}
+
+// CollisionService tracks which agents are viewing or typing in each conversation.
+// Entries live in Redis sorted sets scored by their expiry, so an agent whose client
+// stops refreshing drops out on its own.
+type CollisionService struct {
+	db         *gorm.DB
+	rdb        *redis.Client
+	viewingTTL time.Duration
+	typingTTL  time.Duration
+	lock       string
+}
+
+func NewCollisionService(db *gorm.DB, rdb *redis.Client, cfg *config.Config) *CollisionService {
+	return &CollisionService{
+		db:         db,
+		rdb:        rdb,
+		viewingTTL: time.Duration(cfg.CollisionViewingTTLSeconds) * time.Second,
+		typingTTL:  time.Duration(cfg.CollisionTypingTTLSeconds) * time.Second,
+		lock:       cfg.CollisionLock,
+	}
+}
+
+func collisionKey(conversationID uuid.UUID, activity string) string {
+	return "collision:conversation:" + conversationID.String() + ":" + activity
+}
+
+func scoreOf(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) }
+
+// View marks the user as viewing the conversation; clients call it again before
+// the viewing TTL runs out
+func (cs *CollisionService) View(ctx context.Context, conversationID uuid.UUID, user *models.User) error {
+	added, expires, err := cs.touch(ctx, conversationID, user.ID, ActivityViewing, cs.viewingTTL)
+	if err != nil {
+		return err
+	}
+	if added {
+		cs.publish(ctx, conversationID, user, ActivityViewing, &expires)
+	}
+	return nil
+}
+
+// Typing marks the user as typing, which implies viewing, or as having stopped
+func (cs *CollisionService) Typing(ctx context.Context, conversationID uuid.UUID, user *models.User, typing bool) error {
+	if !typing {
+		removed, err := cs.rdb.ZRem(ctx, collisionKey(conversationID, ActivityTyping), user.ID.String()).Result()
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			cs.publish(ctx, conversationID, user, ActivityIdle, nil)
+		}
+		return nil
+	}
+	if err := cs.View(ctx, conversationID, user); err != nil {
+		return err
+	}
+	added, expires, err := cs.touch(ctx, conversationID, user.ID, ActivityTyping, cs.typingTTL)
+	if err != nil {
+		return err
+	}
+	if added {
+		cs.publish(ctx, conversationID, user, ActivityTyping, &expires)
+	}
+	return nil
+}
+
+// Leave removes the user from the conversation
+func (cs *CollisionService) Leave(ctx context.Context, conversationID uuid.UUID, user *models.User) error {
+	pipe := cs.rdb.TxPipeline()
+	pipe.ZRem(ctx, collisionKey(conversationID, ActivityTyping), user.ID.String())
+	removed := pipe.ZRem(ctx, collisionKey(conversationID, ActivityViewing), user.ID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if removed.Val() > 0 {
+		cs.publish(ctx, conversationID, user, ActivityLeft, nil)
+	}
+	return nil
+}
+
+// Viewers lists the agents viewing the conversation and whether each is typing
+func (cs *CollisionService) Viewers(ctx context.Context, conversationID uuid.UUID) ([]ConversationViewer, error) {
+	viewing, err := cs.members(ctx, conversationID, ActivityViewing)
+	if err != nil {
+		return nil, err
+	}
+	typing, err := cs.members(ctx, conversationID, ActivityTyping)
+	if err != nil {
+		return nil, err
+	}
+	isTyping := map[string]bool{}
+	for _, id := range typing {
+		isTyping[id] = true
+	}
+
+	out := make([]ConversationViewer, 0, len(viewing))
+	if len(viewing) == 0 {
+		return out, nil
+	}
+	var users []models.User
+	if err := cs.db.Select("id, name").Where("id IN ?", viewing).Order("name asc").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		out = append(out, ConversationViewer{UserID: u.ID, Name: u.Name, Typing: isTyping[u.ID.String()]})
+	}
+	return out, nil
+}
+
+// IsTyping reports whether the user is typing in the conversation right now
+func (cs *CollisionService) IsTyping(ctx context.Context, conversationID, userID uuid.UUID) bool {
+	score, err := cs.rdb.ZScore(ctx, collisionKey(conversationID, ActivityTyping), userID.String()).Result()
+	return err == nil && int64(score) > time.Now().UnixMilli()
+}
+
+// CheckSend applies soft locking to a send by sender. While the assignee is typing,
+// warn holds back sends from anyone else unless they force it, and block holds them
+// back even when forced. Neither holds anything back while the assignee is not typing.
+func (cs *CollisionService) CheckSend(ctx context.Context, conv *models.Conversation, sender *models.User, force bool) error {
+	switch {
+	case cs.lock != CollisionLockWarn && cs.lock != CollisionLockBlock:
+		return nil
+	case conv.AgentID == nil || *conv.AgentID == sender.ID:
+		return nil
+	case cs.lock == CollisionLockWarn && force:
+		return nil
+	case cs.IsTyping(ctx, conv.ID, *conv.AgentID):
+		return ErrAssigneeTyping
+	}
+	return nil
+}
+
+// Lock returns the soft locking mode
+func (cs *CollisionService) Lock() string { return cs.lock }
+
+// Subscribe streams changes from every instance until ctx is cancelled
+func (cs *CollisionService) Subscribe(ctx context.Context) <-chan CollisionChange {
+	out := make(chan CollisionChange, 32)
+	sub := cs.rdb.Subscribe(ctx, collisionChannel)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var change CollisionChange
+				if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+					continue
+				}
+				select {
+				case out <- change:
+				default:
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// touch adds or refreshes the user in one of the conversation's sets, dropping lapsed
+// entries, and reports whether the user was not there before
+func (cs *CollisionService) touch(ctx context.Context, conversationID, userID uuid.UUID, activity string, ttl time.Duration) (bool, time.Time, error) {
+	key := collisionKey(conversationID, activity)
+	now := time.Now()
+	expires := now.Add(ttl)
+	pipe := cs.rdb.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", scoreOf(now))
+	added := pipe.ZAdd(ctx, key, &redis.Z{Score: float64(expires.UnixMilli()), Member: userID.String()})
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, expires, err
+	}
+	return added.Val() > 0, expires, nil
+}
+
+// members returns the users whose entry in a set has not lapsed
+func (cs *CollisionService) members(ctx context.Context, conversationID uuid.UUID, activity string) ([]string, error) {
+	return cs.rdb.ZRangeByScore(ctx, collisionKey(conversationID, activity), &redis.ZRangeBy{Min: "(" + scoreOf(time.Now()), Max: "+inf"}).Result()
+}
+
+func (cs *CollisionService) publish(ctx context.Context, conversationID uuid.UUID, user *models.User, activity string, expires *time.Time) {
+	change := CollisionChange{ConversationID: conversationID, UserID: user.ID, Name: user.Name, Activity: activity, At: time.Now(), ExpiresAt: expires}
+	if payload, err := json.Marshal(change); err == nil {
+		cs.rdb.Publish(ctx, collisionChannel, payload)
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"whatsapp-crm/internal/models"
+)
+
+var (
+	ErrInvalidControl = errors.New("controlled_by must be bot or human")
+	// ErrBotNeedsUnassigned is returned when handing an agent's conversation to the bot
+	ErrBotNeedsUnassigned = errors.New("unassign the conversation before handing it to the bot")
+)
+
+// TakeByBot lets the chatbot answer an unassigned conversation. It leaves the routing
+// queue until the bot hands it off.
+func (cs *ConversationService) TakeByBot(conv *models.Conversation, actor Actor, note string) error {
+	if conv.ControlledBy == models.ControlBot {
+		return nil
+	}
+	if conv.AgentID != nil {
+		return ErrBotNeedsUnassigned
+	}
+	if conv.Status == models.ConversationStatusClosed {
+		return ErrInvalidTransition
+	}
+	res := cs.db.Model(&models.Conversation{}).Where("id = ? AND agent_id IS NULL", conv.ID).
+		Updates(map[string]interface{}{"controlled_by": models.ControlBot, "queued_at": nil})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBotNeedsUnassigned
+	}
+	conv.ControlledBy, conv.QueuedAt = models.ControlBot, nil
+	recordEvent(cs.db, conv.ID, models.ConversationEventControlChanged, actor, string(models.ControlHuman), string(models.ControlBot), note)
+	return nil
+}
+
+// Handoff gives a conversation back to humans. An open conversation moves to pending
+// so it goes through routing; the caller routes it.
+func (cs *ConversationService) Handoff(conv *models.Conversation, actor Actor, note string) error {
+	if conv.ControlledBy == models.ControlHuman {
+		return nil
+	}
+	extra := map[string]interface{}{"controlled_by": models.ControlHuman}
+	if conv.Status == models.ConversationStatusOpen {
+		if err := cs.changeStatus(conv, models.ConversationStatusPending, models.ConversationEventStatusChanged, actor, extra); err != nil {
+			return err
+		}
+	} else if err := cs.db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Updates(extra).Error; err != nil {
+		return err
+	}
+	conv.ControlledBy = models.ControlHuman
+	recordEvent(cs.db, conv.ID, models.ConversationEventControlChanged, actor, string(models.ControlBot), string(models.ControlHuman), note)
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// recordEvent appends an entry to a conversation's history. Failures are logged
+// rather than returned so history never blocks the change it describes.
+func recordEvent(db *gorm.DB, conversationID uuid.UUID, eventType models.ConversationEventType, actor Actor, from, to, note string) {
+	err := db.Create(&models.ConversationEvent{
+		ConversationID: conversationID,
+		Type:           eventType,
+		ActorType:      actor.Type,
+		ActorID:        actor.ID,
+		FromValue:      from,
+		ToValue:        to,
+		Note:           note,
+	}).Error
+	if err != nil {
+		log.Printf("recording %s for conversation %s: %v", eventType, conversationID, err)
+	}
+}
+
+func idString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// AddTag tags a conversation and records the change; adding a tag it already has is a no-op
+func (cs *ConversationService) AddTag(conversationID, tagID uuid.UUID, actor Actor) error {
+	tag, err := tagFor(cs.db, tagID, models.TagScopeConversation)
+	if err != nil {
+		return err
+	}
+	var conv models.Conversation
+	if err := cs.db.Select("id").First(&conv, "id = ?", conversationID).Error; err != nil {
+		return err
+	}
+	if cs.db.Model(&conv).Where("tags.id = ?", tag.ID).Association("Tags").Count() > 0 {
+		return nil
+	}
+	if err := cs.db.Model(&conv).Association("Tags").Append(tag); err != nil {
+		return err
+	}
+	recordEvent(cs.db, conv.ID, models.ConversationEventTagsChanged, actor, "", tag.Name, "")
+	return nil
+}
+
+// RemoveTag untags a conversation and records the change
+func (cs *ConversationService) RemoveTag(conversationID, tagID uuid.UUID, actor Actor) error {
+	var tag models.Tag
+	if err := cs.db.First(&tag, "id = ?", tagID).Error; err != nil {
+		return err
+	}
+	res := cs.db.Exec("DELETE FROM conversation_tags WHERE conversation_id = ? AND tag_id = ?", conversationID, tag.ID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		recordEvent(cs.db, conversationID, models.ConversationEventTagsChanged, actor, tag.Name, "", "")
+	}
+	return nil
+}
+
+// TimelineItem is a history event, a message or an internal note, in the order they happened
+type TimelineItem struct {
+	Kind    string                    `json:"kind"`
+	At      time.Time                 `json:"at"`
+	Event   *models.ConversationEvent `json:"event,omitempty"`
+	Message *models.Message           `json:"message,omitempty"`
+	Note    *models.ConversationNote  `json:"note,omitempty"`
+}
+
+// Timeline interleaves a conversation's events, messages and notes chronologically
+func (cs *ConversationService) Timeline(conversationID uuid.UUID) ([]TimelineItem, error) {
+	var events []models.ConversationEvent
+	if err := cs.db.Preload("Actor", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email, role")
+	}).Where("conversation_id = ?", conversationID).Order("created_at asc").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	var messages []models.Message
+	if err := cs.db.Preload("SentBy", preloadNoteAuthor).Where("conversation_id = ?", conversationID).Order("created_at asc").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	var notes []models.ConversationNote
+	if err := cs.db.Preload("Author", preloadNoteAuthor).Where("conversation_id = ?", conversationID).Order("created_at asc").Find(&notes).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]TimelineItem, 0, len(events)+len(messages)+len(notes))
+	for i := range events {
+		items = append(items, TimelineItem{Kind: "event", At: events[i].CreatedAt, Event: &events[i]})
+	}
+	for i := range messages {
+		items = append(items, TimelineItem{Kind: "message", At: messages[i].CreatedAt, Message: &messages[i]})
+	}
+	for i := range notes {
+		items = append(items, TimelineItem{Kind: "note", At: notes[i].CreatedAt, Note: &notes[i]})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].At.Before(items[j].At) })
+	return items, nil
+}
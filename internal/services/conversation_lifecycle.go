@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTransition is returned for a status change the lifecycle does not allow
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrNoAgent is returned when moving a conversation without an agent to assigned
+	ErrNoAgent = errors.New("conversation has no agent")
+	// ErrStatusChanged is returned when someone else changed the status first
+	ErrStatusChanged = errors.New("conversation status changed concurrently")
+)
+
+// Actor is who caused a change to a conversation
+type Actor struct {
+	Type models.ActorType
+	ID   *uuid.UUID
+}
+
+var (
+	SystemActor   = Actor{Type: models.ActorSystem}
+	CustomerActor = Actor{Type: models.ActorCustomer}
+)
+
+func UserActor(id uuid.UUID) Actor { return Actor{Type: models.ActorUser, ID: &id} }
+
+// ReopenPolicy decides what an inbound message on a closed conversation does
+type ReopenPolicy string
+
+const (
+	ReopenExisting ReopenPolicy = "reopen"
+	ReopenNew      ReopenPolicy = "new"
+)
+
+// conversationTransitions lists the statuses each status may move to.
+// Leaving closed is a reopen.
+var conversationTransitions = map[models.ConversationStatus][]models.ConversationStatus{
+	models.ConversationStatusOpen:     {models.ConversationStatusAssigned, models.ConversationStatusPending, models.ConversationStatusClosed},
+	models.ConversationStatusAssigned: {models.ConversationStatusPending, models.ConversationStatusOpen, models.ConversationStatusClosed},
+	models.ConversationStatusPending:  {models.ConversationStatusAssigned, models.ConversationStatusOpen, models.ConversationStatusClosed},
+	models.ConversationStatusClosed:   {models.ConversationStatusOpen, models.ConversationStatusAssigned},
+}
+
+// CanTransition reports whether the lifecycle allows moving from one status to another
+func CanTransition(from, to models.ConversationStatus) bool {
+	for _, s := range conversationTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves a conversation to another status, keeping its timestamps and history in step.
+// Moving a closed conversation to open or assigned reopens it.
+func (cs *ConversationService) Transition(conversationID uuid.UUID, to models.ConversationStatus, actor Actor) error {
+	var conv models.Conversation
+	if err := cs.db.First(&conv, "id = ?", conversationID).Error; err != nil {
+		return err
+	}
+	if conv.Status == to {
+		return nil
+	}
+	if !CanTransition(conv.Status, to) {
+		return ErrInvalidTransition
+	}
+	if conv.Status == models.ConversationStatusClosed {
+		if to == models.ConversationStatusAssigned && conv.AgentID == nil {
+			return ErrNoAgent
+		}
+		return cs.reopen(&conv, actor)
+	}
+	if to == models.ConversationStatusAssigned && conv.AgentID == nil {
+		return ErrNoAgent
+	}
+	return cs.changeStatus(&conv, to, models.ConversationEventStatusChanged, actor, nil)
+}
+
+// changeStatus writes the new status with its timestamps and records the event in one
+// transaction. The update only applies if the status is still what conv says it is.
+func (cs *ConversationService) changeStatus(conv *models.Conversation, to models.ConversationStatus, eventType models.ConversationEventType, actor Actor, extra map[string]interface{}) error {
+	return cs.changeStatusWith(conv, to, eventType, actor, extra, nil)
+}
+
+// changeStatusWith is changeStatus that also runs also, when set, in the same
+// transaction; an error from it rolls the status change back
+func (cs *ConversationService) changeStatusWith(conv *models.Conversation, to models.ConversationStatus, eventType models.ConversationEventType, actor Actor, extra map[string]interface{}, also func(tx *gorm.DB) error) error {
+	now := time.Now()
+	from := conv.Status
+	updates := map[string]interface{}{"status": to}
+	switch to {
+	case models.ConversationStatusAssigned:
+		if conv.AssignedAt == nil {
+			updates["assigned_at"] = now
+		}
+		updates["queued_at"] = nil
+	case models.ConversationStatusClosed:
+		start := conv.CreatedAt
+		if conv.ReopenedAt != nil {
+			start = *conv.ReopenedAt
+		}
+		wall, business := resolutionTimes(cs.hours.ForTeam(conv.TeamID), start, now)
+		updates["closed_at"] = now
+		updates["resolution_time"] = wall
+		updates["resolution_time_business"] = business
+	}
+	for k, v := range extra {
+		updates[k] = v
+	}
+
+	err := cs.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Conversation{}).Where("id = ? AND status = ?", conv.ID, from).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+		if also != nil {
+			if err := also(tx); err != nil {
+				return err
+			}
+		}
+		if from == to {
+			return nil
+		}
+		return tx.Create(&models.ConversationEvent{
+			ConversationID: conv.ID,
+			Type:           eventType,
+			ActorType:      actor.Type,
+			ActorID:        actor.ID,
+			FromValue:      string(from),
+			ToValue:        string(to),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := cs.db.First(conv, "id = ?", conv.ID).Error; err != nil {
+		return err
+	}
+
+	if to == models.ConversationStatusClosed {
+		cs.publish(models.EventConversationClosed, conv.ID)
+		cs.closedMu.Lock()
+		listeners := append([]func(models.Conversation){}, cs.onClosed...)
+		cs.closedMu.Unlock()
+		for _, fn := range listeners {
+			go fn(*conv)
+		}
+		// the agent has a free slot now
+		go cs.DrainQueue()
+	}
+	return nil
+}
+
+// OnClosed registers fn to be called, in its own goroutine, with every conversation that closes
+func (cs *ConversationService) OnClosed(fn func(models.Conversation)) {
+	cs.closedMu.Lock()
+	defer cs.closedMu.Unlock()
+	cs.onClosed = append(cs.onClosed, fn)
+}
+
+// shouldReopen applies the reopen policy to a closed conversation receiving a new message
+func (cs *ConversationService) shouldReopen(conv *models.Conversation) bool {
+	if conv.AutoClosed {
+		// closed for inactivity, not because the issue was resolved
+		return true
+	}
+	if cs.reopenPolicy != ReopenExisting {
+		return false
+	}
+	return cs.reopenWindow <= 0 || conv.ClosedAt == nil || time.Since(*conv.ClosedAt) <= cs.reopenWindow
+}
+
+// reopen brings a closed conversation back to its agent, or to routing when it has none.
+// SLA targets restart from the moment it was reopened.
+func (cs *ConversationService) reopen(conv *models.Conversation, actor Actor) error {
+	to := models.ConversationStatusOpen
+	if conv.AgentID != nil {
+		to = models.ConversationStatusAssigned
+	}
+	now := time.Now()
+	err := cs.changeStatus(conv, to, models.ConversationEventReopened, actor, map[string]interface{}{
+		"closed_at":          nil,
+		"reopened_at":        now,
+		"reopen_count":       gorm.Expr("reopen_count + 1"),
+		"first_responded_at": nil,
+		"queued_at":          nil,
+		"sla_breached":       false,
+		"auto_closed":        false,
+	})
+	if err != nil {
+		return err
+	}
+	if err := cs.sla.Apply(conv); err != nil {
+		log.Printf("sla for reopened conversation %s: %v", conv.ID, err)
+	}
+	if conv.AgentID == nil {
+		cs.AutoRoute(conv, RoutingHints{})
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by List
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ConversationSort is the order of the conversation list
+type ConversationSort string
+
+const (
+	SortLastMessage ConversationSort = "last_message"
+	SortPriority    ConversationSort = "priority"
+)
+
+const (
+	activityColumn = "conversations.last_activity_at"
+	priorityRank   = "FIELD(conversations.priority, 'low', 'medium', 'high', 'urgent')"
+)
+
+// ConversationSnoozed says how the list treats snoozed conversations
+type ConversationSnoozed string
+
+const (
+	SnoozedHidden  ConversationSnoozed = ""     // left out until they wake up
+	SnoozedOnly    ConversationSnoozed = "only" // only snoozed conversations
+	SnoozedInclude ConversationSnoozed = "all"  // snoozed and awake alike
+)
+
+// ConversationFilter narrows the conversation list. Zero values mean no filter.
+type ConversationFilter struct {
+	Statuses   []models.ConversationStatus
+	Priorities []models.ConversationPriority
+	AgentID    *uuid.UUID
+	Unassigned bool
+	TeamID     *uuid.UUID
+	Tag        string    // id or name
+	UnreadOnly bool      // unread by Viewer
+	Viewer     uuid.UUID // agent whose read cursors give the unread counts
+	Control    models.ConversationControl
+	Snoozed    ConversationSnoozed
+	From       *time.Time
+	To         *time.Time
+	Sort       ConversationSort
+	Ascending  bool
+	Cursor     string
+	Limit      int
+}
+
+// MessageSummary is the short form of a conversation's latest message shown in the list
+type MessageSummary struct {
+	ID        uuid.UUID               `json:"id"`
+	Type      models.MessageType      `json:"type"`
+	Direction models.MessageDirection `json:"direction"`
+	Status    models.MessageStatus    `json:"status"`
+	Preview   string                  `json:"preview"`
+	Automated bool                    `json:"automated"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+// ConversationListItem is a conversation with its customer and latest message
+type ConversationListItem struct {
+	models.Conversation
+	LastMessage *MessageSummary `json:"last_message"`
+}
+
+// ConversationPage is one page of the list; NextCursor is empty on the last page
+type ConversationPage struct {
+	Conversations []ConversationListItem `json:"conversations"`
+	NextCursor    string                 `json:"next_cursor"`
+}
+
+// listCursor is the sort key of the last row of a page
+type listCursor struct {
+	Rank int       `json:"r,omitempty"`
+	At   time.Time `json:"t"`
+	ID   uuid.UUID `json:"id"`
+}
+
+func (c listCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// List returns a page of conversations, keyset-paginated on the sort key. Sorted by
+// last message, a page is a range scan of idx_conversation_activity; the priority
+// sort has no such index and still sorts every matching row.
+func (cs *ConversationService) List(f ConversationFilter) (*ConversationPage, error) {
+	if f.Limit <= 0 || f.Limit > 100 {
+		f.Limit = 20
+	}
+	query := cs.db.Model(&models.Conversation{}).
+		Preload("Customer", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name, phone, whatsapp_id, profile_pic")
+		}).
+		Preload("Agent", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name, email")
+		}).
+		Preload("Team").
+		Preload("Tags")
+	query = applyConversationFilter(query, f)
+
+	dir, cmp := "DESC", "<"
+	if f.Ascending {
+		dir, cmp = "ASC", ">"
+	}
+	if f.Cursor != "" {
+		cur, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// spelled out rather than as a row comparison, which MySQL does not match to the index
+		after := activityColumn + " " + cmp + " ? OR (" + activityColumn + " = ? AND conversations.id " + cmp + " ?)"
+		if f.Sort == SortPriority {
+			query = query.Where(priorityRank+" "+cmp+" ? OR ("+priorityRank+" = ? AND ("+after+"))", cur.Rank, cur.Rank, cur.At, cur.At, cur.ID)
+		} else {
+			query = query.Where(after, cur.At, cur.At, cur.ID)
+		}
+	}
+	if f.Sort == SortPriority {
+		query = query.Order(priorityRank + " " + dir)
+	}
+	query = query.Order(activityColumn + " " + dir).Order("conversations.id " + dir)
+
+	var convs []models.Conversation
+	if err := query.Limit(f.Limit + 1).Find(&convs).Error; err != nil {
+		return nil, err
+	}
+	page := &ConversationPage{}
+	if len(convs) > f.Limit {
+		convs = convs[:f.Limit]
+		last := convs[len(convs)-1]
+		cur := listCursor{At: last.LastActivityAt, ID: last.ID}
+		if f.Sort == SortPriority {
+			cur.Rank = priorityOrder(last.Priority)
+		}
+		page.NextCursor = cur.encode()
+	}
+
+	latest, err := cs.lastMessages(convs)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := cs.unreadCounts(f.Viewer, convs)
+	if err != nil {
+		return nil, err
+	}
+	page.Conversations = make([]ConversationListItem, len(convs))
+	for i := range convs {
+		convs[i].UnreadCount = unread[convs[i].ID]
+		page.Conversations[i] = ConversationListItem{Conversation: convs[i], LastMessage: latest[convs[i].ID]}
+	}
+	return page, nil
+}
+
+func applyConversationFilter(query *gorm.DB, f ConversationFilter) *gorm.DB {
+	if len(f.Statuses) > 0 {
+		query = query.Where("conversations.status IN ?", f.Statuses)
+	}
+	if len(f.Priorities) > 0 {
+		query = query.Where("conversations.priority IN ?", f.Priorities)
+	}
+	if f.Unassigned {
+		query = query.Where("conversations.agent_id IS NULL")
+	} else if f.AgentID != nil {
+		query = query.Where("conversations.agent_id = ?", *f.AgentID)
+	}
+	if f.TeamID != nil {
+		query = query.Where("conversations.team_id = ?", *f.TeamID)
+	}
+	if tag := strings.TrimSpace(f.Tag); tag != "" {
+		sql, args := tagFilter("conversation_tags", "conversation_id", "conversations.id", tag)
+		query = query.Where(sql, args...)
+	}
+	if f.UnreadOnly {
+		query = query.Where("EXISTS (SELECT 1 "+unreadInbound+" AND messages.conversation_id = conversations.id)", f.Viewer)
+	}
+	if f.Control != "" {
+		query = query.Where("conversations.controlled_by = ?", f.Control)
+	}
+	switch f.Snoozed {
+	case SnoozedHidden:
+		query = query.Where("conversations.snoozed_at IS NULL")
+	case SnoozedOnly:
+		query = query.Where("conversations.snoozed_at IS NOT NULL")
+	}
+	if f.From != nil {
+		query = query.Where("conversations.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("conversations.created_at < ?", *f.To)
+	}
+	return query
+}
+
+// priorityOrder matches the priorityRank SQL expression
+func priorityOrder(p models.ConversationPriority) int {
+	switch p {
+	case models.PriorityLow:
+		return 1
+	case models.PriorityMedium:
+		return 2
+	case models.PriorityHigh:
+		return 3
+	case models.PriorityUrgent:
+		return 4
+	}
+	return 0
+}
+
+// lastMessages loads the newest message of each conversation in one query
+func (cs *ConversationService) lastMessages(convs []models.Conversation) (map[uuid.UUID]*MessageSummary, error) {
+	out := make(map[uuid.UUID]*MessageSummary, len(convs))
+	if len(convs) == 0 {
+		return out, nil
+	}
+	ids := make([]uuid.UUID, len(convs))
+	for i := range convs {
+		ids[i] = convs[i].ID
+	}
+	var msgs []models.Message
+	err := cs.db.Select("id, conversation_id, type, direction, status, content, caption, file_name, automated, created_at").
+		Where("conversation_id IN ?", ids).
+		Where("created_at = (SELECT MAX(m2.created_at) FROM messages m2 WHERE m2.conversation_id = messages.conversation_id)").
+		Find(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		if _, ok := out[m.ConversationID]; ok {
+			continue
+		}
+		out[m.ConversationID] = &MessageSummary{
+			ID:        m.ID,
+			Type:      m.Type,
+			Direction: m.Direction,
+			Status:    m.Status,
+			Preview:   messagePreview(m),
+			Automated: m.Automated,
+			CreatedAt: m.CreatedAt,
+		}
+	}
+	return out, nil
+}
+
+// messagePreview is a one-line summary of a message, cut to 100 characters
+func messagePreview(m *models.Message) string {
+	text := m.Content
+	if text == "" {
+		text = m.Caption
+	}
+	if text == "" {
+		text = m.FileName
+	}
+	if text == "" && m.Type != models.MessageTypeText {
+		text = "[" + string(m.Type) + "]"
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > 100 {
+		text = string(r[:100]) + "…"
+	}
+	return text
+}
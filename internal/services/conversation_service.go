@@ -1,24 +1,245 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"log"
+	"sync"
 	"time"
+	"whatsapp-crm/internal/config"
 	"whatsapp-crm/internal/models"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-type ConversationService struct { db *gorm.DB }
+// ErrAlreadyAssigned is returned when someone else picked the conversation up first
+var ErrAlreadyAssigned = errors.New("conversation is already assigned")
 
-func NewConversationService(db *gorm.DB) *ConversationService { return &ConversationService{db: db} }
+var ErrInvalidPriority = errors.New("priority must be low, medium, high or urgent")
 
+type ConversationService struct { db *gorm.DB; events *EventDispatcher; router *RoutingService; teams *TeamService; sla *SLAService; hours *BusinessHoursService; reopenPolicy ReopenPolicy; reopenWindow time.Duration; transferTimeout time.Duration; closedMu sync.Mutex; onClosed []func(models.Conversation) }
+
+func NewConversationService(db *gorm.DB, events *EventDispatcher, router *RoutingService, teams *TeamService, sla *SLAService, hours *BusinessHoursService, cfg *config.Config) *ConversationService {
+	return &ConversationService{db: db, events: events, router: router, teams: teams, sla: sla, hours: hours, reopenPolicy: ReopenPolicy(cfg.ConversationReopenPolicy), reopenWindow: time.Duration(cfg.ConversationReopenWindowHours) * time.Hour, transferTimeout: time.Duration(cfg.TransferTimeoutSeconds) * time.Second}
+}
+
+// Create starts a conversation and routes it straight away
 func (cs *ConversationService) Create(customerID uuid.UUID) (*models.Conversation, error) {
+	conv, err := cs.create(customerID)
+	if err != nil { return nil, err }
+	cs.AutoRoute(conv, RoutingHints{})
+	return conv, nil
+}
+
+func (cs *ConversationService) create(customerID uuid.UUID) (*models.Conversation, error) {
 	conv := models.Conversation{CustomerID: customerID, Status: models.ConversationStatusOpen, Priority: models.PriorityMedium}
+	var groups int64
+	cs.db.Model(&models.Contact{}).Where("customer_id = ? AND is_group = ?", customerID, true).Count(&groups)
+	conv.IsGroup = groups > 0
 	if err := cs.db.Create(&conv).Error; err != nil { return nil, err }
+	if err := cs.sla.Apply(&conv); err != nil { log.Printf("sla for conversation %s: %v", conv.ID, err) }
+	cs.events.Publish(models.EventConversationCreated, conv)
 	return &conv, nil
 }
 
-func (cs *ConversationService) Assign(conversationID, agentID uuid.UUID) error {
+// GetOrCreateConversation returns the conversation an inbound message belongs to. A closed
+// conversation is reopened or left alone for a new one according to the reopen policy.
+// New conversations are not routed here: the inbound pipeline calls AutoRoute once the first message is known.
+func (cs *ConversationService) GetOrCreateConversation(customerID uuid.UUID) (*models.Conversation, error) {
+	var conv models.Conversation
+	err := cs.db.Where("customer_id = ?", customerID).Order("created_at desc").First(&conv).Error
+	if err == gorm.ErrRecordNotFound { return cs.create(customerID) }
+	if err != nil { return nil, err }
+	if conv.Status != models.ConversationStatusClosed {
+		if conv.SnoozedAt != nil { cs.Unsnooze(&conv, CustomerActor) }
+		return &conv, nil
+	}
+	if cs.shouldReopen(&conv) {
+		if err := cs.reopen(&conv, CustomerActor); err != nil { return nil, err }
+		return &conv, nil
+	}
+	return cs.create(customerID)
+}
+
+// NeedsRouting reports whether a conversation has not been given to an agent, team or queue yet
+func NeedsRouting(conv *models.Conversation) bool {
+	return conv.AgentID == nil && conv.TeamID == nil && conv.QueuedAt == nil
+}
+
+// Assign gives the conversation to an agent, taking it from the bot; closed conversations must be reopened first
+func (cs *ConversationService) Assign(conversationID, agentID uuid.UUID, actor Actor) error {
+	return cs.assignWith(conversationID, agentID, actor, nil)
+}
+
+// assignWith is Assign that also runs also, when set, in the assignment's transaction
+func (cs *ConversationService) assignWith(conversationID, agentID uuid.UUID, actor Actor, also func(tx *gorm.DB) error) error {
+	var conv models.Conversation
+	if err := cs.db.First(&conv, "id = ?", conversationID).Error; err != nil { return err }
+	if conv.Status == models.ConversationStatusClosed { return ErrInvalidTransition }
 	now := time.Now()
-	return cs.db.Model(&models.Conversation{}).Where("id = ?", conversationID).Updates(map[string]interface{}{"agent_id": agentID, "status": models.ConversationStatusAssigned, "assigned_at": &now}).Error
+	updates := map[string]interface{}{"agent_id": agentID, "assigned_at": &now, "queued_at": nil, "controlled_by": models.ControlHuman}
+	previous := idString(conv.AgentID)
+	if conv.Status == models.ConversationStatusAssigned {
+		err := cs.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&conv).Updates(updates).Error; err != nil { return err }
+			if also != nil { return also(tx) }
+			return nil
+		})
+		if err != nil { return err }
+	} else if err := cs.changeStatusWith(&conv, models.ConversationStatusAssigned, models.ConversationEventStatusChanged, actor, updates, also); err != nil {
+		return err
+	}
+	recordEvent(cs.db, conversationID, models.ConversationEventAssigned, actor, previous, agentID.String(), "")
+	if conv.ControlledBy == models.ControlBot { recordEvent(cs.db, conversationID, models.ConversationEventControlChanged, actor, string(models.ControlBot), string(models.ControlHuman), "assigned") }
+	cs.publish(models.EventConversationAssigned, conversationID)
+	return nil
+}
+
+// UpdatePriority changes the priority and re-stamps SLA due dates for it
+func (cs *ConversationService) UpdatePriority(conversationID uuid.UUID, priority models.ConversationPriority, actor Actor) error {
+	switch priority {
+	case models.PriorityLow, models.PriorityMedium, models.PriorityHigh, models.PriorityUrgent:
+	default:
+		return ErrInvalidPriority
+	}
+	var conv models.Conversation
+	if err := cs.db.First(&conv, "id = ?", conversationID).Error; err != nil { return err }
+	if conv.Priority == priority { return nil }
+	if err := cs.db.Model(&conv).Update("priority", priority).Error; err != nil { return err }
+	recordEvent(cs.db, conv.ID, models.ConversationEventPriorityChanged, actor, string(conv.Priority), string(priority), "")
+	conv.Priority = priority
+	return cs.sla.Apply(&conv)
+}
+
+// Escalate carries out a policy's breach action. Bumped priorities keep their
+// original due dates so one breach cannot cascade into another.
+func (cs *ConversationService) Escalate(b SLABreach) {
+	conv := b.Conversation
+	switch b.Policy.BreachAction {
+	case models.SLAActionBumpPriority:
+		if next := models.NextPriority(conv.Priority); next != conv.Priority {
+			cs.db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Update("priority", next)
+			recordEvent(cs.db, conv.ID, models.ConversationEventPriorityChanged, SystemActor, string(conv.Priority), string(next), "SLA "+string(b.Target)+" breached")
+		}
+	case models.SLAActionReassign:
+		if conv.AgentID == nil {
+			// still queued: try to get it to anyone
+			cs.AutoRoute(&conv, RoutingHints{})
+			return
+		}
+		if !cs.router.Enabled() { return }
+		_, err := cs.router.RouteExcept(context.Background(), &conv, []uuid.UUID{*conv.AgentID}, func(agentID uuid.UUID) error { return cs.Assign(conv.ID, agentID, SystemActor) })
+		if err != nil { log.Printf("reassigning conversation %s after SLA breach: %v", conv.ID, err) }
+	}
+}
+
+// AutoRoute applies team rules to an unassigned conversation, then hands it to the
+// routing engine. Teams without auto-assign, or no available agent, leave it queued.
+// Conversations the bot is answering wait for its handoff.
+func (cs *ConversationService) AutoRoute(conv *models.Conversation, hints RoutingHints) {
+	if conv.AgentID != nil || conv.ControlledBy == models.ControlBot { return }
+	if conv.TeamID == nil {
+		team, err := cs.teams.Match(conv, hints)
+		if err != nil { log.Printf("team rules for conversation %s: %v", conv.ID, err) }
+		if team != nil {
+			cs.db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Update("team_id", team.ID)
+			conv.TeamID = &team.ID
+			recordEvent(cs.db, conv.ID, models.ConversationEventTeamChanged, SystemActor, "", team.ID.String(), "matched routing rule for "+team.Name)
+			// a team-specific policy may now apply
+			if err := cs.sla.Apply(conv); err != nil { log.Printf("sla for conversation %s: %v", conv.ID, err) }
+		}
+	}
+	cs.assignOrQueue(conv, nil)
+}
+
+// assignOrQueue hands an unassigned conversation to the routing engine, skipping the
+// excluded agents. Teams without auto-assign, no available agent, or a team outside
+// its business hours leave it queued.
+func (cs *ConversationService) assignOrQueue(conv *models.Conversation, exclude []uuid.UUID) {
+	if conv.TeamID != nil && !cs.teamAutoAssign(*conv.TeamID) {
+		// team-level assignment: members pick it up from the team queue
+		cs.enqueue(conv)
+		return
+	}
+	if !cs.hours.ForTeam(conv.TeamID).IsOpen(time.Now()) {
+		// routed once the team's hours open again
+		cs.enqueue(conv)
+		return
+	}
+	if !cs.router.Enabled() { return }
+	assigned, err := cs.router.RouteExcept(context.Background(), conv, exclude, func(agentID uuid.UUID) error { return cs.Assign(conv.ID, agentID, SystemActor) })
+	if err != nil { log.Printf("routing conversation %s: %v", conv.ID, err) }
+	if assigned {
+		cs.db.First(conv, "id = ?", conv.ID)
+		return
+	}
+	cs.enqueue(conv)
+}
+
+func (cs *ConversationService) enqueue(conv *models.Conversation) {
+	now := time.Now()
+	res := cs.db.Model(&models.Conversation{}).Where("id = ? AND queued_at IS NULL", conv.ID).Update("queued_at", now)
+	if res.Error == nil && res.RowsAffected > 0 { recordEvent(cs.db, conv.ID, models.ConversationEventQueued, SystemActor, "", idString(conv.TeamID), "") }
+	if conv.QueuedAt == nil { conv.QueuedAt = &now }
+}
+
+func (cs *ConversationService) teamAutoAssign(teamID uuid.UUID) bool {
+	var team models.Team
+	if err := cs.db.Select("id, auto_assign").First(&team, "id = ?", teamID).Error; err != nil { return false }
+	return team.AutoAssign
+}
+
+// PickUp assigns a queued conversation to the calling agent unless someone beat them to it
+func (cs *ConversationService) PickUp(conversationID, agentID uuid.UUID) error {
+	var conv models.Conversation
+	if err := cs.db.First(&conv, "id = ?", conversationID).Error; err != nil { return err }
+	if conv.AgentID != nil { return ErrAlreadyAssigned }
+	if conv.Status == models.ConversationStatusClosed { return ErrInvalidTransition }
+	now := time.Now()
+	err := cs.changeStatus(&conv, models.ConversationStatusAssigned, models.ConversationEventStatusChanged, UserActor(agentID), map[string]interface{}{"agent_id": agentID, "assigned_at": &now, "queued_at": nil})
+	if err == ErrStatusChanged { return ErrAlreadyAssigned }
+	if err != nil { return err }
+	recordEvent(cs.db, conversationID, models.ConversationEventAssigned, UserActor(agentID), "", agentID.String(), "picked up from queue")
+	cs.publish(models.EventConversationAssigned, conversationID)
+	return nil
+}
+
+// Queue returns unassigned conversations waiting for an agent, oldest first, optionally for one team
+func (cs *ConversationService) Queue(limit int, teamID *uuid.UUID) ([]models.Conversation, error) {
+	var convs []models.Conversation
+	query := cs.db.Preload("Customer").Preload("Team").Where("agent_id IS NULL AND queued_at IS NOT NULL AND snoozed_at IS NULL AND status <> ?", models.ConversationStatusClosed)
+	if teamID != nil { query = query.Where("team_id = ?", *teamID) }
+	err := query.Order("queued_at asc").Limit(limit).Find(&convs).Error
+	return convs, err
+}
+
+// DrainQueue routes queued conversations oldest first. A conversation no agent can
+// take stays queued without holding up the ones behind it, which may belong to
+// other teams. Conversations waiting in a pickup-only team queue, or for a team
+// outside its business hours, are left alone.
+func (cs *ConversationService) DrainQueue() {
+	if !cs.router.Enabled() { return }
+	var skipped []uuid.UUID
+	for {
+		var conv models.Conversation
+		query := cs.db.Where("agent_id IS NULL AND queued_at IS NOT NULL AND snoozed_at IS NULL AND status <> ?", models.ConversationStatusClosed).
+			Where("team_id IS NULL OR team_id IN (?)", cs.db.Model(&models.Team{}).Select("id").Where("auto_assign = ?", true))
+		if len(skipped) > 0 { query = query.Where("id NOT IN ?", skipped) }
+		if err := query.Order("queued_at asc").First(&conv).Error; err != nil { return }
+		if !cs.hours.ForTeam(conv.TeamID).IsOpen(time.Now()) {
+			skipped = append(skipped, conv.ID)
+			continue
+		}
+		assigned, err := cs.router.Route(context.Background(), &conv, func(agentID uuid.UUID) error { return cs.Assign(conv.ID, agentID, SystemActor) })
+		if err != nil { log.Printf("routing queued conversation %s: %v", conv.ID, err) }
+		if !assigned { skipped = append(skipped, conv.ID) }
+	}
+}
+
+// publish reloads the conversation so subscribers receive its current state
+func (cs *ConversationService) publish(event models.EventType, conversationID uuid.UUID) {
+	var conv models.Conversation
+	if err := cs.db.First(&conv, "id = ?", conversationID).Error; err != nil { return }
+	cs.events.Publish(event, conv)
 }
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrSnoozeInPast is returned when a snooze would end before it starts
+var ErrSnoozeInPast = errors.New("snooze time must be in the future")
+
+// Snooze hides a conversation from the active queues until the given time, or until
+// the customer writes again when until is nil
+func (cs *ConversationService) Snooze(conversationID uuid.UUID, until *time.Time, actor Actor) error {
+	if until != nil && !until.After(time.Now()) {
+		return ErrSnoozeInPast
+	}
+	var conv models.Conversation
+	if err := cs.db.First(&conv, "id = ?", conversationID).Error; err != nil {
+		return err
+	}
+	if conv.Status == models.ConversationStatusClosed {
+		return ErrInvalidTransition
+	}
+	now := time.Now()
+	if err := cs.db.Model(&conv).Updates(map[string]interface{}{"snoozed_at": now, "snoozed_until": until}).Error; err != nil {
+		return err
+	}
+	to := "reply"
+	if until != nil {
+		to = until.Format(time.RFC3339)
+	}
+	recordEvent(cs.db, conv.ID, models.ConversationEventSnoozed, actor, "", to, "")
+	return nil
+}
+
+// Unsnooze brings a snoozed conversation back; one without an agent goes through routing again
+func (cs *ConversationService) Unsnooze(conv *models.Conversation, actor Actor) error {
+	res := cs.db.Model(&models.Conversation{}).Where("id = ? AND snoozed_at IS NOT NULL", conv.ID).
+		Updates(map[string]interface{}{"snoozed_at": nil, "snoozed_until": nil})
+	if res.Error != nil {
+		return res.Error
+	}
+	conv.SnoozedAt, conv.SnoozedUntil = nil, nil
+	if res.RowsAffected == 0 {
+		// already woken by someone else
+		return nil
+	}
+	recordEvent(cs.db, conv.ID, models.ConversationEventUnsnoozed, actor, "", "", "")
+	if conv.AgentID == nil {
+		cs.AutoRoute(conv, RoutingHints{})
+	}
+	return nil
+}
+
+// Run expires unanswered transfers and wakes snoozed conversations whose time is up,
+// until ctx is cancelled
+func (cs *ConversationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cs.expireTransfers()
+			cs.wakeSnoozed()
+		}
+	}
+}
+
+func (cs *ConversationService) wakeSnoozed() {
+	var convs []models.Conversation
+	if err := cs.db.Where("snoozed_at IS NOT NULL AND snoozed_until <= ?", time.Now()).Find(&convs).Error; err != nil {
+		log.Printf("loading snoozed conversations: %v", err)
+		return
+	}
+	for i := range convs {
+		if err := cs.Unsnooze(&convs[i], SystemActor); err != nil {
+			log.Printf("waking conversation %s: %v", convs[i].ID, err)
+		}
+	}
+}
+
+// AutoClose closes a conversation for inactivity. It is reopened when the customer
+// writes again, whatever the reopen policy.
+func (cs *ConversationService) AutoClose(conv *models.Conversation) error {
+	return cs.changeStatus(conv, models.ConversationStatusClosed, models.ConversationEventAutoClosed, SystemActor, map[string]interface{}{
+		"auto_closed":   true,
+		"snoozed_at":    nil,
+		"snoozed_until": nil,
+	})
+}
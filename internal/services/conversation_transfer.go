@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrHandoffNoteRequired = errors.New("a handoff note is required")
+	ErrTransferTarget      = errors.New("transfer needs exactly one of agent_id or team_id")
+	ErrTransferPending     = errors.New("conversation already has a pending transfer")
+	ErrTransferNotPending  = errors.New("transfer is no longer pending")
+	ErrSameAgent           = errors.New("conversation is already with that agent")
+)
+
+// TransferRequest describes a handoff to either an agent or a team
+type TransferRequest struct {
+	ToAgentID *uuid.UUID
+	ToTeamID  *uuid.UUID
+	Note      string
+}
+
+// Transfer hands a conversation to another agent, who must accept within the
+// transfer timeout, or straight into a team's queue.
+func (cs *ConversationService) Transfer(conversationID uuid.UUID, req TransferRequest, by uuid.UUID) (*models.ConversationTransfer, error) {
+	if req.Note == "" {
+		return nil, ErrHandoffNoteRequired
+	}
+	if (req.ToAgentID == nil) == (req.ToTeamID == nil) {
+		return nil, ErrTransferTarget
+	}
+	var conv models.Conversation
+	var transfer models.ConversationTransfer
+	// the conversation row is locked so two transfers cannot both see none pending
+	err := cs.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&conv, "id = ?", conversationID).Error; err != nil {
+			return err
+		}
+		if conv.Status == models.ConversationStatusClosed {
+			return ErrInvalidTransition
+		}
+		if req.ToAgentID != nil && conv.AgentID != nil && *conv.AgentID == *req.ToAgentID {
+			return ErrSameAgent
+		}
+		var pending int64
+		if err := tx.Model(&models.ConversationTransfer{}).Where("conversation_id = ? AND status = ?", conv.ID, models.TransferPending).Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrTransferPending
+		}
+		transfer = models.ConversationTransfer{
+			ConversationID: conv.ID,
+			FromAgentID:    conv.AgentID,
+			ToAgentID:      req.ToAgentID,
+			ToTeamID:       req.ToTeamID,
+			RequestedBy:    by,
+			Note:           req.Note,
+			Status:         models.TransferPending,
+		}
+		now := time.Now()
+		if req.ToAgentID != nil {
+			expires := now.Add(cs.transferTimeout)
+			transfer.ExpiresAt = &expires
+		} else {
+			transfer.Status, transfer.RespondedAt = models.TransferCompleted, &now
+		}
+		return tx.Create(&transfer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	actor := UserActor(by)
+	if req.ToAgentID != nil {
+		recordEvent(cs.db, conv.ID, models.ConversationEventTransferred, actor, idString(conv.AgentID), req.ToAgentID.String(), req.Note)
+		return &transfer, nil
+	}
+	recordEvent(cs.db, conv.ID, models.ConversationEventTransferred, actor, idString(conv.TeamID), req.ToTeamID.String(), req.Note)
+	cs.db.Model(&conv).Update("team_id", *req.ToTeamID)
+	conv.TeamID = req.ToTeamID
+	if err := cs.sla.Apply(&conv); err != nil {
+		log.Printf("sla for conversation %s: %v", conv.ID, err)
+	}
+	cs.requeue(&conv, actor, transfer.FromAgentID)
+	return &transfer, nil
+}
+
+// AcceptTransfer gives the conversation to the receiving agent. The transfer is
+// accepted in the assignment's transaction, so it stays pending if the assignment fails.
+func (cs *ConversationService) AcceptTransfer(transferID, agentID uuid.UUID) (*models.ConversationTransfer, error) {
+	var transfer models.ConversationTransfer
+	if err := cs.db.First(&transfer, "id = ? AND to_agent_id = ?", transferID, agentID).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	actor := UserActor(agentID)
+	err := cs.assignWith(transfer.ConversationID, agentID, actor, func(tx *gorm.DB) error {
+		return respondWithin(tx, &transfer, models.TransferAccepted, "", now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordEvent(cs.db, transfer.ConversationID, models.ConversationEventTransferAccept, actor, idString(transfer.FromAgentID), agentID.String(), "")
+	return &transfer, nil
+}
+
+// DeclineTransfer refuses a handoff; the conversation falls back to the routing queue
+func (cs *ConversationService) DeclineTransfer(transferID, agentID uuid.UUID, reason string) (*models.ConversationTransfer, error) {
+	transfer, err := cs.respond(transferID, agentID, models.TransferDeclined, reason)
+	if err != nil {
+		return nil, err
+	}
+	actor := UserActor(agentID)
+	recordEvent(cs.db, transfer.ConversationID, models.ConversationEventTransferDecline, actor, idString(transfer.FromAgentID), agentID.String(), reason)
+	cs.fallback(transfer, actor)
+	return transfer, nil
+}
+
+// respond moves a pending transfer addressed to agentID into its final state
+func (cs *ConversationService) respond(transferID, agentID uuid.UUID, status models.TransferStatus, reason string) (*models.ConversationTransfer, error) {
+	var transfer models.ConversationTransfer
+	if err := cs.db.First(&transfer, "id = ? AND to_agent_id = ?", transferID, agentID).Error; err != nil {
+		return nil, err
+	}
+	if err := respondWithin(cs.db, &transfer, status, reason, time.Now()); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// respondWithin moves a transfer out of pending; ErrTransferNotPending when it already was
+func respondWithin(db *gorm.DB, transfer *models.ConversationTransfer, status models.TransferStatus, reason string, at time.Time) error {
+	res := db.Model(transfer).Where("status = ?", models.TransferPending).
+		Updates(map[string]interface{}{"status": status, "responded_at": at, "decline_reason": reason})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTransferNotPending
+	}
+	transfer.Status, transfer.RespondedAt, transfer.DeclineReason = status, &at, reason
+	return nil
+}
+
+// fallback puts a conversation whose handoff failed back through routing, avoiding
+// both the agent who handed it off and the one who did not take it
+func (cs *ConversationService) fallback(transfer *models.ConversationTransfer, actor Actor) {
+	var conv models.Conversation
+	if err := cs.db.First(&conv, "id = ?", transfer.ConversationID).Error; err != nil {
+		return
+	}
+	if conv.Status == models.ConversationStatusClosed {
+		return
+	}
+	var exclude []uuid.UUID
+	if transfer.ToAgentID != nil {
+		exclude = append(exclude, *transfer.ToAgentID)
+	}
+	cs.requeue(&conv, actor, transfer.FromAgentID, exclude...)
+}
+
+// requeue takes the conversation off its agent and routes it again
+func (cs *ConversationService) requeue(conv *models.Conversation, actor Actor, previous *uuid.UUID, exclude ...uuid.UUID) {
+	if previous != nil {
+		exclude = append(exclude, *previous)
+	}
+	unassign := map[string]interface{}{"agent_id": nil, "assigned_at": nil}
+	if conv.Status == models.ConversationStatusOpen {
+		cs.db.Model(conv).Updates(unassign)
+		conv.AgentID = nil
+	} else if err := cs.changeStatus(conv, models.ConversationStatusOpen, models.ConversationEventStatusChanged, actor, unassign); err != nil {
+		log.Printf("returning conversation %s to the queue: %v", conv.ID, err)
+		return
+	}
+	cs.assignOrQueue(conv, exclude)
+}
+
+// PendingTransfers lists handoffs waiting on an agent, or all of them when agentID is nil
+func (cs *ConversationService) PendingTransfers(agentID *uuid.UUID) ([]models.ConversationTransfer, error) {
+	query := cs.db.Preload("Conversation.Customer").Preload("FromAgent", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email")
+	}).Where("status = ?", models.TransferPending)
+	if agentID != nil {
+		query = query.Where("to_agent_id = ?", *agentID)
+	}
+	var transfers []models.ConversationTransfer
+	err := query.Order("created_at asc").Find(&transfers).Error
+	return transfers, err
+}
+
+func (cs *ConversationService) expireTransfers() {
+	var transfers []models.ConversationTransfer
+	if err := cs.db.Where("status = ? AND expires_at <= ?", models.TransferPending, time.Now()).Find(&transfers).Error; err != nil {
+		log.Printf("loading expired transfers: %v", err)
+		return
+	}
+	for i := range transfers {
+		t := &transfers[i]
+		res := cs.db.Model(t).Where("status = ?", models.TransferPending).
+			Updates(map[string]interface{}{"status": models.TransferExpired, "responded_at": time.Now()})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+		recordEvent(cs.db, t.ConversationID, models.ConversationEventTransferExpired, SystemActor, idString(t.FromAgentID), idString(t.ToAgentID), "")
+		cs.fallback(t, SystemActor)
+	}
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/pkg/whatsapp"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrCSATGroup is returned for a report grouping other than agent, team, day, week or month
+var ErrCSATGroup = errors.New("group_by must be agent, team, day, week or month")
+
+// csatOptionPrefix marks the list rows of the interactive survey: csat_1 ... csat_5
+const csatOptionPrefix = "csat_"
+
+var csatOptions = []whatsapp.InteractiveOption{
+	{ID: "csat_5", Title: "5 - Very satisfied"},
+	{ID: "csat_4", Title: "4 - Satisfied"},
+	{ID: "csat_3", Title: "3 - Neutral"},
+	{ID: "csat_2", Title: "2 - Dissatisfied"},
+	{ID: "csat_1", Title: "1 - Very dissatisfied"},
+}
+
+// CSATService sends a satisfaction survey when a conversation closes and files the
+// customer's rating and comment against it
+type CSATService struct {
+	db            *gorm.DB
+	messages      *MessageService
+	enabled       bool
+	channel       string
+	template      string
+	question      string
+	followUp      string
+	thanks        string
+	expiry        time.Duration
+	commentWindow time.Duration
+}
+
+func NewCSATService(db *gorm.DB, messages *MessageService, cfg *config.Config) *CSATService {
+	return &CSATService{
+		db:            db,
+		messages:      messages,
+		enabled:       cfg.CSATEnabled,
+		channel:       cfg.CSATChannel,
+		template:      cfg.CSATTemplate,
+		question:      cfg.CSATQuestion,
+		followUp:      cfg.CSATFollowUp,
+		thanks:        cfg.CSATThanks,
+		expiry:        time.Duration(cfg.CSATExpiryHours) * time.Hour,
+		commentWindow: time.Duration(cfg.CSATCommentMinutes) * time.Minute,
+	}
+}
+
+// Send surveys the customer of a conversation that just closed. Group chats,
+// conversations no agent handled and ones closed for inactivity are not surveyed.
+// The interactive list needs the 24h window; outside it the template is used if set.
+func (s *CSATService) Send(conv models.Conversation) {
+	if !s.enabled || conv.IsGroup || conv.AutoClosed || conv.AgentID == nil {
+		return
+	}
+	now := time.Now()
+	// only the newest survey takes replies
+	s.db.Model(&models.CSATSurvey{}).Where("customer_id = ? AND status = ?", conv.CustomerID, models.CSATSent).Update("status", models.CSATExpired)
+	s.db.Model(&models.CSATSurvey{}).Where("customer_id = ? AND status = ?", conv.CustomerID, models.CSATRated).Update("status", models.CSATCompleted)
+
+	survey := models.CSATSurvey{ConversationID: conv.ID, CustomerID: conv.CustomerID, AgentID: conv.AgentID, TeamID: conv.TeamID, SentAt: now, ExpiresAt: now.Add(s.expiry)}
+	var msg *models.Message
+	var err error
+	inWindow := conv.LastInboundAt != nil && now.Sub(*conv.LastInboundAt) < customerWindow
+	switch {
+	case s.channel != "template" && inWindow:
+		survey.Channel = "interactive"
+		msg, err = s.messages.SendAutomatedInteractive(conv.ID, whatsapp.InteractiveMessage{Type: "list", Body: s.question, Button: "Rate us", Options: csatOptions})
+	case s.template != "":
+		survey.Channel = "template"
+		msg, err = s.messages.SendAutomatedTemplate(conv.ID, s.template, nil)
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("csat survey for conversation %s: %v", conv.ID, err)
+		survey.Status = models.CSATFailed
+	}
+	if msg != nil && msg.ID != uuid.Nil {
+		survey.MessageID = &msg.ID
+	}
+	if err := s.db.Create(&survey).Error; err != nil {
+		log.Printf("csat survey for conversation %s: %v", conv.ID, err)
+	}
+}
+
+// Claim returns the survey an inbound message answers and the rating it gives, 0 for
+// a follow-up comment. A message that is neither ends the survey, so the customer's
+// next request is handled as usual. Neither a rating nor a comment is taken while the
+// customer has another conversation going; the message belongs there.
+func (s *CSATService) Claim(customerID uuid.UUID, text, choice string) (*models.CSATSurvey, int) {
+	var survey models.CSATSurvey
+	if err := s.db.Where("customer_id = ? AND status IN ?", customerID, []models.CSATStatus{models.CSATSent, models.CSATRated}).
+		Order("sent_at desc").First(&survey).Error; err != nil {
+		return nil, 0
+	}
+	now := time.Now()
+	switch survey.Status {
+	case models.CSATSent:
+		rating := parseRating(text, choice)
+		if rating == 0 || now.After(survey.ExpiresAt) || s.activeElsewhere(&survey) {
+			s.db.Model(&survey).Where("status = ?", models.CSATSent).Update("status", models.CSATExpired)
+			return nil, 0
+		}
+		return &survey, rating
+	default:
+		if strings.TrimSpace(text) == "" || choice != "" || now.Sub(*survey.RatedAt) > s.commentWindow || s.activeElsewhere(&survey) {
+			s.db.Model(&survey).Where("status = ?", models.CSATRated).Update("status", models.CSATCompleted)
+			return nil, 0
+		}
+		return &survey, 0
+	}
+}
+
+// activeElsewhere reports whether the surveyed customer has a conversation other than
+// the rated one that is not closed
+func (s *CSATService) activeElsewhere(survey *models.CSATSurvey) bool {
+	var n int64
+	s.db.Model(&models.Conversation{}).
+		Where("customer_id = ? AND id <> ? AND status <> ?", survey.CustomerID, survey.ConversationID, models.ConversationStatusClosed).
+		Count(&n)
+	return n > 0
+}
+
+// Record stores a rating, or the comment that follows it, and answers the customer
+func (s *CSATService) Record(survey *models.CSATSurvey, rating int, text string) {
+	now := time.Now()
+	reply := s.thanks
+	var res *gorm.DB
+	if rating > 0 {
+		status := models.CSATCompleted
+		if s.followUp != "" {
+			status, reply = models.CSATRated, s.followUp
+		}
+		res = s.db.Model(survey).Where("status = ?", models.CSATSent).Updates(map[string]interface{}{"rating": rating, "rated_at": now, "status": status})
+	} else {
+		res = s.db.Model(survey).Where("status = ?", models.CSATRated).Updates(map[string]interface{}{"comment": strings.TrimSpace(text), "commented_at": now, "status": models.CSATCompleted})
+	}
+	if res.Error != nil || res.RowsAffected == 0 || reply == "" {
+		return
+	}
+	if _, err := s.messages.SendAutomatedText(survey.ConversationID, reply); err != nil {
+		log.Printf("csat reply for conversation %s: %v", survey.ConversationID, err)
+	}
+}
+
+// parseRating reads 1-5 from a message that is only a rating: a list choice, a
+// button or list title such as "4 - Satisfied", a digit with nothing but punctuation
+// around it, or one to five stars. Anything more is a message, not a rating.
+func parseRating(text, choice string) int {
+	if strings.HasPrefix(choice, csatOptionPrefix) {
+		if n, err := strconv.Atoi(strings.TrimPrefix(choice, csatOptionPrefix)); err == nil && n >= 1 && n <= 5 {
+			return n
+		}
+		return 0
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || len([]rune(text)) > 30 {
+		return 0
+	}
+	if stars := strings.Count(text, "\u2b50"); stars > 0 && strings.Trim(text, "\u2b50\ufe0f ") == "" {
+		if stars <= 5 {
+			return stars
+		}
+		return 0
+	}
+	if head, label, ok := strings.Cut(text, " - "); ok {
+		if strings.IndexFunc(label, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsSpace(r) }) >= 0 {
+			return 0
+		}
+		text = head
+	}
+	bare := strings.TrimFunc(text, func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSpace(r) })
+	if len(bare) == 1 && bare[0] >= '1' && bare[0] <= '5' {
+		return int(bare[0] - '0')
+	}
+	return 0
+}
+
+// Run closes surveys that were never answered or whose comment window passed, until ctx is cancelled
+func (s *CSATService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			s.db.Model(&models.CSATSurvey{}).Where("status = ? AND expires_at < ?", models.CSATSent, now).Update("status", models.CSATExpired)
+			s.db.Model(&models.CSATSurvey{}).Where("status = ? AND rated_at < ?", models.CSATRated, now.Add(-s.commentWindow)).Update("status", models.CSATCompleted)
+		}
+	}
+}
+
+// CSATStats aggregates the surveys of one agent, team or period
+type CSATStats struct {
+	Key          string           `json:"key"`
+	Name         string           `json:"name,omitempty"`
+	Sent         int64            `json:"sent"`
+	Responses    int64            `json:"responses"`
+	ResponseRate float64          `json:"response_rate"`
+	Average      float64          `json:"average"`
+	CSAT         float64          `json:"csat"` // percentage of ratings that are 4 or 5
+	Ratings      map[string]int64 `json:"ratings"`
+}
+
+// CSATFilter narrows the report to surveys sent in [From, To) and an agent or team
+type CSATFilter struct {
+	From    *time.Time
+	To      *time.Time
+	AgentID *uuid.UUID
+	TeamID  *uuid.UUID
+}
+
+var csatGroups = map[string][2]string{
+	"agent": {"COALESCE(csat_surveys.agent_id, '')", "COALESCE(users.name, '')"},
+	"team":  {"COALESCE(csat_surveys.team_id, '')", "COALESCE(teams.name, '')"},
+	"day":   {"DATE_FORMAT(csat_surveys.sent_at, '%Y-%m-%d')", "''"},
+	"week":  {"DATE_FORMAT(csat_surveys.sent_at, '%x-W%v')", "''"},
+	"month": {"DATE_FORMAT(csat_surveys.sent_at, '%Y-%m')", "''"},
+}
+
+// Report returns CSAT per agent, team or period, and over everything matched
+func (s *CSATService) Report(f CSATFilter, groupBy string) ([]CSATStats, *CSATStats, error) {
+	group, ok := csatGroups[groupBy]
+	if !ok {
+		return nil, nil, ErrCSATGroup
+	}
+	query := s.db.Table("csat_surveys").
+		Joins("LEFT JOIN users ON users.id = csat_surveys.agent_id").
+		Joins("LEFT JOIN teams ON teams.id = csat_surveys.team_id").
+		Where("csat_surveys.status <> ?", models.CSATFailed)
+	if f.From != nil {
+		query = query.Where("csat_surveys.sent_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("csat_surveys.sent_at < ?", *f.To)
+	}
+	if f.AgentID != nil {
+		query = query.Where("csat_surveys.agent_id = ?", *f.AgentID)
+	}
+	if f.TeamID != nil {
+		query = query.Where("csat_surveys.team_id = ?", *f.TeamID)
+	}
+	query = query.Session(&gorm.Session{})
+
+	const aggregates = "COUNT(*) AS sent, COUNT(csat_surveys.rating) AS responses, COALESCE(AVG(csat_surveys.rating), 0) AS average, " +
+		"COALESCE(SUM(csat_surveys.rating >= 4), 0) AS satisfied, COALESCE(SUM(csat_surveys.rating = 1), 0) AS r1, " +
+		"COALESCE(SUM(csat_surveys.rating = 2), 0) AS r2, COALESCE(SUM(csat_surveys.rating = 3), 0) AS r3, " +
+		"COALESCE(SUM(csat_surveys.rating = 4), 0) AS r4, COALESCE(SUM(csat_surveys.rating = 5), 0) AS r5"
+	var rows []csatRow
+	if err := query.Select(group[0] + " AS `key`, " + group[1] + " AS name, " + aggregates).
+		Group("`key`, name").Order("`key` asc").Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	var total csatRow
+	if err := query.Select(aggregates).Scan(&total).Error; err != nil {
+		return nil, nil, err
+	}
+	stats := make([]CSATStats, len(rows))
+	for i, r := range rows {
+		stats[i] = r.stats()
+	}
+	all := total.stats()
+	all.Key = "total"
+	return stats, &all, nil
+}
+
+type csatRow struct {
+	Key                string
+	Name               string
+	Sent, Responses    int64
+	Average            float64
+	Satisfied          int64
+	R1, R2, R3, R4, R5 int64
+}
+
+func (r csatRow) stats() CSATStats {
+	st := CSATStats{
+		Key:       r.Key,
+		Name:      r.Name,
+		Sent:      r.Sent,
+		Responses: r.Responses,
+		Average:   r.Average,
+		Ratings:   map[string]int64{"1": r.R1, "2": r.R2, "3": r.R3, "4": r.R4, "5": r.R5},
+	}
+	if r.Sent > 0 {
+		st.ResponseRate = float64(r.Responses) / float64(r.Sent) * 100
+	}
+	if r.Responses > 0 {
+		st.CSAT = float64(r.Satisfied) / float64(r.Responses) * 100
+	}
+	return st
+}
+
+// Surveys lists surveys newest first, optionally for one conversation or agent
+func (s *CSATService) Surveys(conversationID, agentID *uuid.UUID, offset, limit int) ([]models.CSATSurvey, int64, error) {
+	query := s.db.Model(&models.CSATSurvey{})
+	if conversationID != nil {
+		query = query.Where("conversation_id = ?", *conversationID)
+	}
+	if agentID != nil {
+		query = query.Where("agent_id = ?", *agentID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var surveys []models.CSATSurvey
+	err := query.Preload("Agent", func(db *gorm.DB) *gorm.DB { return db.Select("id, name, email") }).
+		Order("sent_at desc").Offset(offset).Limit(limit).Find(&surveys).Error
+	return surveys, total, err
+}
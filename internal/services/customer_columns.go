@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/pkg/xlsx"
+)
+
+var ErrCustomerFile = errors.New("upload a .csv or .xlsx file")
+
+// CustomerColumns are the customer fields a spreadsheet can carry, in the order
+// exports write them. Imports map their headers onto these names.
+var CustomerColumns = []string{"name", "phone", "whatsapp_id", "email", "company", "address", "city", "country", "notes", "tags"}
+
+// customerColumnAliases are header spellings suggested for each column, compared
+// after lowercasing and dropping everything but letters and digits
+var customerColumnAliases = map[string][]string{
+	"name":        {"name", "nama", "fullname", "namalengkap", "customer", "customername", "namapelanggan", "contactname"},
+	"phone":       {"phone", "telepon", "telp", "tel", "nohp", "nomorhp", "hp", "handphone", "mobile", "mobilephone", "phonenumber", "notelp", "nomortelepon", "nomor"},
+	"whatsapp_id": {"whatsappid", "whatsapp", "wa", "waid", "nowa", "nomorwa", "whatsappnumber"},
+	"email":       {"email", "emailaddress", "surel"},
+	"company":     {"company", "perusahaan", "organization", "organisasi", "instansi"},
+	"address":     {"address", "alamat", "street"},
+	"city":        {"city", "kota", "kabupaten"},
+	"country":     {"country", "negara"},
+	"notes":       {"notes", "note", "catatan", "keterangan", "remarks"},
+	"tags":        {"tags", "tag", "label", "labels", "kategori"},
+}
+
+// SuggestMapping guesses which header holds each column
+func SuggestMapping(headers []string) map[string]string {
+	mapping := map[string]string{}
+	for _, h := range headers {
+		key := headerKey(h)
+		for _, col := range CustomerColumns {
+			if _, taken := mapping[col]; taken {
+				continue
+			}
+			for _, alias := range customerColumnAliases[col] {
+				if key == alias {
+					mapping[col] = h
+				}
+			}
+		}
+	}
+	return mapping
+}
+
+func headerKey(h string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(h) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// customerValues gives a customer's columns as spreadsheet text
+func customerValues(c *models.Customer) map[string]string {
+	tags := make([]string, len(c.Tags))
+	for i, t := range c.Tags {
+		tags[i] = t.Name
+	}
+	return map[string]string{
+		"name":        c.Name,
+		"phone":       c.Phone,
+		"whatsapp_id": c.WhatsAppID,
+		"email":       c.Email,
+		"company":     c.Company,
+		"address":     c.Address,
+		"city":        c.City,
+		"country":     c.Country,
+		"notes":       c.Notes,
+		"tags":        strings.Join(tags, ", "),
+	}
+}
+
+// escapeFormula puts ' before a cell a spreadsheet would read as a formula. Phone
+// numbers such as +62 812-3456 are left as they are: they hold nothing to run.
+func escapeFormula(v string) string {
+	if v == "" || !strings.ContainsRune("=+-@\t\r", rune(v[0])) || phoneLike(v) {
+		return v
+	}
+	return "'" + v
+}
+
+// unescapeFormula undoes escapeFormula on an imported cell
+func unescapeFormula(v string) string {
+	if len(v) > 1 && v[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(v[1])) {
+		return v[1:]
+	}
+	return v
+}
+
+func phoneLike(v string) bool {
+	return strings.Trim(v, "+0123456789 -().") == "" && digitsOnly(v) != ""
+}
+
+// normalizePhone reduces a phone number to the digits WhatsApp uses: international
+// format without +. A national number starting with 0 gets countryCode in its place.
+func normalizePhone(s, countryCode string) string {
+	s = strings.TrimSpace(s)
+	d := digitsOnly(s)
+	switch {
+	case strings.HasPrefix(d, "00"):
+		d = d[2:]
+	case strings.HasPrefix(d, "0") && !strings.HasPrefix(s, "+"):
+		d = countryCode + d[1:]
+	}
+	return d
+}
+
+// localPhone is the national form of a normalized number, as it is often stored
+func localPhone(phone, countryCode string) string {
+	if countryCode != "" && strings.HasPrefix(phone, countryCode) {
+		return "0" + phone[len(countryCode):]
+	}
+	return ""
+}
+
+func validPhone(phone string) bool {
+	return len(phone) >= 8 && len(phone) <= 15
+}
+
+// normalizeEmail lowercases an email address; false when it is not a bare address
+func normalizeEmail(s string) (string, bool) {
+	email := strings.ToLower(strings.TrimSpace(s))
+	addr, err := mail.ParseAddress(email)
+	return email, err == nil && addr.Address == email
+}
+
+// splitTags splits a tags cell on commas or semicolons
+func splitTags(s string) []string {
+	return splitList(strings.ReplaceAll(s, ";", ","))
+}
+
+// readCustomerSheet reads all rows of an uploaded CSV or XLSX file
+func readCustomerSheet(file *multipart.FileHeader) (string, [][]string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".csv", ".txt":
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return "", nil, err
+		}
+		rows, err := readCSV(data)
+		return "csv", rows, err
+	case ".xlsx":
+		rows, err := xlsx.Read(f, file.Size)
+		if err != nil {
+			return "", nil, ErrCustomerFile
+		}
+		return "xlsx", rows, nil
+	}
+	return "", nil, ErrCustomerFile
+}
+
+// readCSV parses CSV as spreadsheets save it: with or without a byte order mark,
+// separated by commas, or by semicolons or tabs where the comma is the decimal sign
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	firstLine := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = ','
+	for _, sep := range []rune{';', '\t'} {
+		if bytes.Count(firstLine, []byte(string(sep))) > bytes.Count(firstLine, []byte(string(r.Comma))) {
+			r.Comma = sep
+		}
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, ErrCustomerFile
+	}
+	return rows, nil
+}
+
+// blankRow reports whether every cell of a row is empty
+func blankRow(values []string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/pkg/xlsx"
+
+	"gorm.io/gorm"
+)
+
+var ErrCustomerExportFormat = errors.New("format must be csv or xlsx")
+
+// CustomerExportFormats maps each customer export format to its content type
+var CustomerExportFormats = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// rowWriter is a CSV or XLSX sheet being written
+type rowWriter interface {
+	WriteRow(values []string) error
+	Close() error
+}
+
+type csvRows struct{ w *csv.Writer }
+
+// WriteRow escapes cells a spreadsheet would run as a formula. XLSX cells are
+// written as text, which is never run, so only CSV needs this.
+func (c csvRows) WriteRow(values []string) error {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = escapeFormula(v)
+	}
+	return c.w.Write(escaped)
+}
+
+func (c csvRows) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Export writes the customers matching search and tag, as GetCustomers filters
+// them, with CustomerColumns as the header row. Imports read the same columns back.
+func (cs *CustomerService) Export(w io.Writer, format, search, tag string) error {
+	var out rowWriter
+	switch format {
+	case "csv":
+		// the byte order mark makes Excel read the file as UTF-8
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return err
+		}
+		out = csvRows{csv.NewWriter(w)}
+	case "xlsx":
+		xw, err := xlsx.NewWriter(w, "Customers")
+		if err != nil {
+			return err
+		}
+		out = xw
+	default:
+		return ErrCustomerExportFormat
+	}
+	if err := out.WriteRow(CustomerColumns); err != nil {
+		return err
+	}
+
+	var customers []models.Customer
+	err := cs.customerQuery(search, tag).Preload("Tags").FindInBatches(&customers, 500, func(tx *gorm.DB, batch int) error {
+		for i := range customers {
+			values := customerValues(&customers[i])
+			row := make([]string, len(CustomerColumns))
+			for j, col := range CustomerColumns {
+				row[j] = values[col]
+			}
+			if err := out.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
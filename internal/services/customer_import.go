@@ -0,0 +1,816 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/storage"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCustomerImportEmpty    = errors.New("the file has a header row but no customers")
+	ErrCustomerImportTooLarge = errors.New("too many rows for one import")
+	ErrCustomerImportMapping  = errors.New("map at least one of phone, whatsapp_id or email to a column of the file")
+	ErrCustomerImportOptions  = errors.New("on_match must be update or skip, on_new must be create or skip")
+	ErrCustomerImportStarted  = errors.New("import has already been started")
+	ErrCustomerImportNotReady = errors.New("preview the import with a column mapping first")
+)
+
+const (
+	OnMatchUpdate = "update"
+	OnMatchSkip   = "skip"
+	OnNewCreate   = "create"
+	OnNewSkip     = "skip"
+)
+
+// ImportAction is what an import does with one row
+type ImportAction string
+
+const (
+	ImportCreate ImportAction = "create"
+	ImportUpdate ImportAction = "update"
+	ImportSkip   ImportAction = "skip"
+	ImportError  ImportAction = "error"
+)
+
+// importBatch is how many rows are matched against customers per query
+const importBatch = 500
+
+// importRow is a data row and its line in the file, blank lines left out
+type importRow struct {
+	Line   int      `json:"line"`
+	Values []string `json:"values"`
+}
+
+// ImportRowResult is the outcome of one row
+type ImportRowResult struct {
+	Row        int          `json:"row"`
+	Action     ImportAction `json:"action"`
+	CustomerID *uuid.UUID   `json:"customer_id,omitempty"`
+	MatchedBy  string       `json:"matched_by,omitempty"`
+	Changes    []string     `json:"changes,omitempty"`
+	Reason     string       `json:"reason,omitempty"`
+	Error      string       `json:"error,omitempty"`
+
+	values  []string
+	fields  map[string]string
+	tags    []string
+	match   *models.Customer
+	updates map[string]interface{}
+}
+
+// ImportPreview is a dry run of an import: the counts and the first rows
+type ImportPreview struct {
+	Total  int               `json:"total"`
+	Create int               `json:"create"`
+	Update int               `json:"update"`
+	Skip   int               `json:"skip"`
+	Error  int               `json:"error"`
+	Rows   []ImportRowResult `json:"rows"`
+}
+
+// CustomerImportUpload is a freshly uploaded file and what it looks like
+type CustomerImportUpload struct {
+	Job              *models.CustomerImportJob `json:"import"`
+	Headers          []string                  `json:"headers"`
+	Columns          []string                  `json:"columns"`
+	SuggestedMapping map[string]string         `json:"suggested_mapping"`
+	Sample           [][]string                `json:"sample"`
+}
+
+// CustomerImportService imports customer spreadsheets in the background
+type CustomerImportService struct {
+	db          *gorm.DB
+	store       storage.Storage
+	events      *EventDispatcher
+	countryCode string
+	maxRows     int
+	linkExpiry  time.Duration
+	wake        chan struct{}
+}
+
+func NewCustomerImportService(db *gorm.DB, store storage.Storage, events *EventDispatcher, cfg *config.Config) *CustomerImportService {
+	return &CustomerImportService{
+		db:          db,
+		store:       store,
+		events:      events,
+		countryCode: cfg.DefaultCountryCode,
+		maxRows:     cfg.CustomerImportMaxRows,
+		linkExpiry:  time.Duration(cfg.ExportLinkExpiryHours) * time.Hour,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Upload reads a CSV or XLSX file into a draft import. Its first non-blank row
+// is taken as the header row.
+func (is *CustomerImportService) Upload(file *multipart.FileHeader, userID uuid.UUID) (*CustomerImportUpload, error) {
+	format, records, err := readCustomerSheet(file)
+	if err != nil {
+		return nil, err
+	}
+	var headers []string
+	var rows []importRow
+	for i, values := range records {
+		if blankRow(values) {
+			continue
+		}
+		if headers == nil {
+			for _, h := range values {
+				headers = append(headers, strings.TrimSpace(h))
+			}
+			continue
+		}
+		rows = append(rows, importRow{Line: i + 1, Values: values})
+	}
+	if len(rows) == 0 {
+		return nil, ErrCustomerImportEmpty
+	}
+	if len(rows) > is.maxRows {
+		return nil, ErrCustomerImportTooLarge
+	}
+
+	mapping := SuggestMapping(headers)
+	rawHeaders, _ := json.Marshal(headers)
+	rawRows, _ := json.Marshal(rows)
+	rawMapping, _ := json.Marshal(mapping)
+	job := models.CustomerImportJob{
+		RequestedBy: userID,
+		FileName:    file.Filename,
+		Format:      format,
+		Headers:     string(rawHeaders),
+		Records:     string(rawRows),
+		Mapping:     string(rawMapping),
+		OnMatch:     OnMatchUpdate,
+		OnNew:       OnNewCreate,
+		Total:       len(rows),
+	}
+	if err := is.db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	upload := &CustomerImportUpload{Job: &job, Headers: headers, Columns: CustomerColumns, SuggestedMapping: mapping}
+	for i := 0; i < len(rows) && i < 5; i++ {
+		upload.Sample = append(upload.Sample, rows[i].Values)
+	}
+	return upload, nil
+}
+
+// Preview saves the mapping and choices of a draft import and dry-runs it against
+// the current customers. limit caps how many row outcomes are listed.
+func (is *CustomerImportService) Preview(id uuid.UUID, mapping map[string]string, onMatch, onNew string, limit int) (*models.CustomerImportJob, *ImportPreview, error) {
+	job, err := is.Job(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != models.CustomerImportDraft {
+		return nil, nil, ErrCustomerImportStarted
+	}
+	if onMatch == "" {
+		onMatch = OnMatchUpdate
+	}
+	if onNew == "" {
+		onNew = OnNewCreate
+	}
+	if (onMatch != OnMatchUpdate && onMatch != OnMatchSkip) || (onNew != OnNewCreate && onNew != OnNewSkip) {
+		return nil, nil, ErrCustomerImportOptions
+	}
+	var headers []string
+	var rows []importRow
+	if err := json.Unmarshal([]byte(job.Headers), &headers); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal([]byte(job.Records), &rows); err != nil {
+		return nil, nil, err
+	}
+	p, err := is.planner(headers, mapping, onMatch, onNew)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	preview := &ImportPreview{Total: len(rows), Rows: []ImportRowResult{}}
+	for start := 0; start < len(rows); start += importBatch {
+		end := start + importBatch
+		if end > len(rows) {
+			end = len(rows)
+		}
+		results, err := p.plan(rows[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range results {
+			switch r.Action {
+			case ImportCreate:
+				preview.Create++
+			case ImportUpdate:
+				preview.Update++
+			case ImportSkip:
+				preview.Skip++
+			case ImportError:
+				preview.Error++
+			}
+			if len(preview.Rows) < limit {
+				preview.Rows = append(preview.Rows, r)
+			}
+		}
+	}
+
+	rawMapping, _ := json.Marshal(mapping)
+	if err := is.db.Model(job).Updates(map[string]interface{}{"mapping": string(rawMapping), "on_match": onMatch, "on_new": onNew}).Error; err != nil {
+		return nil, nil, err
+	}
+	return job, preview, nil
+}
+
+// Start queues a previewed import for the worker
+func (is *CustomerImportService) Start(id uuid.UUID) (*models.CustomerImportJob, error) {
+	job, err := is.Job(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.CustomerImportDraft {
+		return nil, ErrCustomerImportStarted
+	}
+	headers, mapping := is.Mapping(job)
+	if _, err := is.planner(headers, mapping, job.OnMatch, job.OnNew); err != nil {
+		return nil, ErrCustomerImportNotReady
+	}
+	res := is.db.Model(job).Where("status = ?", models.CustomerImportDraft).Update("status", models.CustomerImportPending)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrCustomerImportStarted
+	}
+	select {
+	case is.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Jobs lists a user's imports, or everyone's, newest first
+func (is *CustomerImportService) Jobs(userID *uuid.UUID) ([]models.CustomerImportJob, error) {
+	var jobs []models.CustomerImportJob
+	query := is.db.Omit("records").Order("created_at desc").Limit(100)
+	if userID != nil {
+		query = query.Where("requested_by = ?", *userID)
+	}
+	return jobs, query.Find(&jobs).Error
+}
+
+// Job returns one import
+func (is *CustomerImportService) Job(id uuid.UUID) (*models.CustomerImportJob, error) {
+	var job models.CustomerImportJob
+	if err := is.db.First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Mapping returns the headers and the column mapping of an import
+func (is *CustomerImportService) Mapping(job *models.CustomerImportJob) ([]string, map[string]string) {
+	var headers []string
+	mapping := map[string]string{}
+	_ = json.Unmarshal([]byte(job.Headers), &headers)
+	_ = json.Unmarshal([]byte(job.Mapping), &mapping)
+	return headers, mapping
+}
+
+// ErrorReportURL signs a link to the CSV of rows that failed; empty when none did
+func (is *CustomerImportService) ErrorReportURL(ctx context.Context, job *models.CustomerImportJob) (string, error) {
+	if job.ErrorPath == "" {
+		return "", nil
+	}
+	return is.store.SignedURL(ctx, job.ErrorPath, is.linkExpiry)
+}
+
+// Run works through queued imports one at a time. Imports left running by a
+// restart start over; rows already saved then match and update.
+func (is *CustomerImportService) Run(ctx context.Context) {
+	is.db.Model(&models.CustomerImportJob{}).Where("status = ?", models.CustomerImportRunning).
+		Updates(map[string]interface{}{"status": models.CustomerImportPending, "processed": 0, "created": 0, "updated": 0, "skipped": 0, "failed": 0})
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		for is.next(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-is.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// next claims and runs the oldest pending import; false when there was none
+func (is *CustomerImportService) next(ctx context.Context) bool {
+	var job models.CustomerImportJob
+	if err := is.db.Where("status = ?", models.CustomerImportPending).Order("created_at asc").First(&job).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("customer import: %v", err)
+		}
+		return false
+	}
+	now := time.Now()
+	claim := is.db.Model(&models.CustomerImportJob{}).Where("id = ? AND status = ?", job.ID, models.CustomerImportPending).
+		Updates(map[string]interface{}{"status": models.CustomerImportRunning, "started_at": now})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return claim.Error == nil
+	}
+
+	path, err := is.process(ctx, &job)
+	done := time.Now()
+	if err != nil {
+		log.Printf("customer import %s: %v", job.ID, err)
+		is.db.Model(&job).Updates(map[string]interface{}{"status": models.CustomerImportFailed, "error": err.Error(), "completed_at": done})
+		return true
+	}
+	is.db.Model(&job).Updates(map[string]interface{}{"status": models.CustomerImportCompleted, "error_path": path, "completed_at": done})
+	return true
+}
+
+// process imports every row of a job and saves a CSV of the failed rows to storage.
+// It returns the report's path, empty when no row failed.
+func (is *CustomerImportService) process(ctx context.Context, job *models.CustomerImportJob) (string, error) {
+	var rows []importRow
+	if err := json.Unmarshal([]byte(job.Records), &rows); err != nil {
+		return "", err
+	}
+	headers, mapping := is.Mapping(job)
+	p, err := is.planner(headers, mapping, job.OnMatch, job.OnNew)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp("", "import-errors-*.csv")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	tmp.WriteString("\ufeff")
+	report := csv.NewWriter(tmp)
+	report.Write(append([]string{"row", "error"}, headers...))
+
+	for start := 0; start < len(rows); start += importBatch {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		end := start + importBatch
+		if end > len(rows) {
+			end = len(rows)
+		}
+		results, err := p.plan(rows[start:end])
+		if err != nil {
+			return "", err
+		}
+		for i := range results {
+			r := &results[i]
+			if r.Action == ImportCreate || r.Action == ImportUpdate {
+				if err := p.apply(r); err != nil {
+					r.Action, r.Error = ImportError, err.Error()
+				}
+			}
+			switch r.Action {
+			case ImportCreate:
+				job.Created++
+			case ImportUpdate:
+				job.Updated++
+			case ImportSkip:
+				job.Skipped++
+			case ImportError:
+				job.Failed++
+				report.Write(append([]string{strconv.Itoa(r.Row), r.Error}, r.values...))
+			}
+		}
+		job.Processed = end
+		is.db.Model(job).Updates(map[string]interface{}{
+			"processed": job.Processed, "created": job.Created, "updated": job.Updated, "skipped": job.Skipped, "failed": job.Failed,
+		})
+	}
+	report.Flush()
+	if err := report.Error(); err != nil {
+		return "", err
+	}
+	if job.Failed == 0 {
+		return "", nil
+	}
+	if _, err := tmp.Seek(0, 0); err != nil {
+		return "", err
+	}
+	objectPath := storage.Join("whatsapp-crm", "imports", job.CreatedAt.Format("2006/01/02"), job.ID.String()+"-errors.csv")
+	return is.store.Save(ctx, tmp, objectPath, "text/csv")
+}
+
+// importPlanner decides what to do with each row of one import. It remembers the
+// rows it has seen, so a customer appearing twice in the file is flagged.
+type importPlanner struct {
+	db          *gorm.DB
+	events      *EventDispatcher
+	countryCode string
+	columns     map[string]int
+	onMatch     string
+	onNew       string
+	seen        map[string]int
+	tags        map[string]*models.Tag
+}
+
+// planner resolves a field-to-header mapping to column positions
+func (is *CustomerImportService) planner(headers []string, mapping map[string]string, onMatch, onNew string) (*importPlanner, error) {
+	p := &importPlanner{
+		db:          is.db,
+		events:      is.events,
+		countryCode: is.countryCode,
+		columns:     map[string]int{},
+		onMatch:     onMatch,
+		onNew:       onNew,
+		seen:        map[string]int{},
+		tags:        map[string]*models.Tag{},
+	}
+	for _, field := range CustomerColumns {
+		header, ok := mapping[field]
+		if !ok || header == "" {
+			continue
+		}
+		i := indexOf(headers, header)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: no column %q", ErrCustomerImportMapping, header)
+		}
+		p.columns[field] = i
+	}
+	for field := range mapping {
+		if indexOf(CustomerColumns, field) < 0 {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrCustomerImportMapping, field)
+		}
+	}
+	_, phone := p.columns["phone"]
+	_, wa := p.columns["whatsapp_id"]
+	_, email := p.columns["email"]
+	if !phone && !wa && !email {
+		return nil, ErrCustomerImportMapping
+	}
+	return p, nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// plan parses and validates a batch of rows and matches them against customers,
+// deleted ones included, without saving anything
+func (p *importPlanner) plan(rows []importRow) ([]ImportRowResult, error) {
+	results := make([]ImportRowResult, len(rows))
+	var waIDs, phones, emails, tagNames []string
+	for i, row := range rows {
+		r := &results[i]
+		r.Row, r.values = row.Line, row.Values
+		if err := p.parse(r); err != "" {
+			r.Action, r.Error = ImportError, err
+			continue
+		}
+		if dup := p.duplicate(r); dup > 0 {
+			r.Action, r.Error = ImportError, fmt.Sprintf("duplicate of row %d", dup)
+			continue
+		}
+		if wa := r.fields["whatsapp_id"]; wa != "" {
+			waIDs = append(waIDs, wa)
+		}
+		if phone := r.fields["phone"]; phone != "" {
+			phones = append(phones, phone)
+			waIDs = append(waIDs, phone)
+			if local := localPhone(phone, p.countryCode); local != "" {
+				phones = append(phones, local)
+			}
+		}
+		if email := r.fields["email"]; email != "" {
+			emails = append(emails, email)
+		}
+		tagNames = append(tagNames, r.tags...)
+	}
+
+	// each list matches an indexed column; emails compare in the column's
+	// case-insensitive collation, so the lower-cased ones find any spelling
+	var existing []models.Customer
+	if sql, args := matchAny([]inList{{"whatsapp_id", waIDs}, {"phone_digits", phones}, {"email", emails}}); sql != "" {
+		err := p.db.Unscoped().Preload("Tags").Where(sql, args...).Find(&existing).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	byWA, byPhone, byEmail := map[string]*models.Customer{}, map[string]*models.Customer{}, map[string]*models.Customer{}
+	for i := range existing {
+		c := &existing[i]
+		if c.WhatsAppID != "" {
+			byWA[c.WhatsAppID] = c
+		}
+		if phone := normalizePhone(c.Phone, p.countryCode); phone != "" {
+			byPhone[phone] = c
+		}
+		if c.Email != "" {
+			byEmail[strings.ToLower(c.Email)] = c
+		}
+	}
+	if err := p.loadTags(tagNames); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.Action == ImportError {
+			continue
+		}
+		switch {
+		case r.fields["whatsapp_id"] != "" && byWA[r.fields["whatsapp_id"]] != nil:
+			r.match, r.MatchedBy = byWA[r.fields["whatsapp_id"]], "whatsapp_id"
+		case r.fields["phone"] != "" && byPhone[r.fields["phone"]] != nil:
+			r.match, r.MatchedBy = byPhone[r.fields["phone"]], "phone"
+		case r.fields["phone"] != "" && byWA[r.fields["phone"]] != nil:
+			r.match, r.MatchedBy = byWA[r.fields["phone"]], "phone"
+		case r.fields["email"] != "" && byEmail[r.fields["email"]] != nil:
+			r.match, r.MatchedBy = byEmail[r.fields["email"]], "email"
+		}
+		p.decide(r)
+	}
+	return results, nil
+}
+
+// inList is a column and the values to look it up by
+type inList struct {
+	column string
+	values []string
+}
+
+// matchAny ORs together an IN condition for each list that has values. Empty lists
+// are left out: padding them would match every customer with that column blank.
+func matchAny(lists []inList) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, l := range lists {
+		if len(l.values) > 0 {
+			conds = append(conds, l.column+" IN ?")
+			args = append(args, l.values)
+		}
+	}
+	return strings.Join(conds, " OR "), args
+}
+
+// parse reads the mapped cells of a row and normalizes them; it returns why the
+// row is invalid, or ""
+func (p *importPlanner) parse(r *ImportRowResult) string {
+	r.fields = map[string]string{}
+	for field, i := range p.columns {
+		if i < len(r.values) {
+			r.fields[field] = strings.TrimSpace(unescapeFormula(r.values[i]))
+		}
+	}
+	if phone := r.fields["phone"]; phone != "" {
+		if r.fields["phone"] = normalizePhone(phone, p.countryCode); !validPhone(r.fields["phone"]) {
+			return fmt.Sprintf("invalid phone number %q", phone)
+		}
+	}
+	if wa := r.fields["whatsapp_id"]; wa != "" {
+		if r.fields["whatsapp_id"] = normalizePhone(wa, p.countryCode); !validPhone(r.fields["whatsapp_id"]) {
+			return fmt.Sprintf("invalid WhatsApp ID %q", wa)
+		}
+	} else {
+		r.fields["whatsapp_id"] = r.fields["phone"]
+	}
+	if email := r.fields["email"]; email != "" {
+		var ok bool
+		if r.fields["email"], ok = normalizeEmail(email); !ok {
+			return fmt.Sprintf("invalid email %q", email)
+		}
+	}
+	if r.fields["phone"] == "" && r.fields["whatsapp_id"] == "" && r.fields["email"] == "" {
+		return "no phone, WhatsApp ID or email"
+	}
+	r.tags = splitTags(r.fields["tags"])
+	delete(r.fields, "tags")
+	for _, name := range r.tags {
+		if len(name) > 100 {
+			return fmt.Sprintf("tag %q is longer than 100 characters", name)
+		}
+	}
+	return ""
+}
+
+// duplicate returns the earlier row of the file with the same WhatsApp ID, phone
+// or email, or 0
+func (p *importPlanner) duplicate(r *ImportRowResult) int {
+	var keys []string
+	for _, field := range []string{"whatsapp_id", "phone", "email"} {
+		if v := r.fields[field]; v != "" {
+			keys = append(keys, field+":"+v)
+		}
+	}
+	for _, k := range keys {
+		if line, ok := p.seen[k]; ok {
+			return line
+		}
+	}
+	for _, k := range keys {
+		p.seen[k] = r.Row
+	}
+	return 0
+}
+
+// loadTags caches the existing tags among names
+func (p *importPlanner) loadTags(names []string) error {
+	var missing []string
+	for _, name := range names {
+		if _, ok := p.tags[strings.ToLower(name)]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	var tags []models.Tag
+	if err := p.db.Where("name IN ?", missing).Find(&tags).Error; err != nil {
+		return err
+	}
+	for i := range tags {
+		p.tags[strings.ToLower(tags[i].Name)] = &tags[i]
+	}
+	return nil
+}
+
+// decide picks the action of a parsed, matched row
+func (p *importPlanner) decide(r *ImportRowResult) {
+	for _, name := range r.tags {
+		if tag := p.tags[strings.ToLower(name)]; tag != nil && !tag.Allows(models.TagScopeCustomer) {
+			r.Action, r.Error = ImportError, fmt.Sprintf("tag %q cannot be put on customers", tag.Name)
+			return
+		}
+	}
+	if r.match == nil {
+		switch {
+		case p.onNew == OnNewSkip:
+			r.Action, r.Reason = ImportSkip, "new customer"
+		case r.fields["whatsapp_id"] == "":
+			r.Action, r.Error = ImportError, "a phone number or WhatsApp ID is needed to create a customer"
+		default:
+			r.Action = ImportCreate
+			r.Changes = p.changed(r, &models.Customer{})
+		}
+		return
+	}
+	r.CustomerID = &r.match.ID
+	if r.match.DeletedAt.Valid {
+		r.Action, r.Error = ImportError, "matches a deleted customer"
+		return
+	}
+	if p.onMatch == OnMatchSkip {
+		r.Action, r.Reason = ImportSkip, "customer exists"
+		return
+	}
+	r.updates = map[string]interface{}{}
+	if r.Changes = p.changed(r, r.match); len(r.Changes) == 0 {
+		r.Action, r.Reason = ImportSkip, "no changes"
+		return
+	}
+	r.Action = ImportUpdate
+}
+
+// changed lists the fields the row would change on c, and fills r.updates for an
+// existing customer. Empty cells never clear a field, and a customer's WhatsApp ID
+// is only filled in, never replaced.
+func (p *importPlanner) changed(r *ImportRowResult, c *models.Customer) []string {
+	current := customerValues(c)
+	var changes []string
+	for _, field := range CustomerColumns {
+		v := r.fields[field]
+		if field == "tags" || v == "" || v == current[field] {
+			continue
+		}
+		if field == "whatsapp_id" && current[field] != "" {
+			continue
+		}
+		if field == "email" && strings.EqualFold(v, current[field]) {
+			continue
+		}
+		if field == "phone" && current[field] != "" && normalizePhone(current[field], p.countryCode) == v {
+			continue
+		}
+		changes = append(changes, field)
+		if r.updates != nil {
+			r.updates[field] = v
+		}
+	}
+	has := map[string]bool{}
+	for _, t := range c.Tags {
+		has[strings.ToLower(t.Name)] = true
+	}
+	for _, name := range r.tags {
+		if !has[strings.ToLower(name)] {
+			changes = append(changes, "tags")
+			break
+		}
+	}
+	return changes
+}
+
+// apply saves a planned create or update with its tags
+func (p *importPlanner) apply(r *ImportRowResult) error {
+	var customer models.Customer
+	var created []string
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if r.Action == ImportCreate {
+			customer = models.Customer{
+				Name:       r.fields["name"],
+				Phone:      r.fields["phone"],
+				WhatsAppID: r.fields["whatsapp_id"],
+				Email:      r.fields["email"],
+				Company:    r.fields["company"],
+				Address:    r.fields["address"],
+				City:       r.fields["city"],
+				Country:    r.fields["country"],
+				Notes:      r.fields["notes"],
+			}
+			if customer.Name == "" {
+				customer.Name = customer.WhatsAppID
+			}
+			if err := tx.Create(&customer).Error; err != nil {
+				return err
+			}
+			contact := models.Contact{
+				CustomerID:  customer.ID,
+				WhatsAppID:  customer.WhatsAppID,
+				DisplayName: customer.Name,
+				Status:      models.ContactStatusValid,
+			}
+			if err := tx.Create(&contact).Error; err != nil {
+				return err
+			}
+		} else {
+			customer = *r.match
+			if len(r.updates) > 0 {
+				if err := tx.Model(&customer).Updates(r.updates).Error; err != nil {
+					return err
+				}
+			}
+		}
+		tags, err := p.ensureTags(tx, r.tags, &created)
+		if err != nil {
+			return err
+		}
+		if len(tags) > 0 {
+			return tx.Model(&customer).Association("Tags").Append(tags)
+		}
+		return nil
+	})
+	if err != nil {
+		// tags created in the rolled back transaction do not exist
+		for _, key := range created {
+			delete(p.tags, key)
+		}
+		return fmt.Errorf("could not save customer: %w", err)
+	}
+	r.CustomerID = &customer.ID
+	if err := p.db.Preload("Contact").Preload("Tags").First(&customer, "id = ?", customer.ID).Error; err == nil {
+		if r.Action == ImportCreate {
+			p.events.Publish(models.EventCustomerCreated, customer)
+		} else {
+			p.events.Publish(models.EventCustomerUpdated, customer)
+		}
+	}
+	return nil
+}
+
+// ensureTags returns the tags named, creating missing ones for customers and
+// adding their keys to created
+func (p *importPlanner) ensureTags(tx *gorm.DB, names []string, created *[]string) ([]*models.Tag, error) {
+	var tags []*models.Tag
+	for _, name := range names {
+		key := strings.ToLower(name)
+		tag := p.tags[key]
+		if tag == nil {
+			tag = &models.Tag{Name: name, Scope: models.TagScopeCustomer}
+			if err := tx.Create(tag).Error; err != nil {
+				return nil, err
+			}
+			p.tags[key] = tag
+			*created = append(*created, key)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
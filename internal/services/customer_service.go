@@ -5,14 +5,16 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CustomerService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	events *EventDispatcher
 }
 
-func NewCustomerService(db *gorm.DB) *CustomerService {
-	return &CustomerService{db: db}
+func NewCustomerService(db *gorm.DB, events *EventDispatcher) *CustomerService {
+	return &CustomerService{db: db, events: events}
 }
 
 // GetOrCreateCustomer gets existing customer or creates new one
@@ -45,6 +47,7 @@ func (cs *CustomerService) GetOrCreateCustomer(whatsappID, name string) (*models
 			}
 
 			customer.Contact = &contact
+			cs.events.Publish(models.EventCustomerCreated, customer)
 		} else {
 			return nil, err
 		}
@@ -53,14 +56,13 @@ func (cs *CustomerService) GetOrCreateCustomer(whatsappID, name string) (*models
 	return &customer, nil
 }
 
-// GetCustomers returns paginated list of customers
-func (cs *CustomerService) GetCustomers(page, limit int, search string) ([]models.Customer, int64, error) {
+// GetCustomers returns paginated list of customers, optionally only those with a tag (id or name)
+func (cs *CustomerService) GetCustomers(page, limit int, search, tag string) ([]models.Customer, int64, error) {
 	offset := (page - 1) * limit
 
-	query := cs.db.Model(&models.Customer{}).Preload("Contact")
-
-	if search != "" {
-		query = query.Where("name LIKE ? OR email LIKE ? OR phone LIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%")
+	query := cs.customerQuery(search, tag).Preload("Contact").Preload("Tags")
+	if terms := searchTerms(search); len(terms) > 0 {
+		query = query.Order(clause.OrderBy{Expression: clause.Expr{SQL: customerMatch + " DESC", Vars: []interface{}{booleanQuery(terms)}, WithoutParentheses: true}})
 	}
 
 	// Get total count
@@ -76,10 +78,28 @@ func (cs *CustomerService) GetCustomers(page, limit int, search string) ([]model
 	return customers, total, nil
 }
 
+// customerQuery selects the customers matching a search and a tag (id or name)
+func (cs *CustomerService) customerQuery(search, tag string) *gorm.DB {
+	query := cs.db.Model(&models.Customer{})
+	if terms := searchTerms(search); len(terms) > 0 {
+		// phone numbers are also matched inside, which the FULLTEXT index cannot do
+		query = query.Where(customerMatch+" OR customers.phone LIKE ?", booleanQuery(terms), "%"+escapeLike(search)+"%")
+	} else if search != "" {
+		// too short for the FULLTEXT index
+		like := "%" + escapeLike(search) + "%"
+		query = query.Where("name LIKE ? OR email LIKE ? OR phone LIKE ?", like, like, like)
+	}
+	if tag != "" {
+		sql, args := tagFilter("customer_tags", "customer_id", "customers.id", tag)
+		query = query.Where(sql, args...)
+	}
+	return query
+}
+
 // GetCustomerByID returns customer by ID
 func (cs *CustomerService) GetCustomerByID(id uuid.UUID) (*models.Customer, error) {
 	var customer models.Customer
-	if err := cs.db.Preload("Contact").Preload("Conversations").First(&customer, "id = ?", id).Error; err != nil {
+	if err := cs.db.Preload("Contact").Preload("Contact.Participants").Preload("Tags").Preload("Conversations").First(&customer, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &customer, nil
@@ -92,15 +112,18 @@ func (cs *CustomerService) UpdateCustomer(id uuid.UUID, updates map[string]inter
 		return nil, err
 	}
 
+	// tags are changed through the tag endpoints
+	delete(updates, "tags")
 	if err := cs.db.Model(&customer).Updates(updates).Error; err != nil {
 		return nil, err
 	}
 
 	// Reload with preloaded data
-	if err := cs.db.Preload("Contact").First(&customer, "id = ?", id).Error; err != nil {
+	if err := cs.db.Preload("Contact").Preload("Tags").First(&customer, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 
+	cs.events.Publish(models.EventCustomerUpdated, customer)
 	return &customer, nil
 }
 
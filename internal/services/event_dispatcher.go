@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EventEnvelope is the JSON body POSTed to subscribed endpoints
+type EventEnvelope struct {
+	ID        uuid.UUID        `json:"id"`
+	Event     models.EventType `json:"event"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      interface{}      `json:"data"`
+}
+
+// EventDispatcher fans CRM events out to outbound webhook subscriptions
+type EventDispatcher struct {
+	db           *gorm.DB
+	client       *http.Client
+	maxAttempts  int
+	disableAfter int
+	lease        time.Duration // how long an attempt holds its delivery
+}
+
+func NewEventDispatcher(db *gorm.DB, cfg *config.Config) *EventDispatcher {
+	timeout := time.Duration(cfg.EventWebhookTimeoutSeconds) * time.Second
+	return &EventDispatcher{
+		db:           db,
+		client:       &http.Client{Timeout: timeout},
+		maxAttempts:  cfg.EventWebhookMaxAttempts,
+		disableAfter: cfg.EventWebhookDisableAfter,
+		lease:        timeout + time.Minute,
+	}
+}
+
+// Publish records a delivery for every active subscription interested in event
+// and attempts each one in the background. It is safe to call on a nil dispatcher.
+func (d *EventDispatcher) Publish(event models.EventType, data interface{}) {
+	if d == nil {
+		return
+	}
+	var subs []models.WebhookSubscription
+	if err := d.db.Where("is_active = ?", true).Find(&subs).Error; err != nil {
+		log.Printf("event %s: failed to load subscriptions: %v", event, err)
+		return
+	}
+
+	envelope := EventEnvelope{ID: uuid.New(), Event: event, CreatedAt: time.Now(), Data: data}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("event %s: failed to marshal payload: %v", event, err)
+		return
+	}
+
+	for _, sub := range subs {
+		if !sub.Subscribes(event) {
+			continue
+		}
+		// the retry loop only picks this up if the immediate attempt never finished
+		next := time.Now().Add(d.lease)
+		delivery := models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        envelope.ID,
+			EventType:      event,
+			Status:         models.DeliveryStatusPending,
+			Payload:        string(payload),
+			NextAttemptAt:  &next,
+		}
+		if err := d.db.Create(&delivery).Error; err != nil {
+			log.Printf("event %s: failed to record delivery: %v", event, err)
+			continue
+		}
+		go d.attempt(sub, delivery)
+	}
+}
+
+// Redeliver queues a copy of an earlier delivery and sends it immediately
+func (d *EventDispatcher) Redeliver(deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := d.db.Preload("Subscription").First(&original, "id = ?", deliveryID).Error; err != nil {
+		return nil, err
+	}
+	retry := models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Status:         models.DeliveryStatusPending,
+		Payload:        original.Payload,
+	}
+	if err := d.db.Create(&retry).Error; err != nil {
+		return nil, err
+	}
+	d.attempt(original.Subscription, retry)
+	if err := d.db.First(&retry, "id = ?", retry.ID).Error; err != nil {
+		return nil, err
+	}
+	return &retry, nil
+}
+
+// Run retries pending deliveries until ctx is cancelled
+func (d *EventDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.retryDue()
+		}
+	}
+}
+
+func (d *EventDispatcher) retryDue() {
+	var due []models.WebhookDelivery
+	if err := d.db.Preload("Subscription").
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryStatusPending, time.Now()).
+		Order("next_attempt_at asc").Limit(100).Find(&due).Error; err != nil {
+		log.Printf("event retry: %v", err)
+		return
+	}
+	for _, delivery := range due {
+		// claim the delivery so other instances, and this one's next pass, leave it
+		// alone until the attempt has saved its outcome or the lease runs out
+		now := time.Now()
+		claim := d.db.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, models.DeliveryStatusPending, now).
+			Update("next_attempt_at", now.Add(d.lease))
+		if claim.Error != nil || claim.RowsAffected != 1 {
+			continue
+		}
+		if !delivery.Subscription.IsActive {
+			d.db.Model(&delivery).Updates(map[string]interface{}{"status": models.DeliveryStatusFailed, "error_message": "subscription disabled", "next_attempt_at": nil})
+			continue
+		}
+		d.attempt(delivery.Subscription, delivery)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" with the subscription secret
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *EventDispatcher) attempt(sub models.WebhookSubscription, delivery models.WebhookDelivery) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	delivery.Attempts++
+	delivery.ResponseCode = 0
+	delivery.ResponseBody = ""
+	delivery.ErrorMessage = ""
+
+	req, err := http.NewRequest("POST", sub.URL, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CRM-Event", string(delivery.EventType))
+		req.Header.Set("X-CRM-Delivery", delivery.ID.String())
+		req.Header.Set("X-CRM-Timestamp", timestamp)
+		req.Header.Set("X-CRM-Signature", "sha256="+Sign(sub.Secret, timestamp, body))
+
+		var resp *http.Response
+		resp, err = d.client.Do(req)
+		if err == nil {
+			respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			delivery.ResponseCode = resp.StatusCode
+			delivery.ResponseBody = string(respBody)
+		}
+	}
+
+	now := time.Now()
+	success := err == nil && delivery.ResponseCode >= 200 && delivery.ResponseCode < 300
+	switch {
+	case success:
+		delivery.Status = models.DeliveryStatusSuccess
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.DeliveryStatusFailed
+		delivery.NextAttemptAt = nil
+	default:
+		// exponential backoff: 1m, 2m, 4m, 8m ...
+		next := now.Add(time.Duration(1<<uint(delivery.Attempts-1)) * time.Minute)
+		delivery.NextAttemptAt = &next
+	}
+	if err != nil {
+		delivery.ErrorMessage = err.Error()
+	} else if !success {
+		delivery.ErrorMessage = "unexpected status " + strconv.Itoa(delivery.ResponseCode)
+	}
+	if err := d.db.Save(&delivery).Error; err != nil {
+		log.Printf("event delivery %s: failed to save: %v", delivery.ID, err)
+	}
+
+	d.recordOutcome(sub.ID, success, now)
+}
+
+// recordOutcome tracks consecutive failures and disables endpoints that keep failing
+func (d *EventDispatcher) recordOutcome(subscriptionID uuid.UUID, success bool, at time.Time) {
+	if success {
+		d.db.Model(&models.WebhookSubscription{}).Where("id = ?", subscriptionID).
+			Updates(map[string]interface{}{"consecutive_failures": 0, "last_delivery_at": at})
+		return
+	}
+	d.db.Model(&models.WebhookSubscription{}).Where("id = ?", subscriptionID).
+		UpdateColumn("consecutive_failures", gorm.Expr("consecutive_failures + 1"))
+	if d.disableAfter <= 0 {
+		return
+	}
+	res := d.db.Model(&models.WebhookSubscription{}).
+		Where("id = ? AND is_active = ? AND consecutive_failures >= ?", subscriptionID, true, d.disableAfter).
+		Updates(map[string]interface{}{"is_active": false, "disabled_at": at})
+	if res.RowsAffected > 0 {
+		log.Printf("webhook subscription %s disabled after %d consecutive failures", subscriptionID, d.disableAfter)
+	}
+}
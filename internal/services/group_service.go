@@ -0,0 +1,120 @@
+package services
+
+import (
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GetOrCreateGroup returns the customer record that stands for a WhatsApp group,
+// creating it together with its group contact on first sight
+func (cs *CustomerService) GetOrCreateGroup(groupID, subject string) (*models.Customer, error) {
+	var customer models.Customer
+	err := cs.db.Preload("Contact").First(&customer, "whatsapp_id = ?", groupID).Error
+	if err == nil {
+		if customer.Contact != nil && !customer.Contact.IsGroup {
+			cs.db.Model(customer.Contact).Update("is_group", true)
+			customer.Contact.IsGroup = true
+		}
+		return &customer, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	name := subject
+	if name == "" {
+		name = groupID
+	}
+	customer = models.Customer{WhatsAppID: groupID, Name: name}
+	contact := models.Contact{WhatsAppID: groupID, DisplayName: name, Status: models.ContactStatusValid, IsGroup: true, GroupSubject: subject}
+	err = cs.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&customer).Error; err != nil {
+			return err
+		}
+		contact.CustomerID = customer.ID
+		return tx.Create(&contact).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	customer.Contact = &contact
+	cs.events.Publish(models.EventCustomerCreated, customer)
+	return &customer, nil
+}
+
+// UpdateGroupInfo stores a new subject and/or description for a group contact
+func (cs *CustomerService) UpdateGroupInfo(contact *models.Contact, subject, description *string) error {
+	updates := map[string]interface{}{}
+	if subject != nil {
+		updates["group_subject"] = *subject
+		updates["display_name"] = *subject
+	}
+	if description != nil {
+		updates["group_desc"] = *description
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := cs.db.Model(contact).Updates(updates).Error; err != nil {
+		return err
+	}
+	if subject != nil && *subject != "" {
+		return cs.db.Model(&models.Customer{}).Where("id = ?", contact.CustomerID).Update("name", *subject).Error
+	}
+	return nil
+}
+
+// JoinGroup records participants as current members, re-activating anyone who had left
+func (cs *CustomerService) JoinGroup(contact *models.Contact, whatsappIDs []string, at time.Time) error {
+	for _, waID := range whatsappIDs {
+		if _, err := cs.EnsureParticipant(contact, waID, ""); err != nil {
+			return err
+		}
+		if err := cs.db.Model(&models.GroupParticipant{}).Where("contact_id = ? AND whatsapp_id = ?", contact.ID, waID).
+			Updates(map[string]interface{}{"joined_at": at, "left_at": nil}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LeaveGroup marks participants as no longer members
+func (cs *CustomerService) LeaveGroup(contact *models.Contact, whatsappIDs []string, at time.Time) error {
+	if len(whatsappIDs) == 0 {
+		return nil
+	}
+	return cs.db.Model(&models.GroupParticipant{}).Where("contact_id = ? AND whatsapp_id IN ?", contact.ID, whatsappIDs).
+		Update("left_at", at).Error
+}
+
+// SetParticipantRole promotes or demotes group members
+func (cs *CustomerService) SetParticipantRole(contact *models.Contact, whatsappIDs []string, role models.GroupRole) error {
+	if len(whatsappIDs) == 0 {
+		return nil
+	}
+	return cs.db.Model(&models.GroupParticipant{}).Where("contact_id = ? AND whatsapp_id IN ?", contact.ID, whatsappIDs).
+		Update("role", role).Error
+}
+
+// EnsureParticipant returns the group member with the given WhatsApp ID, adding them if unknown
+func (cs *CustomerService) EnsureParticipant(contact *models.Contact, whatsappID, name string) (*models.GroupParticipant, error) {
+	var p models.GroupParticipant
+	err := cs.db.First(&p, "contact_id = ? AND whatsapp_id = ?", contact.ID, whatsappID).Error
+	if err == nil {
+		if name != "" && p.Name != name {
+			cs.db.Model(&p).Update("name", name)
+			p.Name = name
+		}
+		return &p, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	p = models.GroupParticipant{ContactID: contact.ID, WhatsAppID: whatsappID, Name: name, Role: models.GroupRoleMember}
+	if err := cs.db.Create(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -65,22 +65,54 @@ func (u *MediaUploader) UploadAndSend(ctx context.Context, conv *models.Conversa
 	if err != nil { return nil, fmt.Errorf("signedurl: %w", err) }
 
 	// Send via WA
+	msg := models.Message{ConversationID: conv.ID, Type: models.MessageType(mediaType), Direction: models.MessageDirectionOutbound, MediaURL: signedURL, MediaPath: savedPath, Caption: caption, FileName: file.Filename}
 	var resp *whatsapp.SendMessageResponse
-	switch mediaType {
-	case "image":
+	switch {
+	case conv.IsGroup:
+		resp, err = u.wa.SendGroupMediaMessage(conv.Customer.WhatsAppID, groupMediaType(mediaType), signedURL, file.Filename, caption)
+	case mediaType == "image":
 		resp, err = u.wa.SendImageMessage(conv.Customer.WhatsAppID, signedURL, caption)
-	case "document":
+	case mediaType == "document":
 		resp, err = u.wa.SendDocumentMessage(conv.Customer.WhatsAppID, signedURL, file.Filename, caption)
-	case "audio":
+	case mediaType == "audio":
 		resp, err = u.wa.SendAudioMessage(conv.Customer.WhatsAppID, signedURL)
-	case "video":
+	case mediaType == "video":
 		resp, err = u.wa.SendVideoMessage(conv.Customer.WhatsAppID, signedURL, caption)
 	default:
 		resp, err = u.wa.SendDocumentMessage(conv.Customer.WhatsAppID, signedURL, file.Filename, caption)
 	}
-	if err != nil { return nil, err }
+	// failed sends are returned alongside the error so the caller can persist the reason
+	if err != nil { markFailed(&msg, err); return &msg, err }
 
 	now := time.Now()
-	msg := models.Message{ConversationID: conv.ID, WhatsAppID: resp.ID, Type: models.MessageType(mediaType), Direction: models.MessageDirectionOutbound, Status: models.MessageStatusSent, MediaURL: signedURL, Caption: caption, FileName: file.Filename, SentAt: &now}
+	msg.WhatsAppID, msg.Status, msg.SentAt = resp.ID, models.MessageStatusSent, &now
 	return &msg, nil
 }
+
+// groupMediaType is the message type a file goes to a group chat as; types the
+// group API does not know are sent as documents
+func groupMediaType(mediaType string) string {
+	switch mediaType {
+	case "image", "audio", "video":
+		return mediaType
+	}
+	return "document"
+}
+
+// Store saves a file to storage without sending it and returns the object path and media type
+func (u *MediaUploader) Store(ctx context.Context, file *multipart.FileHeader, declaredType string) (string, string, error) {
+	f, err := file.Open()
+	if err != nil { return "", "", err }
+	defer f.Close()
+
+	mediaType, contentType := detectType(file.Filename, file.Header.Get("Content-Type"), declaredType)
+	objectPath := storage.Join("whatsapp-crm", "canned", mediaType, uuid.New().String()+filepath.Ext(file.Filename))
+	savedPath, err := u.store.Save(ctx, f.(io.Reader), objectPath, contentType)
+	if err != nil { return "", "", fmt.Errorf("store: %w", err) }
+	return savedPath, mediaType, nil
+}
+
+// SignedURL returns a link WhatsApp can fetch a stored object from
+func (u *MediaUploader) SignedURL(ctx context.Context, path string) (string, error) {
+	return u.store.SignedURL(ctx, path, u.expiry)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/pkg/whatsapp"
+)
+
+// FailureReason is one row of the failed-messages report
+type FailureReason struct {
+	ErrorCode    string    `json:"error_code"`
+	ErrorTitle   string    `json:"error_title"`
+	Count        int64     `json:"count"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+}
+
+// markFailed stamps msg as failed with the provider reason carried by err
+func markFailed(msg *models.Message, err error) {
+	now := time.Now()
+	msg.Status = models.MessageStatusFailed
+	msg.FailedAt = &now
+	var apiErr *whatsapp.APIError
+	if errors.As(err, &apiErr) {
+		msg.ErrorCode = apiErr.Code
+		msg.ErrorTitle = apiErr.Title
+		msg.ErrorDetails = apiErr.Details
+		return
+	}
+	msg.ErrorCode = "send_error"
+	msg.ErrorTitle = err.Error()
+}
+
+// saveFailed persists msg as a failed outbound message and returns the original send error
+func (ms *MessageService) saveFailed(msg *models.Message, err error) error {
+	markFailed(msg, err)
+	_ = ms.db.Create(msg)
+	return err
+}
+
+// FailureReport groups failed messages by provider reason, most frequent first
+func (ms *MessageService) FailureReport(from, to *time.Time) ([]FailureReason, error) {
+	query := ms.db.Model(&models.Message{}).Where("status = ?", models.MessageStatusFailed)
+	if from != nil {
+		query = query.Where("COALESCE(failed_at, updated_at) >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("COALESCE(failed_at, updated_at) < ?", *to)
+	}
+
+	var reasons []FailureReason
+	err := query.Select("error_code, error_title, COUNT(*) AS count, MAX(COALESCE(failed_at, updated_at)) AS last_failed_at").
+		Group("error_code, error_title").
+		Order("count desc").
+		Scan(&reasons).Error
+	return reasons, err
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"strings"
 	"time"
 	"whatsapp-crm/internal/config"
 	"whatsapp-crm/internal/models"
@@ -11,50 +12,129 @@ import (
 	"gorm.io/gorm"
 )
 
-type MessageService struct { db *gorm.DB; wa *whatsapp.Client }
+type MessageService struct { db *gorm.DB; wa *whatsapp.Client; hours *BusinessHoursService }
 
-func NewMessageService(db *gorm.DB, cfg *config.Config) *MessageService { return &MessageService{db: db, wa: whatsapp.NewClient(cfg)} }
+func NewMessageService(db *gorm.DB, cfg *config.Config, hours *BusinessHoursService) *MessageService { return &MessageService{db: db, wa: whatsapp.NewClient(cfg), hours: hours} }
 
-func (ms *MessageService) SendText(conversationID uuid.UUID, content string) (*models.Message, error) {
+// SendText sends text written by agent by
+func (ms *MessageService) SendText(conversationID uuid.UUID, content string, by *models.User) (*models.Message, error) {
+	return ms.sendText(conversationID, content, nil, by)
+}
+
+// SendAutomatedText sends text on behalf of the system; it does not count as an agent reply
+func (ms *MessageService) SendAutomatedText(conversationID uuid.UUID, content string) (*models.Message, error) {
+	return ms.sendText(conversationID, content, nil, nil)
+}
+
+// sendText sends text from agent by, or from the system when by is nil
+func (ms *MessageService) sendText(conversationID uuid.UUID, content string, cannedID *uuid.UUID, by *models.User) (*models.Message, error) {
 	var conv models.Conversation
 	if err := ms.db.Preload("Customer").First(&conv, "id = ?", conversationID).Error; err != nil { return nil, err }
-	resp, err := ms.wa.SendTextMessage(conv.Customer.WhatsAppID, content)
-	if err != nil { return nil, fmt.Errorf("send text: %w", err) }
+	msg := models.Message{ConversationID: conversationID, Type: models.MessageTypeText, Direction: models.MessageDirectionOutbound, Content: content, CannedResponseID: cannedID, Automated: by == nil}
+	ms.StampSender(&conv, &msg, by)
+	var resp *whatsapp.SendMessageResponse
+	var err error
+	if conv.IsGroup {
+		resp, err = ms.wa.SendGroupTextMessage(conv.Customer.WhatsAppID, content)
+	} else {
+		resp, err = ms.wa.SendTextMessage(conv.Customer.WhatsAppID, content)
+	}
+	if err != nil { return nil, ms.saveFailed(&msg, fmt.Errorf("send text: %w", err)) }
 	now := time.Now()
-	msg := models.Message{ConversationID: conversationID, WhatsAppID: resp.ID, Type: models.MessageTypeText, Direction: models.MessageDirectionOutbound, Status: models.MessageStatusSent, Content: content, SentAt: &now}
+	msg.WhatsAppID, msg.Status, msg.SentAt = resp.ID, models.MessageStatusSent, &now
+	ms.StampReply(&conv, &msg, now)
 	if err := ms.db.Create(&msg).Error; err != nil { return nil, err }
-	conv.LastMessageAt = &now
-	_ = ms.db.Save(&conv)
+	_ = ms.SaveReply(&conv)
 	return &msg, nil
 }
 
-func (ms *MessageService) SendMediaMessage(conversationID uuid.UUID, mediaType, mediaURL, caption, filename string) (*models.Message, error) {
+// SendAutomatedInteractive sends buttons or a list on behalf of the system
+func (ms *MessageService) SendAutomatedInteractive(conversationID uuid.UUID, interactive whatsapp.InteractiveMessage) (*models.Message, error) {
 	var conv models.Conversation
 	if err := ms.db.Preload("Customer").First(&conv, "id = ?", conversationID).Error; err != nil { return nil, err }
+	if conv.IsGroup { return nil, fmt.Errorf("interactive messages cannot be sent to group conversations") }
+	msg := models.Message{ConversationID: conversationID, Type: models.MessageTypeInteractive, Direction: models.MessageDirectionOutbound, Content: interactive.Body, Automated: true}
+	resp, err := ms.wa.SendInteractiveMessage(conv.Customer.WhatsAppID, interactive)
+	if err != nil { return nil, ms.saveFailed(&msg, fmt.Errorf("send interactive: %w", err)) }
+	now := time.Now()
+	msg.WhatsAppID, msg.Status, msg.SentAt = resp.ID, models.MessageStatusSent, &now
+	ms.StampReply(&conv, &msg, now)
+	if err := ms.db.Create(&msg).Error; err != nil { return nil, err }
+	_ = ms.SaveReply(&conv)
+	return &msg, nil
+}
+
+func (ms *MessageService) SendMediaMessage(conversationID uuid.UUID, mediaType, mediaURL, caption, filename string, by *models.User) (*models.Message, error) {
+	return ms.sendMedia(conversationID, mediaType, mediaURL, caption, filename, nil, by)
+}
+
+func (ms *MessageService) sendMedia(conversationID uuid.UUID, mediaType, mediaURL, caption, filename string, cannedID *uuid.UUID, by *models.User) (*models.Message, error) {
+	var conv models.Conversation
+	if err := ms.db.Preload("Customer").First(&conv, "id = ?", conversationID).Error; err != nil { return nil, err }
+	msg := models.Message{ConversationID: conversationID, Type: models.MessageType(mediaType), Direction: models.MessageDirectionOutbound, MediaURL: mediaURL, Caption: caption, FileName: filename, CannedResponseID: cannedID}
+	ms.StampSender(&conv, &msg, by)
 	var resp *whatsapp.SendMessageResponse
 	var err error
-	switch mediaType {
-	case "image":
+	switch {
+	case mediaType != "image" && mediaType != "document":
+		return nil, fmt.Errorf("unsupported media type: %s", mediaType)
+	case conv.IsGroup:
+		resp, err = ms.wa.SendGroupMediaMessage(conv.Customer.WhatsAppID, mediaType, mediaURL, filename, caption)
+	case mediaType == "image":
 		resp, err = ms.wa.SendImageMessage(conv.Customer.WhatsAppID, mediaURL, caption)
-	case "document":
-		resp, err = ms.wa.SendDocumentMessage(conv.Customer.WhatsAppID, mediaURL, filename, caption)
 	default:
-		return nil, fmt.Errorf("unsupported media type: %s", mediaType)
+		resp, err = ms.wa.SendDocumentMessage(conv.Customer.WhatsAppID, mediaURL, filename, caption)
 	}
-	if err != nil { return nil, fmt.Errorf("send media: %w", err) }
+	if err != nil { return nil, ms.saveFailed(&msg, fmt.Errorf("send media: %w", err)) }
 	now := time.Now()
-	msg := models.Message{ConversationID: conversationID, WhatsAppID: resp.ID, Type: models.MessageType(mediaType), Direction: models.MessageDirectionOutbound, Status: models.MessageStatusSent, MediaURL: mediaURL, Caption: caption, FileName: filename, SentAt: &now}
+	msg.WhatsAppID, msg.Status, msg.SentAt = resp.ID, models.MessageStatusSent, &now
+	ms.StampReply(&conv, &msg, now)
 	if err := ms.db.Create(&msg).Error; err != nil { return nil, err }
-	conv.LastMessageAt = &now
-	_ = ms.db.Save(&conv)
+	_ = ms.SaveReply(&conv)
 	return &msg, nil
 }
 
-func (ms *MessageService) SendTemplateMessage(conversationID uuid.UUID, templateID uuid.UUID, variables map[string]string) (*models.Message, error) {
-	var conv models.Conversation
-	if err := ms.db.Preload("Customer").First(&conv, "id = ?", conversationID).Error; err != nil { return nil, err }
+// SendCanned sends content produced from a canned response, as a caption when mediaURL is set,
+// and counts the use. Failed sends keep the reference but are not counted.
+func (ms *MessageService) SendCanned(conversationID uuid.UUID, canned *models.CannedResponse, content, mediaURL string, by *models.User) (*models.Message, error) {
+	var msg *models.Message
+	var err error
+	if mediaURL != "" {
+		msg, err = ms.sendMedia(conversationID, string(canned.MediaType), mediaURL, content, canned.FileName, &canned.ID, by)
+	} else {
+		msg, err = ms.sendText(conversationID, content, &canned.ID, by)
+	}
+	if err != nil { return nil, err }
+	if mediaURL != "" && canned.MediaPath != "" {
+		// the signed link expires; keep the object so exports can sign it again
+		msg.MediaPath = canned.MediaPath
+		ms.db.Model(msg).Update("media_path", msg.MediaPath)
+	}
+	ms.db.Model(&models.CannedResponse{}).Where("id = ?", canned.ID).Updates(map[string]interface{}{"usage_count": gorm.Expr("usage_count + 1"), "last_used_at": time.Now()})
+	return msg, nil
+}
+
+func (ms *MessageService) SendTemplateMessage(conversationID uuid.UUID, templateID uuid.UUID, variables map[string]string, by *models.User) (*models.Message, error) {
 	var tpl models.Template
 	if err := ms.db.First(&tpl, "id = ?", templateID).Error; err != nil { return nil, err }
+	return ms.sendTemplate(conversationID, &tpl, variables, by)
+}
+
+// SendAutomatedTemplate sends a template by name on behalf of the system; it does not count as an agent reply.
+// The name may carry the language to send it in as name:language, e.g. closing:id.
+func (ms *MessageService) SendAutomatedTemplate(conversationID uuid.UUID, templateName string, variables map[string]string) (*models.Message, error) {
+	name, language, _ := strings.Cut(templateName, ":")
+	query := ms.db.Where("name = ?", name)
+	if language != "" { query = query.Where("language = ?", language) }
+	var tpl models.Template
+	if err := query.First(&tpl).Error; err != nil { return nil, fmt.Errorf("template %s: %w", templateName, err) }
+	return ms.sendTemplate(conversationID, &tpl, variables, nil)
+}
+
+func (ms *MessageService) sendTemplate(conversationID uuid.UUID, tpl *models.Template, variables map[string]string, by *models.User) (*models.Message, error) {
+	var conv models.Conversation
+	if err := ms.db.Preload("Customer").First(&conv, "id = ?", conversationID).Error; err != nil { return nil, err }
+	if conv.IsGroup { return nil, fmt.Errorf("templates cannot be sent to group conversations") }
 	// build components simple body order per map iteration
 	components := []whatsapp.TemplateComponent{}
 	if len(variables) > 0 {
@@ -64,13 +144,25 @@ func (ms *MessageService) SendTemplateMessage(conversationID uuid.UUID, template
 		}
 		components = append(components, whatsapp.TemplateComponent{Type: "body", Parameters: params})
 	}
+	msg := models.Message{ConversationID: conversationID, Type: models.MessageTypeTemplate, Direction: models.MessageDirectionOutbound, Content: tpl.Content, TemplateID: &tpl.ID, Automated: by == nil}
+	ms.StampSender(&conv, &msg, by)
 	resp, err := ms.wa.SendTemplateMessage(conv.Customer.WhatsAppID, tpl.Name, tpl.Language, components)
-	if err != nil { return nil, fmt.Errorf("send template: %w", err) }
+	if err != nil { return nil, ms.saveFailed(&msg, fmt.Errorf("send template: %w", err)) }
 	now := time.Now()
-	msg := models.Message{ConversationID: conversationID, WhatsAppID: resp.ID, Type: models.MessageTypeTemplate, Direction: models.MessageDirectionOutbound, Status: models.MessageStatusSent, Content: tpl.Content, TemplateID: &templateID, SentAt: &now}
+	msg.WhatsAppID, msg.Status, msg.SentAt = resp.ID, models.MessageStatusSent, &now
+	ms.StampReply(&conv, &msg, now)
 	if err := ms.db.Create(&msg).Error; err != nil { return nil, err }
-	ms.db.Model(&tpl).UpdateColumn("usage_count", gorm.Expr("usage_count + 1"))
-	conv.LastMessageAt = &now
-	_ = ms.db.Save(&conv)
+	ms.db.Model(tpl).UpdateColumn("usage_count", gorm.Expr("usage_count + 1"))
+	_ = ms.SaveReply(&conv)
 	return &msg, nil
 }
+
+// StampSender records the agent sending msg and flags the message when the conversation
+// is assigned to someone else, so the timeline shows who stepped in
+func (ms *MessageService) StampSender(conv *models.Conversation, msg *models.Message, by *models.User) {
+	if by == nil {
+		return
+	}
+	msg.SentByID = &by.ID
+	msg.ByNonAssignee = conv.AgentID != nil && *conv.AgentID != by.ID
+}
@@ -25,25 +25,28 @@ func (ms *MessageService) UploadAndSendLocal(conversationID uuid.UUID, filePath,
 	if err != nil { return nil, fmt.Errorf("upload media: %w", err) }
 
 	// send by media type
+	msg := models.Message{ConversationID: conversationID, Type: models.MessageType(mediaType), Direction: models.MessageDirectionOutbound, MediaURL: uploadURL, Caption: caption, FileName: filepath.Base(filePath)}
 	var resp *whatsapp.SendMessageResponse
-	switch mediaType {
-	case "image":
+	switch {
+	case mediaType != "image" && mediaType != "document" && mediaType != "audio" && mediaType != "video":
+		return nil, fmt.Errorf("unsupported media type: %s", mediaType)
+	case conv.IsGroup:
+		resp, err = ms.wa.SendGroupMediaMessage(conv.Customer.WhatsAppID, mediaType, uploadURL, filepath.Base(filePath), caption)
+	case mediaType == "image":
 		resp, err = ms.wa.SendImageMessage(conv.Customer.WhatsAppID, uploadURL, caption)
-	case "document":
+	case mediaType == "document":
 		resp, err = ms.wa.SendDocumentMessage(conv.Customer.WhatsAppID, uploadURL, filepath.Base(filePath), caption)
-	case "audio":
+	case mediaType == "audio":
 		resp, err = ms.wa.SendAudioMessage(conv.Customer.WhatsAppID, uploadURL)
-	case "video":
-		resp, err = ms.wa.SendVideoMessage(conv.Customer.WhatsAppID, uploadURL, caption)
 	default:
-		return nil, fmt.Errorf("unsupported media type: %s", mediaType)
+		resp, err = ms.wa.SendVideoMessage(conv.Customer.WhatsAppID, uploadURL, caption)
 	}
-	if err != nil { return nil, err }
+	if err != nil { return nil, ms.saveFailed(&msg, err) }
 
 	now := time.Now()
-	msg := models.Message{ConversationID: conversationID, WhatsAppID: resp.ID, Type: models.MessageType(mediaType), Direction: models.MessageDirectionOutbound, Status: models.MessageStatusSent, MediaURL: uploadURL, Caption: caption, FileName: filepath.Base(filePath), SentAt: &now}
+	msg.WhatsAppID, msg.Status, msg.SentAt = resp.ID, models.MessageStatusSent, &now
+	ms.StampReply(&conv, &msg, now)
 	if err := ms.db.Create(&msg).Error; err != nil { return nil, err }
-	conv.LastMessageAt = &now
-	_ = ms.db.Save(&conv)
+	_ = ms.SaveReply(&conv)
 	return &msg, nil
 }
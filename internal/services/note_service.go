@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEmptyNote     = errors.New("note body is required")
+	ErrNotNoteAuthor = errors.New("only the author can change this note")
+)
+
+// mentionPattern matches @someone or @someone@example.com; the text after @ is
+// matched against user emails
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// NoteService manages internal notes on conversations and notifies mentioned users
+type NoteService struct {
+	db            *gorm.DB
+	notifications *NotificationService
+}
+
+func NewNoteService(db *gorm.DB, notifications *NotificationService) *NoteService {
+	return &NoteService{db: db, notifications: notifications}
+}
+
+func preloadNoteAuthor(db *gorm.DB) *gorm.DB { return db.Select("id, name, email, avatar") }
+
+// List returns a conversation's note threads oldest first, each with its replies
+func (ns *NoteService) List(conversationID uuid.UUID) ([]models.ConversationNote, error) {
+	var notes []models.ConversationNote
+	err := ns.db.Preload("Author", preloadNoteAuthor).
+		Preload("Mentions.User", preloadNoteAuthor).
+		Preload("Replies", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).
+		Preload("Replies.Author", preloadNoteAuthor).
+		Preload("Replies.Mentions.User", preloadNoteAuthor).
+		Where("conversation_id = ? AND parent_id IS NULL", conversationID).
+		Order("created_at asc").Find(&notes).Error
+	return notes, err
+}
+
+// Get returns one note with its edit history
+func (ns *NoteService) Get(noteID uuid.UUID) (*models.ConversationNote, error) {
+	var note models.ConversationNote
+	err := ns.db.Preload("Author", preloadNoteAuthor).
+		Preload("Mentions.User", preloadNoteAuthor).
+		Preload("Revisions", func(db *gorm.DB) *gorm.DB { return db.Order("created_at desc") }).
+		First(&note, "id = ?", noteID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// Create adds a note, or a reply when parentID is set. Replies to replies join the
+// root thread.
+func (ns *NoteService) Create(conversationID, authorID uuid.UUID, body string, parentID *uuid.UUID) (*models.ConversationNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrEmptyNote
+	}
+	var exists int64
+	if err := ns.db.Model(&models.Conversation{}).Where("id = ?", conversationID).Count(&exists).Error; err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	note := models.ConversationNote{ConversationID: conversationID, AuthorID: authorID, Body: body}
+	if parentID != nil {
+		var parent models.ConversationNote
+		if err := ns.db.First(&parent, "id = ? AND conversation_id = ?", *parentID, conversationID).Error; err != nil {
+			return nil, err
+		}
+		root := parent.ID
+		if parent.ParentID != nil {
+			root = *parent.ParentID
+		}
+		note.ParentID = &root
+	}
+	if err := ns.db.Create(&note).Error; err != nil {
+		return nil, err
+	}
+	ns.mention(&note, authorID)
+	return ns.Get(note.ID)
+}
+
+// Update edits a note's body, keeping the previous body as a revision. Only new
+// mentions are notified.
+func (ns *NoteService) Update(noteID, editorID uuid.UUID, body string) (*models.ConversationNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrEmptyNote
+	}
+	var note models.ConversationNote
+	if err := ns.db.First(&note, "id = ?", noteID).Error; err != nil {
+		return nil, err
+	}
+	if note.AuthorID != editorID {
+		return nil, ErrNotNoteAuthor
+	}
+	if note.Body == body {
+		return ns.Get(note.ID)
+	}
+	now := time.Now()
+	err := ns.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.NoteRevision{NoteID: note.ID, Body: note.Body, EditedBy: editorID}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&note).Updates(map[string]interface{}{"body": body, "edited_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	note.Body = body
+	ns.mention(&note, editorID)
+	return ns.Get(note.ID)
+}
+
+// Delete removes a note; moderators may remove anyone's
+func (ns *NoteService) Delete(noteID, userID uuid.UUID, moderator bool) error {
+	var note models.ConversationNote
+	if err := ns.db.First(&note, "id = ?", noteID).Error; err != nil {
+		return err
+	}
+	if note.AuthorID != userID && !moderator {
+		return ErrNotNoteAuthor
+	}
+	return ns.db.Where("id = ? OR parent_id = ?", note.ID, note.ID).Delete(&models.ConversationNote{}).Error
+}
+
+// mention records the users @mentioned in a note and notifies the ones not
+// mentioned in it before. Authors are not notified about themselves.
+func (ns *NoteService) mention(note *models.ConversationNote, actorID uuid.UUID) {
+	for _, userID := range ns.resolveMentions(note.Body) {
+		if userID == actorID {
+			continue
+		}
+		res := ns.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.NoteMention{NoteID: note.ID, UserID: userID})
+		if res.Error != nil {
+			log.Printf("recording mention on note %s: %v", note.ID, res.Error)
+			continue
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		err := ns.notifications.Notify(&models.Notification{
+			UserID:         userID,
+			Type:           models.NotificationMention,
+			ConversationID: &note.ConversationID,
+			NoteID:         &note.ID,
+			ActorID:        &actorID,
+			Message:        notePreview(note.Body),
+		})
+		if err != nil {
+			log.Printf("notifying %s of mention: %v", userID, err)
+		}
+	}
+}
+
+// resolveMentions maps @handles to users: a full email matches exactly, otherwise the
+// handle must be the local part of exactly one user's email
+func (ns *NoteService) resolveMentions(body string) []uuid.UUID {
+	seen := map[uuid.UUID]bool{}
+	var ids []uuid.UUID
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.TrimRight(strings.ToLower(m[1]), ".")
+		var users []models.User
+		if strings.Contains(handle, "@") {
+			ns.db.Select("id").Where("email = ?", handle).Find(&users)
+		} else {
+			ns.db.Select("id").Where("email LIKE ?", escapeLike(handle)+"@%").Limit(2).Find(&users)
+		}
+		if len(users) != 1 || seen[users[0].ID] {
+			continue
+		}
+		seen[users[0].ID] = true
+		ids = append(ids, users[0].ID)
+	}
+	return ids
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func notePreview(body string) string {
+	body = strings.Join(strings.Fields(body), " ")
+	if r := []rune(body); len(r) > 140 {
+		return fmt.Sprintf("%s…", string(r[:140]))
+	}
+	return body
+}
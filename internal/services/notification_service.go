@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func notificationChannel(userID uuid.UUID) string { return "notifications:user:" + userID.String() }
+
+// NotificationService stores per-user notifications and pushes them to the user's
+// open WebSockets on any instance through Redis.
+type NotificationService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+func NewNotificationService(db *gorm.DB, rdb *redis.Client) *NotificationService {
+	return &NotificationService{db: db, rdb: rdb}
+}
+
+// Notify saves n and publishes it to its user
+func (ns *NotificationService) Notify(n *models.Notification) error {
+	if err := ns.db.Create(n).Error; err != nil {
+		return err
+	}
+	if n.ActorID != nil {
+		ns.db.Select("id, name, email").First(&n.Actor, "id = ?", *n.ActorID)
+	}
+	if payload, err := json.Marshal(n); err == nil {
+		if err := ns.rdb.Publish(context.Background(), notificationChannel(n.UserID), payload).Err(); err != nil {
+			log.Printf("publishing notification %s: %v", n.ID, err)
+		}
+	}
+	return nil
+}
+
+// List returns a user's notifications, newest first, with the unread count
+func (ns *NotificationService) List(userID uuid.UUID, unreadOnly bool, offset, limit int) ([]models.Notification, int64, error) {
+	query := ns.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var notifications []models.Notification
+	err := query.Preload("Actor", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email")
+	}).Order("created_at desc").Offset(offset).Limit(limit).Find(&notifications).Error
+	return notifications, total, err
+}
+
+// Unread counts a user's unread notifications
+func (ns *NotificationService) Unread(userID uuid.UUID) int64 {
+	var n int64
+	ns.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&n)
+	return n
+}
+
+// MarkRead marks one notification read; id nil marks all of the user's notifications
+func (ns *NotificationService) MarkRead(userID uuid.UUID, id *uuid.UUID) error {
+	query := ns.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if id != nil {
+		query = query.Where("id = ?", *id)
+	}
+	return query.Update("read_at", time.Now()).Error
+}
+
+// Subscribe streams new notifications for one user until ctx is cancelled
+func (ns *NotificationService) Subscribe(ctx context.Context, userID uuid.UUID) <-chan models.Notification {
+	out := make(chan models.Notification, 16)
+	sub := ns.rdb.Subscribe(ctx, notificationChannel(userID))
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var n models.Notification
+				if err := json.Unmarshal([]byte(msg.Payload), &n); err != nil {
+					continue
+				}
+				select {
+				case out <- n:
+				default:
+				}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/models"
+)
+
+// customerWindow is how long after a customer message free-form replies are allowed
+const customerWindow = 24 * time.Hour
+
+// OutOfOfficeService answers customers who write outside business hours
+type OutOfOfficeService struct {
+	hours    *BusinessHoursService
+	messages *MessageService
+}
+
+func NewOutOfOfficeService(hours *BusinessHoursService, messages *MessageService) *OutOfOfficeService {
+	return &OutOfOfficeService{hours: hours, messages: messages}
+}
+
+// HandleInbound sends the conversation's out-of-office reply when a message sent at
+// receivedAt arrives while its team is closed. The reply goes out once per closed
+// period: the period is identified by the time business hours next open. Outside the
+// customer's 24h window only the configured template can be sent. Groups get no reply.
+func (oo *OutOfOfficeService) HandleInbound(conv *models.Conversation, receivedAt time.Time) {
+	if conv.IsGroup {
+		return
+	}
+	reply, hours := oo.hours.AutoReplyFor(conv.TeamID)
+	now := time.Now()
+	if receivedAt.IsZero() {
+		receivedAt = now
+	}
+	if !reply.Enabled || hours.AlwaysOpen() || hours.IsOpen(now) {
+		return
+	}
+	inWindow := now.Sub(receivedAt) < customerWindow
+	if !(reply.Message != "" && inWindow) && reply.Template == "" {
+		return
+	}
+
+	// claim the period before sending so concurrent messages reply once
+	reopens := hours.NextOpen(now)
+	previous := conv.AutoReplyFor
+	res := oo.messages.db.Model(&models.Conversation{}).
+		Where("id = ? AND (auto_reply_for IS NULL OR auto_reply_for <> ?)", conv.ID, reopens).
+		Update("auto_reply_for", reopens)
+	if res.Error != nil || res.RowsAffected == 0 {
+		// already answered during this closed period
+		return
+	}
+
+	var err error
+	if reply.Message != "" && inWindow {
+		text := strings.ReplaceAll(reply.Message, "{{next_open}}", reopens.In(hours.Location).Format("Mon 02 Jan 15:04 MST"))
+		_, err = oo.messages.SendAutomatedText(conv.ID, text)
+	} else {
+		_, err = oo.messages.SendAutomatedTemplate(conv.ID, reply.Template, nil)
+	}
+	if err != nil {
+		log.Printf("out-of-office reply for conversation %s: %v", conv.ID, err)
+		// release the claim so the next message in this period tries again
+		oo.messages.db.Model(&models.Conversation{}).Where("id = ? AND auto_reply_for = ?", conv.ID, reopens).Update("auto_reply_for", previous)
+		return
+	}
+	conv.AutoReplyFor = &reopens
+}
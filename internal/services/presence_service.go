@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const presenceChannel = "presence:changes"
+
+// PresenceChange is broadcast whenever an agent's availability changes
+type PresenceChange struct {
+	UserID uuid.UUID             `json:"user_id"`
+	Status models.PresenceStatus `json:"status"`
+	At     time.Time             `json:"at"`
+}
+
+// AgentPresence is one row of the supervisor live view
+type AgentPresence struct {
+	ID         uuid.UUID             `json:"id"`
+	Name       string                `json:"name"`
+	Email      string                `json:"email"`
+	Role       models.UserRole       `json:"role"`
+	Presence   models.PresenceStatus `json:"presence"`
+	PresenceAt *time.Time            `json:"presence_at"`
+}
+
+// PresenceService tracks agent availability in Redis (with a TTL refreshed by
+// heartbeats) and mirrors it to users.status.
+type PresenceService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+	ttl time.Duration
+
+	mu        sync.RWMutex
+	listeners []func(PresenceChange)
+}
+
+func NewPresenceService(db *gorm.DB, rdb *redis.Client, cfg *config.Config) *PresenceService {
+	return &PresenceService{db: db, rdb: rdb, ttl: time.Duration(cfg.PresenceTTLSeconds) * time.Second}
+}
+
+func presenceKey(userID uuid.UUID) string { return "presence:user:" + userID.String() }
+
+// OnChange registers fn to be called for every presence change made by this instance
+func (ps *PresenceService) OnChange(fn func(PresenceChange)) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.listeners = append(ps.listeners, fn)
+}
+
+// Get returns the current presence of a user; missing or expired keys mean offline
+func (ps *PresenceService) Get(ctx context.Context, userID uuid.UUID) models.PresenceStatus {
+	v, err := ps.rdb.Get(ctx, presenceKey(userID)).Result()
+	if err != nil || v == "" {
+		return models.PresenceOffline
+	}
+	return models.PresenceStatus(v)
+}
+
+// IsAvailable reports whether the user can take new work right now
+func (ps *PresenceService) IsAvailable(ctx context.Context, userID uuid.UUID) bool {
+	return ps.Get(ctx, userID) == models.PresenceAvailable
+}
+
+// Set stores an explicit presence toggle
+func (ps *PresenceService) Set(ctx context.Context, userID uuid.UUID, status models.PresenceStatus) error {
+	prev := ps.Get(ctx, userID)
+	var err error
+	if status == models.PresenceOffline {
+		err = ps.rdb.Del(ctx, presenceKey(userID)).Err()
+	} else {
+		err = ps.rdb.Set(ctx, presenceKey(userID), string(status), ps.ttl).Err()
+	}
+	if err != nil {
+		return err
+	}
+	if prev != status {
+		ps.changed(ctx, userID, status)
+	}
+	return nil
+}
+
+// Heartbeat keeps the current presence alive; an agent with no presence becomes available
+func (ps *PresenceService) Heartbeat(ctx context.Context, userID uuid.UUID) (models.PresenceStatus, error) {
+	status := ps.Get(ctx, userID)
+	if status == models.PresenceOffline {
+		return models.PresenceAvailable, ps.Set(ctx, userID, models.PresenceAvailable)
+	}
+	return status, ps.rdb.Expire(ctx, presenceKey(userID), ps.ttl).Err()
+}
+
+// List returns every active agent and supervisor with their live presence
+func (ps *PresenceService) List(ctx context.Context) ([]AgentPresence, error) {
+	var users []models.User
+	if err := ps.db.Where("role IN ? AND status <> ?", []models.UserRole{models.RoleAgent, models.RoleSupervisor}, models.UserStatusInactive).
+		Order("name asc").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	out := make([]AgentPresence, 0, len(users))
+	if len(users) == 0 {
+		return out, nil
+	}
+
+	keys := make([]string, len(users))
+	for i, u := range users {
+		keys[i] = presenceKey(u.ID)
+	}
+	values, err := ps.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, u := range users {
+		presence := models.PresenceOffline
+		if v, ok := values[i].(string); ok && v != "" {
+			presence = models.PresenceStatus(v)
+		}
+		out = append(out, AgentPresence{ID: u.ID, Name: u.Name, Email: u.Email, Role: u.Role, Presence: presence, PresenceAt: u.PresenceAt})
+	}
+	return out, nil
+}
+
+// AvailableAgents returns the IDs of agents currently marked available
+func (ps *PresenceService) AvailableAgents(ctx context.Context) ([]uuid.UUID, error) {
+	list, err := ps.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	for _, a := range list {
+		if a.Role == models.RoleAgent && a.Presence == models.PresenceAvailable {
+			ids = append(ids, a.ID)
+		}
+	}
+	return ids, nil
+}
+
+// Subscribe streams presence changes from every instance until ctx is cancelled
+func (ps *PresenceService) Subscribe(ctx context.Context) <-chan PresenceChange {
+	out := make(chan PresenceChange, 16)
+	sub := ps.rdb.Subscribe(ctx, presenceChannel)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var change PresenceChange
+				if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+					continue
+				}
+				select {
+				case out <- change:
+				default:
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Run marks agents offline once their heartbeat TTL has lapsed, until ctx is cancelled
+func (ps *PresenceService) Run(ctx context.Context) {
+	interval := ps.ttl / 3
+	if interval < 5*time.Second {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ps.sweepExpired(ctx)
+		}
+	}
+}
+
+func (ps *PresenceService) sweepExpired(ctx context.Context) {
+	var ids []uuid.UUID
+	if err := ps.db.Model(&models.User{}).Where("status IN ?", []models.UserStatus{models.UserStatusOnline, models.UserStatusAway}).Pluck("id", &ids).Error; err != nil {
+		log.Printf("presence sweep: %v", err)
+		return
+	}
+	for _, id := range ids {
+		n, err := ps.rdb.Exists(ctx, presenceKey(id)).Result()
+		if err == nil && n == 0 {
+			ps.changed(ctx, id, models.PresenceOffline)
+		}
+	}
+}
+
+// changed mirrors the presence to the user row and notifies listeners and subscribers
+func (ps *PresenceService) changed(ctx context.Context, userID uuid.UUID, status models.PresenceStatus) {
+	now := time.Now()
+	// never overwrite an account that an admin has deactivated
+	ps.db.Model(&models.User{}).Where("id = ? AND status <> ?", userID, models.UserStatusInactive).
+		Updates(map[string]interface{}{"status": status.UserStatus(), "presence_at": now})
+
+	change := PresenceChange{UserID: userID, Status: status, At: now}
+	if payload, err := json.Marshal(change); err == nil {
+		ps.rdb.Publish(ctx, presenceChannel, payload)
+	}
+
+	ps.mu.RLock()
+	listeners := append([]func(PresenceChange){}, ps.listeners...)
+	ps.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(change)
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReadMessage is returned when the message to mark read up to is not in the conversation
+var ErrReadMessage = errors.New("message_id is not a message of this conversation")
+
+// unreadInbound matches the inbound messages that the agent bound to ? has not read.
+// Without a cursor in a conversation the agent has read everything from before their
+// account was created. Imported history is never unread.
+const unreadInbound = "FROM messages JOIN users ON users.id = ? " +
+	"LEFT JOIN conversation_reads ON conversation_reads.conversation_id = messages.conversation_id AND conversation_reads.user_id = users.id " +
+	"WHERE messages.direction = 'inbound' AND messages.imported = false " +
+	"AND messages.created_at > COALESCE(conversation_reads.last_read_at, users.created_at)"
+
+// unreadCounts counts each conversation's unread inbound messages for one agent
+func (cs *ConversationService) unreadCounts(viewer uuid.UUID, convs []models.Conversation) (map[uuid.UUID]int, error) {
+	out := make(map[uuid.UUID]int, len(convs))
+	if len(convs) == 0 || viewer == uuid.Nil {
+		return out, nil
+	}
+	ids := make([]uuid.UUID, len(convs))
+	for i := range convs {
+		ids[i] = convs[i].ID
+	}
+	var rows []struct {
+		ConversationID uuid.UUID
+		Unread         int
+	}
+	if err := cs.db.Raw("SELECT messages.conversation_id, COUNT(*) AS unread "+unreadInbound+" AND messages.conversation_id IN ? GROUP BY messages.conversation_id",
+		viewer, ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.ConversationID] = r.Unread
+	}
+	return out, nil
+}
+
+// UnreadCount counts a conversation's inbound messages the agent has not read
+func (cs *ConversationService) UnreadCount(conversationID, viewer uuid.UUID) (int, error) {
+	counts, err := cs.unreadCounts(viewer, []models.Conversation{{ID: conversationID}})
+	if err != nil {
+		return 0, err
+	}
+	return counts[conversationID], nil
+}
+
+// MarkRead moves the agent's read cursor in a conversation to the message upTo, or to
+// its latest message, and never back
+func (ms *MessageService) MarkRead(conversationID, userID uuid.UUID, upTo *uuid.UUID) (*models.ConversationRead, error) {
+	var conv models.Conversation
+	if err := ms.db.Select("id").First(&conv, "id = ?", conversationID).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	read := models.ConversationRead{ConversationID: conversationID, UserID: userID, LastReadAt: now}
+	var last models.Message
+	query := ms.db.Select("id, created_at").Where("conversation_id = ?", conversationID)
+	if upTo != nil {
+		query = query.Where("id = ?", *upTo)
+	}
+	switch err := query.Order("created_at desc").First(&last).Error; {
+	case err == nil:
+		read.LastReadAt, read.LastReadMessageID = last.CreatedAt, &last.ID
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	case upTo != nil:
+		return nil, ErrReadMessage
+	}
+
+	// the message id is set before last_read_at, so it compares against the old cursor
+	err := ms.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "conversation_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "last_read_message_id"}, Value: gorm.Expr("IF(VALUES(last_read_at) > last_read_at, VALUES(last_read_message_id), last_read_message_id)")},
+			{Column: clause.Column{Name: "last_read_at"}, Value: gorm.Expr("GREATEST(last_read_at, VALUES(last_read_at))")},
+			{Column: clause.Column{Name: "updated_at"}, Value: now},
+		},
+	}).Create(&read).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := ms.db.First(&read, "conversation_id = ? AND user_id = ?", conversationID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &read, nil
+}
+
+// SendReadReceipt shows the customer the inbound messages up to at as read. WhatsApp
+// marks a chat read up to a message, so one receipt for the newest unreceipted
+// message covers the ones before it. Group chats get no receipts. It returns how
+// many messages became read.
+func (ms *MessageService) SendReadReceipt(conversationID uuid.UUID, at time.Time) (int64, error) {
+	var conv models.Conversation
+	if err := ms.db.Select("id, is_group").First(&conv, "id = ?", conversationID).Error; err != nil {
+		return 0, err
+	}
+	if conv.IsGroup {
+		return 0, nil
+	}
+	pending := ms.db.Model(&models.Message{}).
+		Where("conversation_id = ? AND direction = ? AND imported = ? AND status <> ? AND created_at <= ?",
+			conversationID, models.MessageDirectionInbound, false, models.MessageStatusRead, at).
+		Session(&gorm.Session{})
+	var newest models.Message
+	if err := pending.Select("id, whatsapp_id").Where("whatsapp_id IS NOT NULL AND whatsapp_id <> ''").
+		Order("created_at desc").First(&newest).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if err := ms.wa.MarkAsRead(newest.WhatsAppID); err != nil {
+		return 0, fmt.Errorf("send read receipt: %w", err)
+	}
+	res := pending.Updates(map[string]interface{}{"status": models.MessageStatusRead, "read_at": time.Now()})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"sort"
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StampReply records an outbound message on the conversation before it is saved.
+// When the message answers a waiting customer, it gets the wait time in wall-clock
+// and business seconds, and the first such reply sets the conversation's response time.
+// Automated messages are not replies and only move last_message_at and last_activity_at.
+func (ms *MessageService) StampReply(conv *models.Conversation, msg *models.Message, at time.Time) {
+	conv.LastMessageAt = &at
+	conv.LastActivityAt = at
+	if msg.Automated {
+		return
+	}
+	if conv.FirstRespondedAt == nil {
+		// any agent message stops the first-response SLA clock
+		conv.FirstRespondedAt = &at
+	}
+
+	since, ok := ms.waitingSince(conv.ID)
+	if !ok {
+		return
+	}
+	wall := int(at.Sub(since).Seconds())
+	business := int(ms.hours.ForTeam(conv.TeamID).Between(since, at).Seconds())
+	msg.ResponseTime, msg.ResponseTimeBusiness = &wall, &business
+
+	var replies int64
+	ms.db.Model(&models.Message{}).Where("conversation_id = ? AND response_time IS NOT NULL", conv.ID).Count(&replies)
+	if replies == 0 {
+		conv.ResponseTime, conv.ResponseTimeBusiness = wall, business
+	}
+}
+
+// SaveReply writes the columns StampReply sets. The rest of the conversation was
+// loaded before the send and may have changed since, e.g. by a close or a reopen,
+// so it is not written back.
+func (ms *MessageService) SaveReply(conv *models.Conversation) error {
+	return ms.db.Model(conv).Select("last_message_at", "last_activity_at", "first_responded_at", "response_time", "response_time_business").Updates(conv).Error
+}
+
+// waitingSince returns when the oldest inbound message not yet followed by a reply arrived
+func (ms *MessageService) waitingSince(conversationID uuid.UUID) (time.Time, bool) {
+	inbound := ms.db.Where("conversation_id = ? AND direction = ?", conversationID, models.MessageDirectionInbound)
+	var lastReply models.Message
+	if err := ms.db.Select("created_at").
+		Where("conversation_id = ? AND direction = ? AND status <> ? AND automated = ?", conversationID, models.MessageDirectionOutbound, models.MessageStatusFailed, false).
+		Order("created_at desc").First(&lastReply).Error; err == nil {
+		inbound = inbound.Where("created_at > ?", lastReply.CreatedAt)
+	}
+	var first models.Message
+	if err := inbound.Select("created_at").Order("created_at asc").First(&first).Error; err != nil {
+		return time.Time{}, false
+	}
+	return first.CreatedAt, true
+}
+
+// resolutionTimes is how long a conversation took from start to close, in wall-clock and business seconds
+func resolutionTimes(hours *BusinessHours, created, closed time.Time) (int, int) {
+	return int(closed.Sub(created).Seconds()), int(hours.Between(created, closed).Seconds())
+}
+
+// DurationStats summarises a set of durations in seconds
+type DurationStats struct {
+	Count  int     `json:"count"`
+	Avg    float64 `json:"avg"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+}
+
+func durationStats(values []int) DurationStats {
+	if len(values) == 0 {
+		return DurationStats{}
+	}
+	sort.Ints(values)
+	var sum int
+	for _, v := range values {
+		sum += v
+	}
+	n := len(values)
+	median := float64(values[n/2])
+	if n%2 == 0 {
+		median = float64(values[n/2-1]+values[n/2]) / 2
+	}
+	return DurationStats{
+		Count:  n,
+		Avg:    float64(sum) / float64(n),
+		Median: median,
+		P90:    float64(values[(n*9-1)/10]),
+	}
+}
+
+// ResponseTimeReport holds first-response, per-reply and resolution statistics
+type ResponseTimeReport struct {
+	FirstResponse         DurationStats `json:"first_response"`
+	FirstResponseBusiness DurationStats `json:"first_response_business"`
+	Reply                 DurationStats `json:"reply"`
+	ReplyBusiness         DurationStats `json:"reply_business"`
+	Resolution            DurationStats `json:"resolution"`
+	ResolutionBusiness    DurationStats `json:"resolution_business"`
+}
+
+// ResponseTimes reports on conversations started in [from, to), optionally for one agent or team
+func (ms *MessageService) ResponseTimes(from, to *time.Time, agentID, teamID *uuid.UUID) (*ResponseTimeReport, error) {
+	convs := ms.db.Model(&models.Conversation{})
+	if from != nil {
+		convs = convs.Where("conversations.created_at >= ?", *from)
+	}
+	if to != nil {
+		convs = convs.Where("conversations.created_at < ?", *to)
+	}
+	if agentID != nil {
+		convs = convs.Where("conversations.agent_id = ?", *agentID)
+	}
+	if teamID != nil {
+		convs = convs.Where("conversations.team_id = ?", *teamID)
+	}
+	// reusable base for the queries below
+	convs = convs.Session(&gorm.Session{})
+
+	var first []struct{ ResponseTime, ResponseTimeBusiness int }
+	if err := convs.Select("response_time, response_time_business").
+		Where("first_responded_at IS NOT NULL AND EXISTS (SELECT 1 FROM messages WHERE messages.conversation_id = conversations.id AND messages.response_time IS NOT NULL)").
+		Scan(&first).Error; err != nil {
+		return nil, err
+	}
+	var resolved []struct{ ResolutionTime, ResolutionTimeBusiness int }
+	if err := convs.Select("resolution_time, resolution_time_business").
+		Where("status = ? AND closed_at IS NOT NULL", models.ConversationStatusClosed).
+		Scan(&resolved).Error; err != nil {
+		return nil, err
+	}
+	var replies []struct{ ResponseTime, ResponseTimeBusiness int }
+	if err := ms.db.Model(&models.Message{}).Select("messages.response_time, messages.response_time_business").
+		Where("messages.response_time IS NOT NULL AND messages.conversation_id IN (?)", convs.Select("conversations.id")).
+		Scan(&replies).Error; err != nil {
+		return nil, err
+	}
+
+	var firstWall, firstBiz, replyWall, replyBiz, resWall, resBiz []int
+	for _, r := range first {
+		firstWall, firstBiz = append(firstWall, r.ResponseTime), append(firstBiz, r.ResponseTimeBusiness)
+	}
+	for _, r := range replies {
+		replyWall, replyBiz = append(replyWall, r.ResponseTime), append(replyBiz, r.ResponseTimeBusiness)
+	}
+	for _, r := range resolved {
+		resWall, resBiz = append(resWall, r.ResolutionTime), append(resBiz, r.ResolutionTimeBusiness)
+	}
+	return &ResponseTimeReport{
+		FirstResponse:         durationStats(firstWall),
+		FirstResponseBusiness: durationStats(firstBiz),
+		Reply:                 durationStats(replyWall),
+		ReplyBusiness:         durationStats(replyBiz),
+		Resolution:            durationStats(resWall),
+		ResolutionBusiness:    durationStats(resBiz),
+	}, nil
+}
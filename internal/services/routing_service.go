@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RoutingStrategy string
+
+const (
+	RoutingRoundRobin  RoutingStrategy = "round_robin"
+	RoutingLeastActive RoutingStrategy = "least_active"
+	RoutingSticky      RoutingStrategy = "sticky"
+)
+
+// activeConversationStatuses count against an agent's concurrency cap
+var activeConversationStatuses = []models.ConversationStatus{models.ConversationStatusOpen, models.ConversationStatusAssigned, models.ConversationStatusPending}
+
+type routingCandidate struct {
+	ID     uuid.UUID
+	Active int64
+}
+
+// RoutingService picks an agent for new or reopened conversations among
+// available agents that are below their concurrency cap.
+type RoutingService struct {
+	db            *gorm.DB
+	rdb           *redis.Client
+	presence      *PresenceService
+	teams         *TeamService
+	enabled       bool
+	strategy      RoutingStrategy
+	maxConcurrent int
+
+	// serialises pick+assign so two conversations cannot both take an agent's last slot
+	mu sync.Mutex
+}
+
+func NewRoutingService(db *gorm.DB, rdb *redis.Client, presence *PresenceService, teams *TeamService, cfg *config.Config) *RoutingService {
+	return &RoutingService{
+		db:            db,
+		rdb:           rdb,
+		presence:      presence,
+		teams:         teams,
+		enabled:       cfg.RoutingEnabled,
+		strategy:      RoutingStrategy(cfg.RoutingStrategy),
+		maxConcurrent: cfg.RoutingMaxConcurrent,
+	}
+}
+
+// Enabled reports whether automatic routing is switched on
+func (rs *RoutingService) Enabled() bool { return rs != nil && rs.enabled }
+
+// Route picks an agent for conv and calls assign with it. It returns false
+// when no agent can take the conversation right now.
+func (rs *RoutingService) Route(ctx context.Context, conv *models.Conversation, assign func(agentID uuid.UUID) error) (bool, error) {
+	return rs.route(ctx, conv, nil, assign)
+}
+
+// RouteExcept is Route without considering the given agents, e.g. the one a conversation is taken away from
+func (rs *RoutingService) RouteExcept(ctx context.Context, conv *models.Conversation, exclude []uuid.UUID, assign func(agentID uuid.UUID) error) (bool, error) {
+	return rs.route(ctx, conv, exclude, assign)
+}
+
+func (rs *RoutingService) route(ctx context.Context, conv *models.Conversation, exclude []uuid.UUID, assign func(agentID uuid.UUID) error) (bool, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	agentID, err := rs.pick(ctx, conv, exclude)
+	if err != nil || agentID == nil {
+		return false, err
+	}
+	if err := assign(*agentID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (rs *RoutingService) pick(ctx context.Context, conv *models.Conversation, exclude []uuid.UUID) (*uuid.UUID, error) {
+	eligible, err := rs.candidates(ctx, conv.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	eligible = withoutCandidates(eligible, exclude)
+	if len(eligible) == 0 {
+		return nil, nil
+	}
+
+	switch rs.strategy {
+	case RoutingRoundRobin:
+		n, err := rs.rdb.Incr(ctx, "routing:round_robin").Result()
+		if err != nil {
+			return nil, err
+		}
+		return &eligible[int(n-1)%len(eligible)].ID, nil
+	case RoutingSticky:
+		if prev := rs.previousAgent(conv); prev != nil {
+			for _, c := range eligible {
+				if c.ID == *prev {
+					return &c.ID, nil
+				}
+			}
+		}
+	}
+
+	// least active (also the fallback when the sticky agent cannot take it)
+	best := eligible[0]
+	for _, c := range eligible[1:] {
+		if c.Active < best.Active {
+			best = c
+		}
+	}
+	return &best.ID, nil
+}
+
+// candidates returns available agents below their cap (members of teamID when set), in a stable order
+func (rs *RoutingService) candidates(ctx context.Context, teamID *uuid.UUID) ([]routingCandidate, error) {
+	ids, err := rs.presence.AvailableAgents(ctx)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	if teamID != nil {
+		members, err := rs.teams.MemberIDs(*teamID)
+		if err != nil {
+			return nil, err
+		}
+		ids = intersectIDs(ids, members)
+		if len(ids) == 0 {
+			return nil, nil
+		}
+	}
+
+	var agents []models.User
+	if err := rs.db.Select("id, max_concurrent").Where("id IN ?", ids).Find(&agents).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		AgentID uuid.UUID
+		Active  int64
+	}
+	if err := rs.db.Model(&models.Conversation{}).Select("agent_id, COUNT(*) AS active").
+		Where("agent_id IN ? AND status IN ?", ids, activeConversationStatuses).
+		Group("agent_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	active := map[uuid.UUID]int64{}
+	for _, c := range counts {
+		active[c.AgentID] = c.Active
+	}
+
+	var out []routingCandidate
+	for _, a := range agents {
+		limit := a.MaxConcurrent
+		if limit <= 0 {
+			limit = rs.maxConcurrent
+		}
+		if limit > 0 && active[a.ID] >= int64(limit) {
+			continue
+		}
+		out = append(out, routingCandidate{ID: a.ID, Active: active[a.ID]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID.String() < out[j].ID.String() })
+	return out, nil
+}
+
+// previousAgent is the agent who last handled another conversation with the same customer
+func (rs *RoutingService) previousAgent(conv *models.Conversation) *uuid.UUID {
+	var prev models.Conversation
+	err := rs.db.Where("customer_id = ? AND id <> ? AND agent_id IS NOT NULL", conv.CustomerID, conv.ID).
+		Order("created_at desc").First(&prev).Error
+	if err != nil {
+		return nil
+	}
+	return prev.AgentID
+}
+
+func withoutCandidates(in []routingCandidate, exclude []uuid.UUID) []routingCandidate {
+	if len(exclude) == 0 {
+		return in
+	}
+	out := in[:0]
+	for _, c := range in {
+		skip := false
+		for _, id := range exclude {
+			if c.ID == id {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func intersectIDs(a, b []uuid.UUID) []uuid.UUID {
+	in := make(map[uuid.UUID]bool, len(b))
+	for _, id := range b {
+		in[id] = true
+	}
+	var out []uuid.UUID
+	for _, id := range a {
+		if in[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
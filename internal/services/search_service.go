@@ -0,0 +1,453 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrSearchQuery is returned when a query has nothing the FULLTEXT index can match
+var ErrSearchQuery = errors.New("search needs a word of at least 3 letters or digits")
+
+// MATCH column lists must be exactly those of the FULLTEXT indexes on the models
+const (
+	messageMatch  = "MATCH(messages.content, messages.file_name, messages.caption) AGAINST (? IN BOOLEAN MODE)"
+	customerMatch = "MATCH(customers.name, customers.email, customers.phone, customers.notes) AGAINST (? IN BOOLEAN MODE)"
+	noteMatch     = "MATCH(conversation_notes.body) AGAINST (? IN BOOLEAN MODE)"
+)
+
+const (
+	// minSearchTerm matches InnoDB's default innodb_ft_min_token_size
+	minSearchTerm = 3
+	// snippetRadius is how many characters of context surround the first match
+	snippetRadius = 60
+	// transcriptPage is the default page size of GET /messages/conversation/:id
+	transcriptPage = 50
+)
+
+// SearchFilter narrows a search. Agent applies to messages, notes and customers;
+// dates apply to messages and notes, type and direction to messages.
+type SearchFilter struct {
+	Query     string
+	AgentID   *uuid.UUID
+	From      *time.Time
+	To        *time.Time
+	Type      models.MessageType
+	Direction models.MessageDirection
+	Offset    int
+	Limit     int
+}
+
+// MessageHit is one matching message with a highlighted snippet and where it sits in
+// its conversation
+type MessageHit struct {
+	MessageID      uuid.UUID               `json:"message_id"`
+	ConversationID uuid.UUID               `json:"conversation_id"`
+	CustomerID     uuid.UUID               `json:"customer_id"`
+	CustomerName   string                  `json:"customer_name"`
+	Type           models.MessageType      `json:"type"`
+	Direction      models.MessageDirection `json:"direction"`
+	CreatedAt      time.Time               `json:"created_at"`
+	Score          float64                 `json:"score"`
+	Snippet        string                  `json:"snippet"`
+	Position       int64                   `json:"position"`
+	Link           string                  `json:"link"`
+}
+
+// NoteHit is one matching internal note with a highlighted snippet
+type NoteHit struct {
+	NoteID         uuid.UUID `json:"note_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	CustomerID     uuid.UUID `json:"customer_id"`
+	CustomerName   string    `json:"customer_name"`
+	AuthorID       uuid.UUID `json:"author_id"`
+	AuthorName     string    `json:"author_name"`
+	CreatedAt      time.Time `json:"created_at"`
+	Score          float64   `json:"score"`
+	Snippet        string    `json:"snippet"`
+	Link           string    `json:"link"`
+}
+
+// CustomerHit is one matching customer, the field that matched, and their latest conversation
+type CustomerHit struct {
+	CustomerID     uuid.UUID  `json:"customer_id"`
+	Name           string     `json:"name"`
+	Phone          string     `json:"phone"`
+	Email          string     `json:"email"`
+	Score          float64    `json:"score"`
+	Field          string     `json:"field"`
+	Snippet        string     `json:"snippet"`
+	ConversationID *uuid.UUID `json:"conversation_id"`
+	Link           string     `json:"link"`
+}
+
+// SearchService runs relevance-ranked FULLTEXT searches over messages, internal notes and customers
+type SearchService struct {
+	db *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{db: db}
+}
+
+// searchTerms splits a query into lower-case words long enough to be indexed
+func searchTerms(q string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(w) >= minSearchTerm && !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// booleanQuery requires every term, each as a prefix
+func booleanQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = "+" + t + "*"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Messages returns messages matching the query, most relevant first
+func (ss *SearchService) Messages(f SearchFilter) ([]MessageHit, int64, error) {
+	terms := searchTerms(f.Query)
+	if len(terms) == 0 {
+		return nil, 0, ErrSearchQuery
+	}
+	against := booleanQuery(terms)
+	query := ss.db.Table("messages").
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id").
+		Joins("JOIN customers ON customers.id = conversations.customer_id AND customers.deleted_at IS NULL").
+		Where(messageMatch, against)
+	if f.AgentID != nil {
+		query = query.Where("conversations.agent_id = ?", *f.AgentID)
+	}
+	if f.From != nil {
+		query = query.Where("messages.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("messages.created_at < ?", *f.To)
+	}
+	if f.Type != "" {
+		query = query.Where("messages.type = ?", f.Type)
+	}
+	if f.Direction != "" {
+		query = query.Where("messages.direction = ?", f.Direction)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []struct {
+		ID             uuid.UUID
+		ConversationID uuid.UUID
+		CustomerID     uuid.UUID
+		CustomerName   string
+		Type           models.MessageType
+		Direction      models.MessageDirection
+		Content        string
+		Caption        string
+		FileName       string
+		CreatedAt      time.Time
+		Score          float64
+	}
+	err := query.Select("messages.id, messages.conversation_id, customers.id AS customer_id, customers.name AS customer_name, "+
+		"messages.type, messages.direction, messages.content, messages.caption, messages.file_name, messages.created_at, "+
+		messageMatch+" AS score", against).
+		Order("score desc, messages.created_at desc").Offset(f.Offset).Limit(f.Limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+	positions, err := ss.positions(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	hits := make([]MessageHit, 0, len(rows))
+	for _, r := range rows {
+		_, snippet := highlight(terms, [][2]string{{"content", r.Content}, {"caption", r.Caption}, {"file_name", r.FileName}})
+		position := positions[r.ID]
+		hits = append(hits, MessageHit{
+			MessageID:      r.ID,
+			ConversationID: r.ConversationID,
+			CustomerID:     r.CustomerID,
+			CustomerName:   r.CustomerName,
+			Type:           r.Type,
+			Direction:      r.Direction,
+			CreatedAt:      r.CreatedAt,
+			Score:          r.Score,
+			Snippet:        snippet,
+			Position:       position,
+			Link:           fmt.Sprintf("/api/v1/messages/conversation/%s?page=%d&limit=%d#%s", r.ConversationID, (position-1)/transcriptPage+1, transcriptPage, r.ID),
+		})
+	}
+	return hits, total, nil
+}
+
+// positions gives each message's 1-based place in its conversation's transcript
+func (ss *SearchService) positions(ids []uuid.UUID) (map[uuid.UUID]int64, error) {
+	out := make(map[uuid.UUID]int64, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		ID       uuid.UUID
+		Position int64
+	}
+	err := ss.db.Table("messages AS hit").
+		Select("hit.id, COUNT(*) AS position").
+		Joins("JOIN messages ON messages.conversation_id = hit.conversation_id AND "+
+			"(messages.created_at < hit.created_at OR (messages.created_at = hit.created_at AND messages.id <= hit.id))").
+		Where("hit.id IN ?", ids).
+		Group("hit.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.ID] = r.Position
+	}
+	return out, nil
+}
+
+// Notes returns internal notes matching the query, most relevant first
+func (ss *SearchService) Notes(f SearchFilter) ([]NoteHit, int64, error) {
+	terms := searchTerms(f.Query)
+	if len(terms) == 0 {
+		return nil, 0, ErrSearchQuery
+	}
+	against := booleanQuery(terms)
+	query := ss.db.Table("conversation_notes").
+		Joins("JOIN conversations ON conversations.id = conversation_notes.conversation_id").
+		Joins("JOIN customers ON customers.id = conversations.customer_id AND customers.deleted_at IS NULL").
+		Joins("LEFT JOIN users ON users.id = conversation_notes.author_id").
+		Where("conversation_notes.deleted_at IS NULL").
+		Where(noteMatch, against)
+	if f.AgentID != nil {
+		query = query.Where("conversations.agent_id = ?", *f.AgentID)
+	}
+	if f.From != nil {
+		query = query.Where("conversation_notes.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("conversation_notes.created_at < ?", *f.To)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []struct {
+		ID             uuid.UUID
+		ConversationID uuid.UUID
+		CustomerID     uuid.UUID
+		CustomerName   string
+		AuthorID       uuid.UUID
+		AuthorName     string
+		Body           string
+		CreatedAt      time.Time
+		Score          float64
+	}
+	err := query.Select("conversation_notes.id, conversation_notes.conversation_id, customers.id AS customer_id, customers.name AS customer_name, "+
+		"conversation_notes.author_id, users.name AS author_name, conversation_notes.body, conversation_notes.created_at, "+
+		noteMatch+" AS score", against).
+		Order("score desc, conversation_notes.created_at desc").Offset(f.Offset).Limit(f.Limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]NoteHit, 0, len(rows))
+	for _, r := range rows {
+		_, snippet := highlight(terms, [][2]string{{"body", r.Body}})
+		hits = append(hits, NoteHit{
+			NoteID:         r.ID,
+			ConversationID: r.ConversationID,
+			CustomerID:     r.CustomerID,
+			CustomerName:   r.CustomerName,
+			AuthorID:       r.AuthorID,
+			AuthorName:     r.AuthorName,
+			CreatedAt:      r.CreatedAt,
+			Score:          r.Score,
+			Snippet:        snippet,
+			Link:           "/api/v1/notes/" + r.ID.String(),
+		})
+	}
+	return hits, total, nil
+}
+
+// Customers returns customers whose name, phone, email or notes match, most relevant
+// first. Queries of digits also match inside phone numbers.
+func (ss *SearchService) Customers(f SearchFilter) ([]CustomerHit, int64, error) {
+	terms := searchTerms(f.Query)
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, f.Query)
+	if len(digits) < 4 || strings.IndexFunc(f.Query, unicode.IsLetter) >= 0 {
+		digits = ""
+	}
+	if len(terms) == 0 && digits == "" {
+		return nil, 0, ErrSearchQuery
+	}
+	against := booleanQuery(terms)
+	query := ss.db.Table("customers").Where("customers.deleted_at IS NULL")
+	switch {
+	case len(terms) > 0 && digits != "":
+		query = query.Where(customerMatch+" OR customers.phone LIKE ?", against, "%"+digits+"%")
+	case digits != "":
+		query = query.Where("customers.phone LIKE ?", "%"+digits+"%")
+	default:
+		query = query.Where(customerMatch, against)
+	}
+	if f.AgentID != nil {
+		query = query.Where("EXISTS (SELECT 1 FROM conversations WHERE conversations.customer_id = customers.id AND conversations.agent_id = ?)", *f.AgentID)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	score, args := "0", []interface{}{}
+	if len(terms) > 0 {
+		score, args = customerMatch, []interface{}{against}
+	}
+	var rows []struct {
+		ID             uuid.UUID
+		Name           string
+		Phone          string
+		Email          string
+		Notes          string
+		Score          float64
+		ConversationID *uuid.UUID
+	}
+	err := query.Select("customers.id, customers.name, customers.phone, customers.email, customers.notes, "+score+" AS score, "+
+		"(SELECT c.id FROM conversations c WHERE c.customer_id = customers.id ORDER BY c.created_at DESC LIMIT 1) AS conversation_id", args...).
+		Order("score desc, customers.name asc").Offset(f.Offset).Limit(f.Limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if digits != "" {
+		terms = append(terms, digits)
+	}
+	hits := make([]CustomerHit, 0, len(rows))
+	for _, r := range rows {
+		field, snippet := highlight(terms, [][2]string{{"name", r.Name}, {"phone", r.Phone}, {"email", r.Email}, {"notes", r.Notes}})
+		hit := CustomerHit{CustomerID: r.ID, Name: r.Name, Phone: r.Phone, Email: r.Email, Score: r.Score, Field: field, Snippet: snippet, ConversationID: r.ConversationID}
+		hit.Link = "/api/v1/customers/" + r.ID.String()
+		if r.ConversationID != nil {
+			hit.Link = "/api/v1/conversations/" + r.ConversationID.String()
+		}
+		hits = append(hits, hit)
+	}
+	return hits, total, nil
+}
+
+// highlight finds the first field containing a term and returns its name with an
+// HTML-escaped snippet around the match, every term wrapped in <mark>
+func highlight(terms []string, fields [][2]string) (string, string) {
+	for _, f := range fields {
+		text := f[1]
+		lower := lowerSameLength(text)
+		at := -1
+		for _, t := range terms {
+			if i := strings.Index(lower, t); i >= 0 && (at < 0 || i < at) {
+				at = i
+			}
+		}
+		if at < 0 {
+			continue
+		}
+		start, end := at-snippetRadius, at+snippetRadius
+		if start < 0 {
+			start = 0
+		}
+		if end > len(text) {
+			end = len(text)
+		}
+		// keep whole UTF-8 characters
+		for start > 0 && !utf8.RuneStart(text[start]) {
+			start--
+		}
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end++
+		}
+		snippet := markTerms(text[start:end], terms)
+		if start > 0 {
+			snippet = "…" + snippet
+		}
+		if end < len(text) {
+			snippet += "…"
+		}
+		return f[0], snippet
+	}
+	// matched through stemming or a prefix the text does not show plainly
+	for _, f := range fields {
+		if f[1] != "" {
+			text := []rune(f[1])
+			if len(text) > 2*snippetRadius {
+				return f[0], html.EscapeString(string(text[:2*snippetRadius])) + "…"
+			}
+			return f[0], html.EscapeString(f[1])
+		}
+	}
+	return "", ""
+}
+
+// markTerms escapes text and wraps each case-insensitive occurrence of a term in <mark>
+func markTerms(text string, terms []string) string {
+	lower := lowerSameLength(text)
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		matched := 0
+		for _, t := range terms {
+			if strings.HasPrefix(lower[i:], t) && len(t) > matched {
+				matched = len(t)
+			}
+		}
+		if matched > 0 {
+			b.WriteString("<mark>" + html.EscapeString(text[i:i+matched]) + "</mark>")
+			i += matched
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		b.WriteString(html.EscapeString(text[i : i+size]))
+		i += size
+	}
+	return b.String()
+}
+
+// lowerSameLength lower-cases text unless that would shift byte offsets
+func lowerSameLength(text string) string {
+	if lower := strings.ToLower(text); len(lower) == len(text) {
+		return lower
+	}
+	return text
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SLABreach is handed to breach listeners so they can escalate the conversation
+type SLABreach struct {
+	Conversation models.Conversation
+	Policy       models.SLAPolicy
+	Target       models.SLATarget
+}
+
+// SLAService stamps conversations with due dates from the matching SLA policy
+// and periodically flags targets that are approaching or missed.
+type SLAService struct {
+	db       *gorm.DB
+	events   *EventDispatcher
+	hours    *BusinessHoursService
+	interval time.Duration
+
+	mu        sync.RWMutex
+	listeners []func(SLABreach)
+}
+
+func NewSLAService(db *gorm.DB, events *EventDispatcher, hours *BusinessHoursService, cfg *config.Config) *SLAService {
+	interval := time.Duration(cfg.SLACheckIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &SLAService{db: db, events: events, hours: hours, interval: interval}
+}
+
+// OnBreach registers fn to be called once for every missed target
+func (ss *SLAService) OnBreach(fn func(SLABreach)) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.listeners = append(ss.listeners, fn)
+}
+
+// PolicyFor returns the active policy for a priority, preferring one scoped to the team
+func (ss *SLAService) PolicyFor(priority models.ConversationPriority, teamID *uuid.UUID) (*models.SLAPolicy, error) {
+	query := ss.db.Where("priority = ? AND is_active = ?", priority, true)
+	if teamID != nil {
+		query = query.Where("team_id = ? OR team_id IS NULL", *teamID).Order("team_id IS NULL")
+	} else {
+		query = query.Where("team_id IS NULL")
+	}
+	var policy models.SLAPolicy
+	err := query.Order("created_at asc").First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Apply stamps the conversation's due dates from its current priority and team.
+// Targets are counted in the team's business time from when the conversation
+// started, or from its latest reopen.
+func (ss *SLAService) Apply(conv *models.Conversation) error {
+	if ss == nil {
+		return nil
+	}
+	policy, err := ss.PolicyFor(conv.Priority, conv.TeamID)
+	if err != nil {
+		return err
+	}
+
+	start := conv.CreatedAt
+	if conv.ReopenedAt != nil {
+		start = *conv.ReopenedAt
+	}
+	conv.SLAPolicyID, conv.FirstResponseDue, conv.ResolutionDue = nil, nil, nil
+	if policy != nil {
+		hours := ss.hours.ForTeam(conv.TeamID)
+		firstDue := hours.Add(start, time.Duration(policy.FirstResponseMinutes)*time.Minute)
+		resolutionDue := hours.Add(start, time.Duration(policy.ResolutionMinutes)*time.Minute)
+		conv.SLAPolicyID, conv.FirstResponseDue, conv.ResolutionDue = &policy.ID, &firstDue, &resolutionDue
+	}
+	return ss.db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Updates(map[string]interface{}{
+		"sla_policy_id":      conv.SLAPolicyID,
+		"first_response_due": conv.FirstResponseDue,
+		"resolution_due":     conv.ResolutionDue,
+	}).Error
+}
+
+// Run checks open conversations against their targets until ctx is cancelled
+func (ss *SLAService) Run(ctx context.Context) {
+	ticker := time.NewTicker(ss.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := ss.Check(now); err != nil {
+				log.Printf("sla check: %v", err)
+			}
+		}
+	}
+}
+
+// Check flags every target that is within its warning window or already missed
+func (ss *SLAService) Check(now time.Time) error {
+	var policies []models.SLAPolicy
+	if err := ss.db.Find(&policies).Error; err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	byID := make(map[uuid.UUID]models.SLAPolicy, len(policies))
+	maxWarn := 0
+	for _, p := range policies {
+		byID[p.ID] = p
+		if p.WarnBeforeMinutes > maxWarn {
+			maxWarn = p.WarnBeforeMinutes
+		}
+	}
+
+	horizon := now.Add(time.Duration(maxWarn) * time.Minute)
+	var convs []models.Conversation
+	if err := ss.db.Where("status <> ? AND sla_policy_id IS NOT NULL", models.ConversationStatusClosed).
+		Where("(first_responded_at IS NULL AND first_response_due <= ?) OR resolution_due <= ?", horizon, horizon).
+		Find(&convs).Error; err != nil {
+		return err
+	}
+
+	for _, conv := range convs {
+		policy, ok := byID[*conv.SLAPolicyID]
+		if !ok {
+			continue
+		}
+		if conv.FirstRespondedAt == nil && conv.FirstResponseDue != nil {
+			ss.evaluate(conv, policy, models.SLATargetFirstResponse, *conv.FirstResponseDue, now)
+		}
+		if conv.ResolutionDue != nil {
+			ss.evaluate(conv, policy, models.SLATargetResolution, *conv.ResolutionDue, now)
+		}
+	}
+	return nil
+}
+
+func (ss *SLAService) evaluate(conv models.Conversation, policy models.SLAPolicy, target models.SLATarget, due, now time.Time) {
+	kind := models.SLAEventWarning
+	switch {
+	case !now.Before(due):
+		kind = models.SLAEventBreach
+	case now.Before(due.Add(-time.Duration(policy.WarnBeforeMinutes) * time.Minute)):
+		return
+	}
+
+	ev := models.SLAEvent{
+		ConversationID: conv.ID,
+		PolicyID:       policy.ID,
+		Target:         target,
+		Kind:           kind,
+		DueAt:          due,
+		Priority:       conv.Priority,
+		TeamID:         conv.TeamID,
+		AgentID:        conv.AgentID,
+		Action:         models.SLAActionNone,
+	}
+	if kind == models.SLAEventBreach {
+		ev.Action = policy.BreachAction
+	}
+	res := ss.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ev)
+	if res.Error != nil {
+		log.Printf("sla %s for conversation %s: %v", kind, conv.ID, res.Error)
+		return
+	}
+	if res.RowsAffected == 0 {
+		// this deadline was already flagged
+		return
+	}
+	ev.Conversation = &conv
+	ev.Policy = &policy
+
+	if kind == models.SLAEventWarning {
+		ss.events.Publish(models.EventSLAWarning, ev)
+		return
+	}
+
+	ss.db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Update("sla_breached", true)
+	recordEvent(ss.db, conv.ID, models.ConversationEventSLABreached, SystemActor, "", string(target), "action: "+string(ev.Action))
+	ss.events.Publish(models.EventSLABreached, ev)
+
+	ss.mu.RLock()
+	listeners := append([]func(SLABreach){}, ss.listeners...)
+	ss.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(SLABreach{Conversation: conv, Policy: policy, Target: target})
+	}
+}
+
+// SLABreachSummary counts missed targets per target, priority and team
+type SLABreachSummary struct {
+	Target   models.SLATarget            `json:"target"`
+	Priority models.ConversationPriority `json:"priority"`
+	TeamID   *uuid.UUID                  `json:"team_id"`
+	Breaches int64                       `json:"breaches"`
+}
+
+// BreachReport summarises breaches flagged in [from, to), optionally for one team
+func (ss *SLAService) BreachReport(from, to *time.Time, teamID *uuid.UUID) ([]SLABreachSummary, error) {
+	var summary []SLABreachSummary
+	err := ss.breaches(from, to, teamID).
+		Select("target, priority, team_id, COUNT(*) AS breaches").
+		Group("target, priority, team_id").
+		Order("breaches desc").
+		Scan(&summary).Error
+	return summary, err
+}
+
+// Breaches lists breaches flagged in [from, to), newest first
+func (ss *SLAService) Breaches(from, to *time.Time, teamID *uuid.UUID, offset, limit int) ([]models.SLAEvent, int64, error) {
+	var total int64
+	if err := ss.breaches(from, to, teamID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []models.SLAEvent
+	err := ss.breaches(from, to, teamID).
+		Preload("Conversation.Customer").Preload("Conversation.Agent").Preload("Policy").
+		Order("created_at desc").Offset(offset).Limit(limit).
+		Find(&events).Error
+	return events, total, err
+}
+
+func (ss *SLAService) breaches(from, to *time.Time, teamID *uuid.UUID) *gorm.DB {
+	query := ss.db.Model(&models.SLAEvent{}).Where("kind = ?", models.SLAEventBreach)
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+	if teamID != nil {
+		query = query.Where("team_id = ?", *teamID)
+	}
+	return query
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTagName  = errors.New("tag name is required")
+	ErrTagColor = errors.New("color must look like #1a2b3c")
+	ErrTagScope = errors.New("tag scope does not allow this record")
+	ErrTagTaken = errors.New("a tag with that name already exists")
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// TagService manages tags and their links to customers
+type TagService struct {
+	db *gorm.DB
+}
+
+func NewTagService(db *gorm.DB) *TagService {
+	return &TagService{db: db}
+}
+
+// List returns tags by name, limited to those usable on scope when it is set
+func (ts *TagService) List(scope models.TagScope) ([]models.Tag, error) {
+	query := ts.db.Order("name asc")
+	if scope != "" {
+		query = query.Where("scope IN ?", []models.TagScope{models.TagScopeAll, scope})
+	}
+	var tags []models.Tag
+	err := query.Find(&tags).Error
+	return tags, err
+}
+
+// Save validates and creates or updates a tag
+func (ts *TagService) Save(tag *models.Tag) error {
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" || strings.Contains(tag.Name, ",") {
+		return ErrTagName
+	}
+	if tag.Color != "" && !tagColorPattern.MatchString(tag.Color) {
+		return ErrTagColor
+	}
+	if tag.Scope == "" {
+		tag.Scope = models.TagScopeAll
+	}
+	if !validTagScope(tag.Scope) {
+		return ErrTagScope
+	}
+	var taken int64
+	ts.db.Model(&models.Tag{}).Where("name = ? AND id <> ?", tag.Name, tag.ID).Count(&taken)
+	if taken > 0 {
+		return ErrTagTaken
+	}
+	return ts.db.Save(tag).Error
+}
+
+func validTagScope(s models.TagScope) bool {
+	return s == models.TagScopeAll || s == models.TagScopeCustomer || s == models.TagScopeConversation
+}
+
+// Delete removes a tag and unlinks it everywhere
+func (ts *TagService) Delete(id uuid.UUID) error {
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM customer_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM conversation_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&models.Tag{}, "id = ?", id)
+		if res.Error == nil && res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return res.Error
+	})
+}
+
+// AddToCustomer tags a customer; adding a tag it already has is a no-op
+func (ts *TagService) AddToCustomer(customerID, tagID uuid.UUID) error {
+	tag, err := tagFor(ts.db, tagID, models.TagScopeCustomer)
+	if err != nil {
+		return err
+	}
+	customer := models.Customer{ID: customerID}
+	if err := ts.db.Select("id").First(&customer, "id = ?", customerID).Error; err != nil {
+		return err
+	}
+	return ts.db.Model(&customer).Association("Tags").Append(tag)
+}
+
+// RemoveFromCustomer untags a customer
+func (ts *TagService) RemoveFromCustomer(customerID, tagID uuid.UUID) error {
+	return ts.db.Model(&models.Customer{ID: customerID}).Association("Tags").Delete(&models.Tag{ID: tagID})
+}
+
+// tagFor loads a tag and checks it may be used on records of scope
+func tagFor(db *gorm.DB, tagID uuid.UUID, scope models.TagScope) (*models.Tag, error) {
+	var tag models.Tag
+	if err := db.First(&tag, "id = ?", tagID).Error; err != nil {
+		return nil, err
+	}
+	if !tag.Allows(scope) {
+		return nil, ErrTagScope
+	}
+	return &tag, nil
+}
+
+// tagFilter matches records linked to a tag given by id or by name
+func tagFilter(joinTable, foreignKey, parentColumn, tag string) (string, []interface{}) {
+	sql := "EXISTS (SELECT 1 FROM " + joinTable + " jt JOIN tags t ON t.id = jt.tag_id WHERE jt." + foreignKey + " = " + parentColumn
+	if id, err := uuid.Parse(tag); err == nil {
+		return sql + " AND t.id = ?)", []interface{}{id}
+	}
+	return sql + " AND t.name = ?)", []interface{}{tag}
+}
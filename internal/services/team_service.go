@@ -0,0 +1,134 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"whatsapp-crm/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RoutingHints is what an inbound message tells us about where a conversation belongs
+type RoutingHints struct {
+	Text        string
+	EntryNumber string
+	MenuChoice  string
+}
+
+type TeamService struct {
+	db *gorm.DB
+}
+
+func NewTeamService(db *gorm.DB) *TeamService {
+	return &TeamService{db: db}
+}
+
+// MemberIDs returns the user IDs belonging to a team
+func (ts *TeamService) MemberIDs(teamID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := ts.db.Table("team_members").Where("team_id = ?", teamID).Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// IsMember reports whether the user belongs to the team
+func (ts *TeamService) IsMember(teamID, userID uuid.UUID) bool {
+	var n int64
+	ts.db.Table("team_members").Where("team_id = ? AND user_id = ?", teamID, userID).Count(&n)
+	return n > 0
+}
+
+// AddMember puts a user in a team; adding an existing member is a no-op
+func (ts *TeamService) AddMember(teamID, userID uuid.UUID) error {
+	if ts.IsMember(teamID, userID) {
+		return nil
+	}
+	return ts.db.Model(&models.Team{ID: teamID}).Association("Members").Append(&models.User{ID: userID})
+}
+
+// RemoveMember takes a user out of a team
+func (ts *TeamService) RemoveMember(teamID, userID uuid.UUID) error {
+	return ts.db.Model(&models.Team{ID: teamID}).Association("Members").Delete(&models.User{ID: userID})
+}
+
+// Match returns the team of the first active rule that matches the conversation, if any
+func (ts *TeamService) Match(conv *models.Conversation, hints RoutingHints) (*models.Team, error) {
+	var rules []models.RoutingRule
+	if err := ts.db.Preload("Team").
+		Joins("JOIN teams ON teams.id = routing_rules.team_id AND teams.is_active = ?", true).
+		Where("routing_rules.is_active = ?", true).
+		Order("routing_rules.position asc, routing_rules.created_at asc").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var customerTags []string
+	ts.db.Table("tags").Joins("JOIN customer_tags ON customer_tags.tag_id = tags.id").
+		Where("customer_tags.customer_id = ?", conv.CustomerID).Pluck("tags.name", &customerTags)
+
+	for _, rule := range rules {
+		if ruleMatches(rule, hints, customerTags) {
+			team := rule.Team
+			return &team, nil
+		}
+	}
+	return nil, nil
+}
+
+func ruleMatches(rule models.RoutingRule, hints RoutingHints, customerTags []string) bool {
+	for _, p := range splitList(rule.Pattern) {
+		switch rule.Type {
+		case models.RuleKeyword:
+			if hints.Text != "" && containsWord(hints.Text, p) {
+				return true
+			}
+		case models.RuleCustomerTag:
+			for _, tag := range customerTags {
+				if strings.EqualFold(tag, p) {
+					return true
+				}
+			}
+		case models.RuleEntryNumber:
+			if hints.EntryNumber != "" && digitsOnly(hints.EntryNumber) == digitsOnly(p) {
+				return true
+			}
+		case models.RuleMenuChoice:
+			if hints.MenuChoice != "" && strings.EqualFold(hints.MenuChoice, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsWord does a case-insensitive whole-word (or whole-phrase) match
+func containsWord(text, word string) bool {
+	re, err := regexp.Compile(`(?i)\b` + regexp.QuoteMeta(word) + `\b`)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(text)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/storage"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrExportEmpty    = errors.New("no conversations match the export")
+	ErrExportTooLarge = errors.New("too many conversations for one export")
+	ErrExportScope    = errors.New("conversation_ids or a from/to range is required")
+	ErrExportNotReady = errors.New("export is not completed")
+)
+
+// BulkExportRequest selects conversations either by id or by when they started
+type BulkExportRequest struct {
+	ConversationIDs []uuid.UUID
+	From            *time.Time
+	To              *time.Time
+	TeamID          *uuid.UUID
+	Format          string
+	IncludeNotes    bool
+}
+
+// QueueExport records a bulk export job for the worker
+func (ts *TranscriptService) QueueExport(req BulkExportRequest, userID uuid.UUID) (*models.ExportJob, error) {
+	if _, ok := ExportFormats[req.Format]; !ok {
+		return nil, ErrExportFormat
+	}
+	if len(req.ConversationIDs) == 0 && (req.From == nil || req.To == nil) {
+		return nil, ErrExportScope
+	}
+	query := ts.db.Model(&models.Conversation{})
+	if len(req.ConversationIDs) > 0 {
+		query = query.Where("id IN ?", req.ConversationIDs)
+	} else {
+		query = query.Where("created_at >= ? AND created_at < ?", *req.From, *req.To)
+	}
+	if req.TeamID != nil {
+		query = query.Where("team_id = ?", *req.TeamID)
+	}
+	var ids []uuid.UUID
+	if err := query.Order("created_at asc").Limit(ts.maxBulk+1).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrExportEmpty
+	}
+	if len(ids) > ts.maxBulk {
+		return nil, ErrExportTooLarge
+	}
+	raw, _ := json.Marshal(ids)
+	job := models.ExportJob{RequestedBy: userID, Format: req.Format, IncludeNotes: req.IncludeNotes, ConversationIDs: string(raw), Total: len(ids)}
+	if err := ts.db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	select {
+	case ts.wake <- struct{}{}:
+	default:
+	}
+	return &job, nil
+}
+
+// Jobs lists a user's exports, or everyone's, newest first
+func (ts *TranscriptService) Jobs(userID *uuid.UUID) ([]models.ExportJob, error) {
+	var jobs []models.ExportJob
+	query := ts.db.Order("created_at desc").Limit(100)
+	if userID != nil {
+		query = query.Where("requested_by = ?", *userID)
+	}
+	return jobs, query.Find(&jobs).Error
+}
+
+// Job returns one export
+func (ts *TranscriptService) Job(id uuid.UUID) (*models.ExportJob, error) {
+	var job models.ExportJob
+	if err := ts.db.First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// DownloadURL signs a link to a completed export's zip
+func (ts *TranscriptService) DownloadURL(ctx context.Context, job *models.ExportJob) (string, error) {
+	if job.Status != models.ExportJobCompleted {
+		return "", ErrExportNotReady
+	}
+	return ts.store.SignedURL(ctx, job.FilePath, ts.linkExpiry)
+}
+
+// Run works through queued exports one at a time. Jobs left running by a restart
+// are picked up again.
+func (ts *TranscriptService) Run(ctx context.Context) {
+	ts.db.Model(&models.ExportJob{}).Where("status = ?", models.ExportJobRunning).
+		Updates(map[string]interface{}{"status": models.ExportJobPending, "processed": 0})
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		for ts.next(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ts.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// next claims and runs the oldest pending job; false when there was none
+func (ts *TranscriptService) next(ctx context.Context) bool {
+	var job models.ExportJob
+	if err := ts.db.Where("status = ?", models.ExportJobPending).Order("created_at asc").First(&job).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("export: %v", err)
+		}
+		return false
+	}
+	now := time.Now()
+	claim := ts.db.Model(&models.ExportJob{}).Where("id = ? AND status = ?", job.ID, models.ExportJobPending).
+		Updates(map[string]interface{}{"status": models.ExportJobRunning, "started_at": now})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return claim.Error == nil
+	}
+
+	path, err := ts.export(ctx, &job)
+	done := time.Now()
+	if err != nil {
+		log.Printf("export %s: %v", job.ID, err)
+		ts.db.Model(&job).Updates(map[string]interface{}{"status": models.ExportJobFailed, "error": err.Error(), "completed_at": done})
+		return true
+	}
+	ts.db.Model(&job).Updates(map[string]interface{}{"status": models.ExportJobCompleted, "file_path": path, "completed_at": done})
+	return true
+}
+
+// export writes every transcript of a job into a zip and saves it to storage
+func (ts *TranscriptService) export(ctx context.Context, job *models.ExportJob) (string, error) {
+	var ids []uuid.UUID
+	if err := json.Unmarshal([]byte(job.ConversationIDs), &ids); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+	var missing []string
+	for i, id := range ids {
+		t, err := ts.Build(ctx, id, job.IncludeNotes)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			missing = append(missing, id.String())
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("%s_%s.%s", t.CreatedAt.Format("2006-01-02"), id, job.Format)
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: t.ExportedAt})
+		if err != nil {
+			return "", err
+		}
+		if err := ts.Render(w, t, job.Format); err != nil {
+			return "", err
+		}
+		ts.db.Model(job).Update("processed", i+1)
+	}
+	if len(missing) > 0 {
+		w, err := zw.Create("missing.txt")
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(w, "Conversations deleted before the export ran:\n")
+		for _, id := range missing {
+			fmt.Fprintln(w, id)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, 0); err != nil {
+		return "", err
+	}
+	objectPath := storage.Join("whatsapp-crm", "exports", job.CreatedAt.Format("2006/01/02"), job.ID.String()+".zip")
+	return ts.store.Save(ctx, tmp, objectPath, "application/zip")
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"whatsapp-crm/internal/config"
+	"whatsapp-crm/internal/models"
+	"whatsapp-crm/internal/storage"
+	"whatsapp-crm/pkg/pdf"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrExportFormat is returned for a format other than txt, html, json or pdf
+var ErrExportFormat = errors.New("format must be txt, html, json or pdf")
+
+// ExportFormats maps each transcript format to its content type
+var ExportFormats = map[string]string{
+	"txt":  "text/plain; charset=utf-8",
+	"html": "text/html; charset=utf-8",
+	"json": "application/json",
+	"pdf":  "application/pdf",
+}
+
+const transcriptTime = "2006-01-02 15:04:05 -07:00"
+
+// Transcript is a conversation laid out for export
+type Transcript struct {
+	ConversationID uuid.UUID                   `json:"conversation_id"`
+	Subject        string                      `json:"subject"`
+	CustomerName   string                      `json:"customer_name"`
+	CustomerPhone  string                      `json:"customer_phone"`
+	IsGroup        bool                        `json:"is_group"`
+	Agent          string                      `json:"agent"`
+	Team           string                      `json:"team"`
+	Status         models.ConversationStatus   `json:"status"`
+	Priority       models.ConversationPriority `json:"priority"`
+	CreatedAt      time.Time                   `json:"created_at"`
+	ClosedAt       *time.Time                  `json:"closed_at"`
+	ExportedAt     time.Time                   `json:"exported_at"`
+	IncludesNotes  bool                        `json:"includes_notes"`
+	Entries        []TranscriptEntry           `json:"entries"`
+}
+
+// TranscriptEntry is a message, or an internal note when Kind is "note"
+type TranscriptEntry struct {
+	Kind       string                  `json:"kind"`
+	ID         uuid.UUID               `json:"id"`
+	At         time.Time               `json:"at"`
+	Sender     string                  `json:"sender"`
+	SenderRole string                  `json:"sender_role"` // customer, agent, system or note
+	Direction  models.MessageDirection `json:"direction,omitempty"`
+	Type       models.MessageType      `json:"type,omitempty"`
+	Status     models.MessageStatus    `json:"status,omitempty"`
+	StatusAt   *time.Time              `json:"status_at,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	Text       string                  `json:"text"`
+	MediaURL   string                  `json:"media_url,omitempty"`
+	FileName   string                  `json:"file_name,omitempty"`
+	MimeType   string                  `json:"mime_type,omitempty"`
+}
+
+// TranscriptService renders conversation transcripts and runs bulk exports
+type TranscriptService struct {
+	db         *gorm.DB
+	store      storage.Storage
+	loc        *time.Location
+	linkExpiry time.Duration
+	maxBulk    int
+	wake       chan struct{}
+}
+
+func NewTranscriptService(db *gorm.DB, store storage.Storage, cfg *config.Config) *TranscriptService {
+	loc, err := time.LoadLocation(cfg.BusinessTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return &TranscriptService{
+		db:         db,
+		store:      store,
+		loc:        loc,
+		linkExpiry: time.Duration(cfg.ExportLinkExpiryHours) * time.Hour,
+		maxBulk:    cfg.ExportMaxConversations,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Build loads a conversation's messages, and its internal notes if asked, in order.
+// Media we hold in storage is linked through a freshly signed URL.
+func (ts *TranscriptService) Build(ctx context.Context, conversationID uuid.UUID, includeNotes bool) (*Transcript, error) {
+	var conv models.Conversation
+	if err := ts.db.Preload("Customer").Preload("Agent").Preload("Team").First(&conv, "id = ?", conversationID).Error; err != nil {
+		return nil, err
+	}
+	t := &Transcript{
+		ConversationID: conv.ID,
+		Subject:        conv.Subject,
+		CustomerName:   conv.Customer.Name,
+		CustomerPhone:  conv.Customer.Phone,
+		IsGroup:        conv.IsGroup,
+		Status:         conv.Status,
+		Priority:       conv.Priority,
+		CreatedAt:      conv.CreatedAt.In(ts.loc),
+		ClosedAt:       conv.ClosedAt,
+		ExportedAt:     time.Now().In(ts.loc),
+		IncludesNotes:  includeNotes,
+	}
+	if conv.Agent != nil {
+		t.Agent = conv.Agent.Name
+	}
+	if conv.Team != nil {
+		t.Team = conv.Team.Name
+	}
+	if t.ClosedAt != nil {
+		closed := t.ClosedAt.In(ts.loc)
+		t.ClosedAt = &closed
+	}
+
+	var messages []models.Message
+	if err := ts.db.Preload("SentBy", preloadNoteAuthor).Where("conversation_id = ?", conv.ID).Order("created_at asc").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	agentAt, err := ts.agentHistory(&conv)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		t.Entries = append(t.Entries, ts.messageEntry(ctx, &conv, &messages[i], agentAt))
+	}
+
+	if includeNotes {
+		var notes []models.ConversationNote
+		if err := ts.db.Preload("Author", preloadNoteAuthor).Where("conversation_id = ?", conv.ID).Order("created_at asc").Find(&notes).Error; err != nil {
+			return nil, err
+		}
+		for _, n := range notes {
+			e := TranscriptEntry{Kind: "note", ID: n.ID, At: n.CreatedAt.In(ts.loc), SenderRole: "note", Text: n.Body}
+			if n.Author != nil {
+				e.Sender = n.Author.Name
+			}
+			t.Entries = append(t.Entries, e)
+		}
+		// messages stay ahead of notes made at the same moment
+		sort.SliceStable(t.Entries, func(i, j int) bool { return t.Entries[i].At.Before(t.Entries[j].At) })
+	}
+	return t, nil
+}
+
+// agentHistory returns a function giving the agent who held the conversation at a
+// time, from its assignment events, for attributing our replies
+func (ts *TranscriptService) agentHistory(conv *models.Conversation) (func(time.Time) string, error) {
+	var events []models.ConversationEvent
+	if err := ts.db.Where("conversation_id = ? AND type IN ?", conv.ID,
+		[]models.ConversationEventType{models.ConversationEventAssigned, models.ConversationEventTransferAccept}).
+		Order("created_at asc").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.ToValue)
+	}
+	if len(ids) > 0 {
+		var users []models.User
+		ts.db.Select("id, name").Where("id IN ?", ids).Find(&users)
+		for _, u := range users {
+			names[u.ID.String()] = u.Name
+		}
+	}
+	current := ""
+	if conv.Agent != nil {
+		current = conv.Agent.Name
+	}
+	return func(at time.Time) string {
+		name := ""
+		for _, e := range events {
+			if e.CreatedAt.After(at) {
+				break
+			}
+			name = names[e.ToValue]
+		}
+		if name == "" {
+			return current
+		}
+		return name
+	}, nil
+}
+
+func (ts *TranscriptService) messageEntry(ctx context.Context, conv *models.Conversation, m *models.Message, agentAt func(time.Time) string) TranscriptEntry {
+	e := TranscriptEntry{
+		Kind:      "message",
+		ID:        m.ID,
+		At:        m.CreatedAt.In(ts.loc),
+		Direction: m.Direction,
+		Type:      m.Type,
+		Status:    m.Status,
+		Text:      messageText(m),
+		MediaURL:  m.MediaURL,
+		FileName:  m.FileName,
+		MimeType:  m.MediaMimeType,
+	}
+	switch {
+	case m.Direction == models.MessageDirectionInbound:
+		e.SenderRole, e.Sender = "customer", conv.Customer.Name
+		if conv.IsGroup && (m.ParticipantName != "" || m.ParticipantID != "") {
+			e.Sender = firstNonEmpty(m.ParticipantName, m.ParticipantID)
+		}
+	case m.Automated:
+		e.SenderRole, e.Sender = "system", "System"
+	case m.SentBy != nil:
+		e.SenderRole, e.Sender = "agent", m.SentBy.Name
+	default:
+		e.SenderRole, e.Sender = "agent", firstNonEmpty(agentAt(m.CreatedAt), "Agent")
+	}
+	for _, at := range []*time.Time{m.FailedAt, m.ReadAt, m.DeliveredAt, m.SentAt} {
+		if at != nil {
+			local := at.In(ts.loc)
+			e.StatusAt = &local
+			break
+		}
+	}
+	if m.Status == models.MessageStatusFailed {
+		e.Error = strings.TrimSpace(m.ErrorCode + " " + m.ErrorTitle)
+	}
+	if m.MediaPath != "" && ts.store != nil {
+		if url, err := ts.store.SignedURL(ctx, m.MediaPath, ts.linkExpiry); err == nil {
+			e.MediaURL = url
+		}
+	}
+	return e
+}
+
+// messageText is what a message says, whatever its type
+func messageText(m *models.Message) string {
+	switch m.Type {
+	case models.MessageTypeLocation:
+		return strings.TrimSpace(fmt.Sprintf("Location: %s (%f, %f)", m.LocationName, m.Latitude, m.Longitude))
+	case models.MessageTypeContact:
+		return strings.TrimSpace("Contact: " + m.ContactName + " " + m.ContactPhone)
+	case models.MessageTypeText, models.MessageTypeTemplate, models.MessageTypeInteractive:
+		return m.Content
+	}
+	return firstNonEmpty(m.Caption, m.Content)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Render writes a transcript in the given format
+func (ts *TranscriptService) Render(w io.Writer, t *Transcript, format string) error {
+	switch format {
+	case "txt":
+		_, err := io.WriteString(w, t.text())
+		return err
+	case "html":
+		return transcriptHTML.Execute(w, t)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t)
+	case "pdf":
+		_, err := t.pdf().WriteTo(w)
+		return err
+	}
+	return ErrExportFormat
+}
+
+func (t *Transcript) header() []string {
+	lines := []string{
+		"Conversation " + t.ConversationID.String(),
+		"Customer: " + strings.TrimSpace(t.CustomerName+" "+t.CustomerPhone),
+	}
+	if t.Subject != "" {
+		lines = append(lines, "Subject: "+t.Subject)
+	}
+	lines = append(lines,
+		fmt.Sprintf("Status: %s, priority %s", t.Status, t.Priority),
+		"Agent: "+firstNonEmpty(t.Agent, "-")+", team: "+firstNonEmpty(t.Team, "-"),
+		"Started: "+t.CreatedAt.Format(transcriptTime),
+	)
+	if t.ClosedAt != nil {
+		lines = append(lines, "Closed: "+t.ClosedAt.Format(transcriptTime))
+	}
+	return append(lines, "Exported: "+t.ExportedAt.Format(transcriptTime))
+}
+
+// Heading is the first line of an entry: time, sender and, for messages, delivery status
+func (e TranscriptEntry) Heading() string {
+	s := "[" + e.At.Format(transcriptTime) + "] " + e.Sender
+	if e.Kind == "note" {
+		return s + " (internal note)"
+	}
+	s += " (" + e.SenderRole + ")"
+	if e.Type != models.MessageTypeText {
+		s += " [" + string(e.Type) + "]"
+	}
+	if e.Direction == models.MessageDirectionOutbound {
+		s += " - " + string(e.Status)
+		if e.Error != "" {
+			s += ": " + e.Error
+		}
+	}
+	return s
+}
+
+// Attachment describes an entry's media as a single line
+func (e TranscriptEntry) Attachment() string {
+	if e.MediaURL == "" {
+		return ""
+	}
+	return "Attachment: " + strings.TrimSpace(e.FileName+" "+e.MediaURL)
+}
+
+func (t *Transcript) text() string {
+	var b strings.Builder
+	for _, l := range t.header() {
+		b.WriteString(l + "\n")
+	}
+	for _, e := range t.Entries {
+		b.WriteString("\n" + e.Heading() + "\n")
+		if e.Text != "" {
+			b.WriteString(e.Text + "\n")
+		}
+		if a := e.Attachment(); a != "" {
+			b.WriteString(a + "\n")
+		}
+	}
+	return b.String()
+}
+
+func (t *Transcript) pdf() *pdf.Document {
+	doc := pdf.New("Conversation " + t.ConversationID.String())
+	for i, l := range t.header() {
+		if i == 0 {
+			doc.Heading(l)
+			continue
+		}
+		doc.Text(l)
+	}
+	for _, e := range t.Entries {
+		doc.Blank()
+		doc.Heading(e.Heading())
+		if e.Text != "" {
+			doc.Text(e.Text)
+		}
+		if a := e.Attachment(); a != "" {
+			doc.Text(a)
+		}
+	}
+	return doc
+}
+
+var transcriptHTML = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Conversation {{.ConversationID}}</title>
+<style>
+body{font-family:sans-serif;max-width:800px;margin:2em auto;color:#222}
+.entry{margin:1em 0;padding:.6em .8em;border-radius:6px;background:#f2f2f2}
+.agent,.system{background:#dcf8c6;margin-left:15%}
+.note{background:#fff4c2;border:1px dashed #d8b600}
+.meta{font-size:.8em;color:#666}
+.text{white-space:pre-wrap}
+img{max-width:100%}
+</style></head><body>
+<h1>Conversation {{.ConversationID}}</h1>
+<p>Customer: {{.CustomerName}} {{.CustomerPhone}}<br>
+{{if .Subject}}Subject: {{.Subject}}<br>{{end}}
+Status: {{.Status}}, priority {{.Priority}}<br>
+Agent: {{or .Agent "-"}}, team: {{or .Team "-"}}<br>
+Started: {{.CreatedAt.Format "2006-01-02 15:04:05 -07:00"}}<br>
+{{with .ClosedAt}}Closed: {{.Format "2006-01-02 15:04:05 -07:00"}}<br>{{end}}
+Exported: {{.ExportedAt.Format "2006-01-02 15:04:05 -07:00"}}</p>
+{{range .Entries}}<div class="entry {{.SenderRole}}">
+<div class="meta">{{.Heading}}</div>
+{{if .Text}}<div class="text">{{.Text}}</div>{{end}}
+{{if .MediaURL}}{{if eq .Type "image" "sticker"}}<a href="{{.MediaURL}}"><img src="{{.MediaURL}}" alt="{{.FileName}}"></a>{{else}}<a href="{{.MediaURL}}">{{or .FileName .MediaURL}}</a>{{end}}{{end}}
+</div>
+{{end}}</body></html>
+`))
@@ -31,16 +31,55 @@ func Connect(cfg *config.Config) (*gorm.DB, error) {
 	// Auto migrate models
 	err = db.AutoMigrate(
 		&models.User{},
+		&models.Tag{},
+		&models.BusinessSchedule{},
+		&models.Holiday{},
+		&models.Team{},
+		&models.RoutingRule{},
+		&models.SLAPolicy{},
 		&models.Customer{},
 		&models.Contact{},
+		&models.GroupParticipant{},
 		&models.Conversation{},
+		&models.SLAEvent{},
+		&models.ConversationEvent{},
+		&models.ConversationTransfer{},
+		&models.ConversationNote{},
+		&models.NoteRevision{},
+		&models.NoteMention{},
+		&models.Notification{},
 		&models.Message{},
 		&models.Template{},
+		&models.CannedResponse{},
+		&models.ChatbotRule{},
+		&models.ChatbotFlow{},
+		&models.ChatbotSession{},
 		&models.WebhookLog{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.ExportJob{},
+		&models.CustomerImportJob{},
+		&models.CSATSurvey{},
+		&models.ConversationRead{},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+	if err := backfillLastActivity(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill conversations: %w", err)
+	}
+	if err := backfillLastInbound(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill conversations: %w", err)
+	}
+	if err := backfillPhoneDigits(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill customers: %w", err)
+	}
+	if err := migrateLegacyNotes(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate notes: %w", err)
+	}
+	if err := migrateLegacyTags(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate tags: %w", err)
+	}
 
 	return db, nil
 }
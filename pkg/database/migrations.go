@@ -0,0 +1,96 @@
+package database
+
+import (
+	"time"
+	"whatsapp-crm/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// dataMigration marks a one-time data change as applied, so it does not run again
+// on the next start
+type dataMigration struct {
+	Name      string `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time
+}
+
+func (dataMigration) TableName() string { return "data_migrations" }
+
+// once runs fn the first time a database sees name, in the transaction that records it
+func once(db *gorm.DB, name string, fn func(tx *gorm.DB) error) error {
+	if err := db.AutoMigrate(&dataMigration{}); err != nil {
+		return err
+	}
+	var done int64
+	if err := db.Model(&dataMigration{}).Where("name = ?", name).Count(&done).Error; err != nil {
+		return err
+	}
+	if done > 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Create(&dataMigration{Name: name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// backfillLastActivity sets the list sort key of conversations from before it existed
+func backfillLastActivity(db *gorm.DB) error {
+	return once(db, "backfill_conversation_last_activity_at", func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE conversations SET last_activity_at = COALESCE(last_message_at, created_at)").Error
+	})
+}
+
+// backfillLastInbound dates conversations from before last_inbound_at existed by
+// their latest customer message, so auto-close does not take them all for idle
+func backfillLastInbound(db *gorm.DB) error {
+	return once(db, "backfill_conversation_last_inbound_at", func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE conversations SET last_inbound_at = " +
+			"(SELECT MAX(messages.created_at) FROM messages WHERE messages.conversation_id = conversations.id AND messages.direction = 'inbound') " +
+			"WHERE last_inbound_at IS NULL").Error
+	})
+}
+
+// migrateLegacyNotes turns the free-text notes field conversations had before internal
+// notes into a note each, by whoever changed it last, else the assignee, else the
+// first admin. The old column stays until DropLegacyColumns.
+func migrateLegacyNotes(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("conversations", "notes") {
+		return nil
+	}
+	return once(db, "conversation_notes_from_legacy_notes", func(tx *gorm.DB) error {
+		return tx.Exec(`INSERT INTO conversation_notes (id, conversation_id, author_id, body, created_at, updated_at)
+			SELECT UUID(), n.id, n.author_id, n.notes, n.at, n.at FROM (
+				SELECT c.id, c.notes,
+					COALESCE(
+						(SELECT e.actor_id FROM conversation_events e WHERE e.conversation_id = c.id AND e.type = 'notes_changed' AND e.actor_id IS NOT NULL ORDER BY e.created_at DESC LIMIT 1),
+						c.agent_id,
+						(SELECT u.id FROM users u WHERE u.role = 'admin' ORDER BY u.created_at LIMIT 1)
+					) AS author_id,
+					COALESCE((SELECT MAX(e.created_at) FROM conversation_events e WHERE e.conversation_id = c.id AND e.type = 'notes_changed'), c.updated_at) AS at
+				FROM conversations c WHERE c.notes IS NOT NULL AND TRIM(c.notes) <> ''
+			) n WHERE n.author_id IS NOT NULL`).Error
+	})
+}
+
+// backfillPhoneDigits fills customers.phone_digits for customers saved before it
+// existed; BeforeSave keeps it current from then on
+func backfillPhoneDigits(db *gorm.DB) error {
+	return once(db, "backfill_customer_phone_digits", func(tx *gorm.DB) error {
+		var rows []struct {
+			ID    string
+			Phone string
+		}
+		return tx.Table("customers").Select("id, phone").Where("phone <> '' AND phone IS NOT NULL").
+			FindInBatches(&rows, 1000, func(*gorm.DB, int) error {
+				for _, r := range rows {
+					if err := tx.Table("customers").Where("id = ?", r.ID).Update("phone_digits", models.PhoneDigits(r.Phone)).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+	})
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"whatsapp-crm/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxTagName is the size of tags.name
+const maxTagName = 100
+
+// legacyTagColumns are the old comma-separated tags columns and the join tables
+// that replace them
+var legacyTagColumns = []struct{ table, joinTable, foreignKey string }{
+	{"customers", "customer_tags", "customer_id"},
+	{"conversations", "conversation_tags", "conversation_id"},
+}
+
+// migrateLegacyTags copies the old comma-separated tags columns on customers and
+// conversations into the tags table and its join tables, once. Names longer than a
+// tag name can be are cut short. The columns stay until DropLegacyColumns.
+func migrateLegacyTags(db *gorm.DB) error {
+	return once(db, "legacy_tags", func(tx *gorm.DB) error {
+		ids := map[string]string{}
+		for _, l := range legacyTagColumns {
+			if !tx.Migrator().HasColumn(l.table, "tags") {
+				continue
+			}
+			var rows []struct {
+				ID   string
+				Tags string
+			}
+			if err := tx.Table(l.table).Select("id, tags").Where("tags IS NOT NULL AND tags <> ''").Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				for _, name := range strings.Split(row.Tags, ",") {
+					name = strings.TrimSpace(name)
+					if name == "" {
+						continue
+					}
+					if r := []rune(name); len(r) > maxTagName {
+						log.Printf("legacy tag on %s %s cut to %d characters: %q", l.table, row.ID, maxTagName, name)
+						name = strings.TrimSpace(string(r[:maxTagName]))
+					}
+					key := strings.ToLower(name)
+					if _, ok := ids[key]; !ok {
+						var tag models.Tag
+						if err := tx.Where("name = ?", name).Attrs(models.Tag{Scope: models.TagScopeAll}).FirstOrCreate(&tag).Error; err != nil {
+							return err
+						}
+						ids[key] = tag.ID.String()
+					}
+					if err := tx.Exec("INSERT IGNORE INTO "+l.joinTable+" ("+l.foreignKey+", tag_id) VALUES (?, ?)", row.ID, ids[key]).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// DropLegacyColumns removes the columns the tags and notes migrations copied out of.
+// It is run by hand with cmd/migrate, after checking the copied data, and refuses
+// while a migration it depends on has not run.
+func DropLegacyColumns(db *gorm.DB) error {
+	drops := []struct{ migration, table, column string }{
+		{"legacy_tags", "customers", "tags"},
+		{"legacy_tags", "conversations", "tags"},
+		{"conversation_notes_from_legacy_notes", "conversations", "notes"},
+	}
+	for _, d := range drops {
+		if !db.Migrator().HasColumn(d.table, d.column) {
+			continue
+		}
+		var done int64
+		if err := db.Model(&dataMigration{}).Where("name = ?", d.migration).Count(&done).Error; err != nil {
+			return err
+		}
+		if done == 0 {
+			return fmt.Errorf("%s.%s: migration %s has not run", d.table, d.column, d.migration)
+		}
+		if err := db.Migrator().DropColumn(d.table, d.column); err != nil {
+			return err
+		}
+		log.Printf("dropped %s.%s", d.table, d.column)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Package pdf writes plain text documents as PDF. It only knows the built-in Courier
+// fonts, so it needs no font files, and wraps lines by character count.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// A4 portrait in points
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 40
+	fontSize   = 9
+	leading    = 12
+	// Courier glyphs are 0.6 em wide
+	lineChars    = (pageWidth - 2*margin) * 10 / (6 * fontSize)
+	linesPerPage = (pageHeight - 2*margin) / leading
+)
+
+type line struct {
+	bold bool
+	text string
+}
+
+// Document collects lines until it is written out
+type Document struct {
+	title string
+	lines []line
+}
+
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// Heading adds a bold line
+func (d *Document) Heading(text string) {
+	d.add(true, text)
+}
+
+// Text adds text, wrapped to the page width; newlines start new lines
+func (d *Document) Text(text string) {
+	d.add(false, text)
+}
+
+// Blank adds an empty line
+func (d *Document) Blank() {
+	d.lines = append(d.lines, line{})
+}
+
+func (d *Document) add(bold bool, text string) {
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		for _, l := range wrap(para, lineChars) {
+			d.lines = append(d.lines, line{bold: bold, text: l})
+		}
+	}
+}
+
+// wrap breaks a paragraph at spaces so no line is longer than width characters;
+// longer words are cut
+func wrap(para string, width int) []string {
+	if utf8.RuneCountInString(para) <= width {
+		return []string{para}
+	}
+	var out []string
+	var cur []rune
+	for _, word := range strings.Fields(para) {
+		w := []rune(word)
+		if len(cur) > 0 && len(cur)+1+len(w) > width {
+			out = append(out, string(cur))
+			cur = cur[:0]
+		}
+		if len(cur) > 0 {
+			cur = append(cur, ' ')
+		}
+		for len(w) > width-len(cur) {
+			n := width - len(cur)
+			out = append(out, string(append(cur, w[:n]...)))
+			cur, w = cur[:0], w[n:]
+		}
+		cur = append(cur, w...)
+	}
+	if len(cur) > 0 || len(out) == 0 {
+		out = append(out, string(cur))
+	}
+	return out
+}
+
+// WriteTo writes the document as a PDF file
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) int {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return len(offsets)
+	}
+
+	pages := (len(d.lines) + linesPerPage - 1) / linesPerPage
+	if pages == 0 {
+		pages = 1
+	}
+	// 1 catalog, 2 page tree, 3 and 4 fonts, 5 info, then a page and its content per page
+	pageObj := func(i int) int { return 6 + 2*i }
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, pages)
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj(i))
+	}
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title (%s) /Producer (whatsapp-crm) >>", escape(d.title)))
+
+	for i := 0; i < pages; i++ {
+		end := (i + 1) * linesPerPage
+		if end > len(d.lines) {
+			end = len(d.lines)
+		}
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n%d TL\n%d %d Td\n", leading, margin, pageHeight-margin-fontSize)
+		bold := -1
+		for _, l := range d.lines[i*linesPerPage : end] {
+			if b := boolInt(l.bold); b != bold {
+				fmt.Fprintf(&content, "/F%d %d Tf\n", 1+b, fontSize)
+				bold = b
+			}
+			fmt.Fprintf(&content, "(%s) Tj T*\n", escape(l.text))
+		}
+		content.WriteString("ET\n")
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, pageObj(i)+1))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.WriteTo(w)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// winAnsi holds the characters WinAnsiEncoding places in 0x80-0x9f
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// escape encodes text as a PDF string in WinAnsiEncoding; characters it lacks, such
+// as emoji, become '?'
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			if c, ok := winAnsi[r]; ok {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else if r >= 0x20 {
+				b.WriteByte('?')
+			}
+		}
+	}
+	return b.String()
+}
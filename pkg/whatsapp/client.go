@@ -8,6 +8,8 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 	"whatsapp-crm/internal/config"
 )
@@ -19,9 +21,10 @@ type Client struct {
 }
 
 type SendMessageRequest struct {
-	To      string `json:"to"`
-	Type    string `json:"type"`
-	Message interface{} `json:"message"`
+	To            string      `json:"to"`
+	RecipientType string      `json:"recipient_type,omitempty"`
+	Type          string      `json:"type"`
+	Message       interface{} `json:"message"`
 }
 
 type TextMessage struct {
@@ -40,6 +43,20 @@ type TemplateMessage struct {
 	Components []TemplateComponent    `json:"components,omitempty"`
 }
 
+// InteractiveMessage is a body with reply buttons, or a list opened by Button
+type InteractiveMessage struct {
+	Type    string              `json:"type"`
+	Body    string              `json:"body"`
+	Button  string              `json:"button,omitempty"`
+	Options []InteractiveOption `json:"options"`
+}
+
+type InteractiveOption struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
 type TemplateLanguage struct {
 	Code string `json:"code"`
 }
@@ -59,6 +76,32 @@ type SendMessageResponse struct {
 	Status   string `json:"status"`
 	Error    string `json:"error,omitempty"`
 	Message  string `json:"message,omitempty"`
+	Code     json.RawMessage `json:"code,omitempty"` // a string or a number, depending on the gateway
+	Details  string `json:"details,omitempty"`
+}
+
+// ErrorCode is the provider's error code as text
+func (r *SendMessageResponse) ErrorCode() string {
+	code := strings.Trim(string(r.Code), `"`)
+	if code == "null" {
+		return ""
+	}
+	return code
+}
+
+// APIError carries the provider's reason when a send is rejected
+type APIError struct {
+	StatusCode int
+	Code       string
+	Title      string
+	Details    string
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("API error %s: %s", e.Code, e.Title)
+	}
+	return fmt.Sprintf("API error: %s", e.Title)
 }
 
 type MessageStatus struct {
@@ -120,6 +163,47 @@ func (c *Client) SendDocumentMessage(to, documentURL, filename, caption string)
 	return c.sendMessage(req)
 }
 
+// SendGroupTextMessage sends a text message to a group chat
+func (c *Client) SendGroupTextMessage(groupID, message string) (*SendMessageResponse, error) {
+	req := SendMessageRequest{
+		To:            groupID,
+		RecipientType: "group",
+		Type:          "text",
+		Message: TextMessage{
+			Body: message,
+		},
+	}
+
+	return c.sendMessage(req)
+}
+
+// SendGroupMediaMessage sends an image or document to a group chat
+func (c *Client) SendGroupMediaMessage(groupID, mediaType, mediaURL, filename, caption string) (*SendMessageResponse, error) {
+	req := SendMessageRequest{
+		To:            groupID,
+		RecipientType: "group",
+		Type:          mediaType,
+		Message: MediaMessage{
+			URL:      mediaURL,
+			Filename: filename,
+			Caption:  caption,
+		},
+	}
+
+	return c.sendMessage(req)
+}
+
+// SendInteractiveMessage sends reply buttons or a list; the tapped option comes back as an interactive message
+func (c *Client) SendInteractiveMessage(to string, interactive InteractiveMessage) (*SendMessageResponse, error) {
+	req := SendMessageRequest{
+		To:      to,
+		Type:    "interactive",
+		Message: interactive,
+	}
+
+	return c.sendMessage(req)
+}
+
 // SendTemplateMessage sends a template message
 func (c *Client) SendTemplateMessage(to, templateName, languageCode string, components []TemplateComponent) (*SendMessageResponse, error) {
 	req := SendMessageRequest{
@@ -164,11 +248,21 @@ func (c *Client) sendMessage(req SendMessageRequest) (*SendMessageResponse, erro
 
 	var response SendMessageResponse
 	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, &APIError{StatusCode: resp.StatusCode, Code: strconv.Itoa(resp.StatusCode), Title: http.StatusText(resp.StatusCode), Details: string(body)}
+		}
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return &response, fmt.Errorf("API error: %s", response.Error)
+		apiErr := &APIError{StatusCode: resp.StatusCode, Code: response.ErrorCode(), Title: response.Error, Details: response.Details}
+		if apiErr.Code == "" {
+			apiErr.Code = strconv.Itoa(resp.StatusCode)
+		}
+		if apiErr.Details == "" {
+			apiErr.Details = response.Message
+		}
+		return &response, apiErr
 	}
 
 	return &response, nil
@@ -234,6 +328,39 @@ func (c *Client) UploadMedia(filePath string) (string, error) {
 	return uploadResp.URL, nil
 }
 
+// MarkAsRead tells the customer their message was read (blue ticks); WhatsApp treats
+// earlier messages in the chat as read too
+func (c *Client) MarkAsRead(messageID string) error {
+	req, err := http.NewRequest("POST", c.APIURL+"/messages/"+messageID+"/read", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.APIToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response SendMessageResponse
+		body, _ := io.ReadAll(resp.Body)
+		_ = json.Unmarshal(body, &response)
+		apiErr := &APIError{StatusCode: resp.StatusCode, Code: response.ErrorCode(), Title: response.Error, Details: response.Details}
+		if apiErr.Code == "" {
+			apiErr.Code = strconv.Itoa(resp.StatusCode)
+		}
+		if apiErr.Title == "" {
+			apiErr.Title = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
 // GetMessageStatus gets message delivery status
 func (c *Client) GetMessageStatus(messageID string) (*MessageStatus, error) {
 	req, err := http.NewRequest("GET", c.APIURL+"/messages/"+messageID+"/status", nil)
@@ -0,0 +1,323 @@
+// Package xlsx reads the first worksheet of an Excel workbook as text and writes
+// single-sheet workbooks. It covers what spreadsheets of contacts need: shared and
+// inline strings, numbers and booleans, without styles or formulas.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrNoSheet is returned for a zip that is not an Excel workbook
+var ErrNoSheet = errors.New("xlsx: no worksheet found")
+
+// ErrSheetBounds is returned for a cell beyond the largest sheet Excel supports
+var ErrSheetBounds = errors.New("xlsx: cell outside the sheet")
+
+// MaxRows and MaxCols are Excel's sheet size. Rows and cells are placed by their
+// reference, so a single far-off cell would otherwise allocate everything before it.
+const (
+	MaxRows = 1048576
+	MaxCols = 16384
+)
+
+// Read returns the rows of the workbook's first sheet. Empty cells are empty strings,
+// and rows are as long as their last filled cell.
+func Read(r io.ReaderAt, size int64) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	sheet := files[firstSheet(files)]
+	if sheet == nil {
+		return nil, ErrNoSheet
+	}
+	var shared []string
+	if f := files["xl/sharedStrings.xml"]; f != nil {
+		if shared, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+	return readSheet(sheet, shared)
+}
+
+// firstSheet finds the part of the first sheet listed in the workbook
+func firstSheet(files map[string]*zip.File) string {
+	const fallback = "xl/worksheets/sheet1.xml"
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if decodePart(files["xl/workbook.xml"], &workbook) != nil || len(workbook.Sheets) == 0 ||
+		decodePart(files["xl/_rels/workbook.xml.rels"], &rels) != nil {
+		return fallback
+	}
+	for _, rel := range rels.Rels {
+		if rel.ID != workbook.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+	return fallback
+}
+
+func decodePart(f *zip.File, v interface{}) error {
+	if f == nil {
+		return ErrNoSheet
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// richText is a string item: plain text, or runs of formatted text
+type richText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (s richText) String() string {
+	if len(s.Runs) == 0 {
+		return s.T
+	}
+	var b strings.Builder
+	for _, r := range s.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var sst struct {
+		Items []richText `xml:"si"`
+	}
+	if err := decodePart(f, &sst); err != nil {
+		return nil, err
+	}
+	out := make([]string, len(sst.Items))
+	for i, s := range sst.Items {
+		out[i] = s.String()
+	}
+	return out, nil
+}
+
+type cell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline richText `xml:"is"`
+}
+
+// readSheet streams the rows of a worksheet so large sheets are not held as XML
+func readSheet(f *zip.File, shared []string) ([][]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var rows [][]string
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row struct {
+			Index int    `xml:"r,attr"`
+			Cells []cell `xml:"c"`
+		}
+		if err := dec.DecodeElement(&row, &start); err != nil {
+			return nil, err
+		}
+		if row.Index > MaxRows || len(rows) >= MaxRows {
+			return nil, ErrSheetBounds
+		}
+		// rows without cells are left out of the file, so place each by its number
+		for row.Index > len(rows)+1 {
+			rows = append(rows, nil)
+		}
+		var values []string
+		for _, c := range row.Cells {
+			col := len(values)
+			if c.Ref != "" {
+				col = columnIndex(c.Ref)
+			}
+			if col >= MaxCols {
+				return nil, ErrSheetBounds
+			}
+			for len(values) < col {
+				values = append(values, "")
+			}
+			values = append(values, cellText(c, shared))
+		}
+		rows = append(rows, values)
+	}
+}
+
+func cellText(c cell, shared []string) string {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(c.Value)
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return shared[i]
+	case "inlineStr":
+		return c.Inline.String()
+	case "b":
+		if c.Value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "str", "e":
+		return c.Value
+	}
+	// numbers are kept in full, so a phone number typed as a number reads back whole
+	if f, err := strconv.ParseFloat(c.Value, 64); err == nil && strings.ContainsAny(c.Value, "eE") {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return c.Value
+}
+
+// columnIndex turns the letters of a cell reference such as "AB12" into 27
+func columnIndex(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		if n = n*26 + int(r-'A'+1); n > MaxCols {
+			// far enough out to be rejected, without overflowing
+			break
+		}
+	}
+	return n - 1
+}
+
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// Writer writes a workbook with one sheet, streaming rows as they come
+type Writer struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+// NewWriter starts a workbook whose only sheet is called sheetName
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + escape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return nil, err
+		}
+	}
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &Writer{zw: zw, sheet: sheet}, nil
+}
+
+// WriteRow adds a row of text cells
+func (w *Writer) WriteRow(values []string) error {
+	w.row++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, w.row)
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, columnName(i), w.row, escape(v))
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(w.sheet, b.String())
+	return err
+}
+
+// Close finishes the sheet and the workbook
+func (w *Writer) Close() error {
+	if _, err := io.WriteString(w.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	// control characters other than tab and newline are not allowed in XML
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}